@@ -2,13 +2,22 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/madatsci/gophermart/internal/app"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
 	"github.com/madatsci/gophermart/internal/app/store/database"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/urfave/cli/v2"
 )
 
@@ -63,6 +72,474 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:  "ledger",
+			Usage: "inspect and maintain points ledger",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "reconcile",
+					Usage: "recompute account balances from history and report drifts",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "output format: table or json",
+							Value: "table",
+						},
+						&cli.BoolFlag{
+							Name:  "repair",
+							Usage: "credit missing accruals and reset cached balances",
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							drifts, err := s.ReconcileAccounts(ctx, cliCtx.Bool("repair"))
+							if err != nil {
+								return err
+							}
+							return printDrifts(cliCtx.App.Writer, cliCtx.String("format"), drifts)
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "users",
+			Usage: "manage users",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "role",
+					Usage: "grant the role to the user, it takes effect immediately",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "login",
+							Usage:    "user login",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "role",
+							Usage:    "user, support or admin",
+							Required: true,
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						role := models.Role(cliCtx.String("role"))
+						if !role.Valid() {
+							return fmt.Errorf("invalid role: %s", role)
+						}
+
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							user, err := s.SetUserRole(ctx, cliCtx.String("login"), role)
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "user %s is %s now\n", user.Login, user.Role)
+							return err
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "accounts",
+			Usage: "manage accounts",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "adjust",
+					Usage: "credit positive or debit negative sum to the user account",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "login",
+							Usage:    "user login",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "sum",
+							Usage:    "points to credit, negative to debit",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "reason",
+							Usage:    "goodwill, compensation, correction, fraud or other",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:  "comment",
+							Usage: "explanation of the adjustment, required for other reason",
+						},
+						&cli.StringFlag{
+							Name:     "operator",
+							Usage:    "name of the operator making the adjustment",
+							Required: true,
+							EnvVars:  []string{"OPERATOR"},
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						sum, err := points.Parse(cliCtx.String("sum"))
+						if err != nil {
+							return fmt.Errorf("invalid sum: %s", cliCtx.String("sum"))
+						}
+
+						adjustment := models.Adjustment{
+							Amount:   sum,
+							Reason:   models.AdjustmentReason(cliCtx.String("reason")),
+							Comment:  strings.TrimSpace(cliCtx.String("comment")),
+							Operator: "cli:" + cliCtx.String("operator"),
+						}
+						if err := adjustment.Validate(); err != nil {
+							return err
+						}
+
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							user, err := s.GetUserByLogin(ctx, cliCtx.String("login"))
+							if err != nil {
+								return fmt.Errorf("user %s: %w", cliCtx.String("login"), err)
+							}
+							acc, err := s.GetAccountByUserID(ctx, user.ID)
+							if err != nil {
+								return err
+							}
+							adjustment.AccountID = acc.ID
+
+							transaction, err := s.AdjustBalance(ctx, adjustment)
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "adjusted balance of %s by %s points (%s)\n", user.Login, transaction.Amount, transaction.Reason)
+							return err
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "orders",
+			Usage: "manage orders",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "return",
+					Usage: "take back accrual of the order returned to the shop",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "order",
+							Usage:    "order number",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:    "policy",
+							Usage:   "how to take back points which have already been spent: negative, debt or partial",
+							Value:   string(models.ClawbackPolicyDebt),
+							EnvVars: []string{"CLAWBACK_POLICY"},
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						policy := models.ClawbackPolicy(cliCtx.String("policy"))
+						if !policy.Valid() {
+							return fmt.Errorf("invalid policy: %s", policy)
+						}
+
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							clawback, err := s.ReturnOrder(ctx, cliCtx.String("order"), policy)
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "order %s returned: accrual %s, clawed %s, debt %s, forgiven %s\n",
+								clawback.OrderNumber, clawback.Accrual, clawback.Clawed, clawback.Debt, clawback.Forgiven)
+							return err
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "promo",
+			Usage: "manage promo codes",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "create",
+					Usage: "create promo code which credits points to users who redeem it",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "code",
+							Usage:    "promo code, it is case-insensitive",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "sum",
+							Usage:    "points credited to every user who redeems the code",
+							Required: true,
+						},
+						&cli.IntFlag{
+							Name:  "max-redemptions",
+							Usage: "how many times the code can be redeemed in total, 1 makes it single-use",
+							Value: 1,
+						},
+						&cli.StringFlag{
+							Name:  "starts-at",
+							Usage: "time from which the code can be redeemed, RFC 3339 or YYYY-MM-DD",
+						},
+						&cli.StringFlag{
+							Name:  "ends-at",
+							Usage: "time from which the code can not be redeemed, RFC 3339 or YYYY-MM-DD",
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						sum, err := points.Parse(cliCtx.String("sum"))
+						if err != nil {
+							return fmt.Errorf("invalid sum: %s", cliCtx.String("sum"))
+						}
+						startsAt, err := parseTime(cliCtx.String("starts-at"))
+						if err != nil {
+							return fmt.Errorf("invalid starts-at: %w", err)
+						}
+						endsAt, err := parseTime(cliCtx.String("ends-at"))
+						if err != nil {
+							return fmt.Errorf("invalid ends-at: %w", err)
+						}
+
+						promo := models.PromoCode{
+							ID:             uuid.NewString(),
+							Code:           strings.ToUpper(strings.TrimSpace(cliCtx.String("code"))),
+							Amount:         sum,
+							MaxRedemptions: cliCtx.Int("max-redemptions"),
+							StartsAt:       startsAt,
+							EndsAt:         endsAt,
+							CreatedAt:      time.Now(),
+							UpdatedAt:      time.Now(),
+						}
+						if err := promo.Validate(); err != nil {
+							return err
+						}
+
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							promo, err := s.CreatePromoCode(ctx, promo)
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "promo code %s credits %s points, can be redeemed %d times\n", promo.Code, promo.Amount, promo.MaxRedemptions)
+							return err
+						})
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "list promo codes with the number of redemptions",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "output format: table or json",
+							Value: "table",
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							promos, err := s.ListPromoCodes(ctx)
+							if err != nil {
+								return err
+							}
+							return printPromoCodes(cliCtx.App.Writer, cliCtx.String("format"), promos)
+						})
+					},
+				},
+				{
+					Name:  "disable",
+					Usage: "forbid redeeming the promo code",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "code",
+							Usage:    "promo code",
+							Required: true,
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							promo, err := s.DisablePromoCode(ctx, strings.ToUpper(strings.TrimSpace(cliCtx.String("code"))))
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "promo code %s disabled after %d redemptions\n", promo.Code, promo.Redemptions)
+							return err
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "campaigns",
+			Usage: "manage accrual campaigns",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "create",
+					Usage: "create campaign which raises accruals of orders processed within its window",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "name",
+							Usage:    "campaign name",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "starts-at",
+							Usage:    "time from which the campaign applies, RFC 3339 or YYYY-MM-DD",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:     "ends-at",
+							Usage:    "time from which the campaign does not apply, RFC 3339 or YYYY-MM-DD",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:  "eligibility",
+							Usage: "all, tier or new_users",
+							Value: string(models.CampaignEligibilityAll),
+						},
+						&cli.StringFlag{
+							Name:  "tier",
+							Usage: "the lowest tier eligible for the campaign with tier eligibility",
+						},
+						&cli.IntFlag{
+							Name:  "new-user-days",
+							Usage: "how many days after registration users are eligible for the campaign with new_users eligibility",
+							Value: 30,
+						},
+						&cli.StringFlag{
+							Name:  "multiplier",
+							Usage: "multiplier of the accrual, e.g. 2 doubles it",
+						},
+						&cli.StringFlag{
+							Name:  "bonus",
+							Usage: "fixed points added to the accrual",
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						startsAt, err := parseTime(cliCtx.String("starts-at"))
+						if err != nil {
+							return fmt.Errorf("invalid starts-at: %w", err)
+						}
+						endsAt, err := parseTime(cliCtx.String("ends-at"))
+						if err != nil {
+							return fmt.Errorf("invalid ends-at: %w", err)
+						}
+
+						campaign := models.Campaign{
+							ID:          uuid.NewString(),
+							Name:        strings.TrimSpace(cliCtx.String("name")),
+							StartsAt:    *startsAt,
+							EndsAt:      *endsAt,
+							Eligibility: models.CampaignEligibility(cliCtx.String("eligibility")),
+							CreatedAt:   time.Now(),
+							UpdatedAt:   time.Now(),
+						}
+						switch campaign.Eligibility {
+						case models.CampaignEligibilityTier:
+							campaign.Tier = models.Tier(cliCtx.String("tier"))
+						case models.CampaignEligibilityNewUsers:
+							campaign.NewUserDays = cliCtx.Int("new-user-days")
+						}
+						if v := cliCtx.String("multiplier"); v != "" {
+							if campaign.Multiplier, err = points.Parse(v); err != nil {
+								return fmt.Errorf("invalid multiplier: %s", v)
+							}
+						}
+						if v := cliCtx.String("bonus"); v != "" {
+							if campaign.FixedBonus, err = points.Parse(v); err != nil {
+								return fmt.Errorf("invalid bonus: %s", v)
+							}
+						}
+						if err := campaign.Validate(); err != nil {
+							return err
+						}
+
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							campaign, err := s.CreateCampaign(ctx, campaign)
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "campaign %s created: %s\n", campaign.ID, campaign.Name)
+							return err
+						})
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "list campaigns with the number of accruals they have been applied to and points they have added",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:  "format",
+							Usage: "output format: table or json",
+							Value: "table",
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							campaigns, err := s.ListCampaigns(ctx)
+							if err != nil {
+								return err
+							}
+							return printCampaigns(cliCtx.App.Writer, cliCtx.String("format"), campaigns)
+						})
+					},
+				},
+				{
+					Name:  "disable",
+					Usage: "stop applying the campaign to accruals",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "id",
+							Usage:    "campaign ID",
+							Required: true,
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							campaign, err := s.DisableCampaign(ctx, cliCtx.String("id"))
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "campaign %s disabled\n", campaign.Name)
+							return err
+						})
+					},
+				},
+			},
+		},
+		{
+			Name:  "withdrawals",
+			Usage: "manage withdrawals",
+			Subcommands: []*cli.Command{
+				{
+					Name:  "refund",
+					Usage: "return points withdrawn for the cancelled order",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:     "order",
+							Usage:    "order number",
+							Required: true,
+						},
+						&cli.StringFlag{
+							Name:  "sum",
+							Usage: "points to refund, the whole withdrawal if not set",
+						},
+					},
+					Action: func(cliCtx *cli.Context) error {
+						var sum points.Points
+						if v := cliCtx.String("sum"); v != "" {
+							var err error
+							if sum, err = points.Parse(v); err != nil || sum <= 0 {
+								return fmt.Errorf("invalid sum: %s", v)
+							}
+						}
+
+						return runStore(cliCtx, func(ctx context.Context, s store.Store) error {
+							refund, err := s.RefundWithdrawal(ctx, cliCtx.String("order"), sum)
+							if err != nil {
+								return err
+							}
+							_, err = fmt.Fprintf(cliCtx.App.Writer, "refunded %s points for order %s\n", refund.Amount, refund.OrderNumber)
+							return err
+						})
+					},
+				},
+			},
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -73,22 +550,178 @@ func main() {
 func run(cliCtx *cli.Context, fn func(ctx context.Context, m *database.Migrations) error) error {
 	ctx := cliCtx.Context
 
-	app, err := app.New(ctx, app.Options{
+	store, err := databaseStore(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	m, err := database.NewMigrations(store.Conn())
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, m)
+}
+
+func runStore(cliCtx *cli.Context, fn func(ctx context.Context, s store.Store) error) error {
+	store, err := databaseStore(cliCtx)
+	if err != nil {
+		return err
+	}
+
+	return fn(cliCtx.Context, store)
+}
+
+func databaseStore(cliCtx *cli.Context) (*database.Store, error) {
+	app, err := app.New(cliCtx.Context, app.Options{
 		DatabaseURI: cliCtx.String("d"),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	store, ok := app.Store().(*database.Store)
 	if !ok {
-		return errors.New("database store must be used for migrations")
+		return nil, errors.New("database store must be used")
 	}
 
-	m, err := database.NewMigrations(store.Conn())
+	return store, nil
+}
+
+// parseTime parses time in RFC 3339 format or date, empty value means no time.
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		return err
+		if t, err = time.Parse(time.DateOnly, value); err != nil {
+			return nil, fmt.Errorf("%s is neither RFC 3339 time nor date", value)
+		}
 	}
 
-	return fn(ctx, m)
+	return &t, nil
+}
+
+func printPromoCodes(w io.Writer, format string, promos []models.PromoCode) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(promos)
+	case "table":
+		if len(promos) == 0 {
+			_, err := fmt.Fprintln(w, "no promo codes found")
+			return err
+		}
+
+		formatTime := func(t *time.Time) string {
+			if t == nil {
+				return "-"
+			}
+			return t.Format(time.RFC3339)
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CODE\tSUM\tREDEMPTIONS\tSTARTS\tENDS\tDISABLED") //nolint:errcheck
+		for _, p := range promos {
+			fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%s\t%s\t%s\n", //nolint:errcheck
+				p.Code,
+				p.Amount,
+				p.Redemptions,
+				p.MaxRedemptions,
+				formatTime(p.StartsAt),
+				formatTime(p.EndsAt),
+				formatTime(p.DisabledAt),
+			)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func printCampaigns(w io.Writer, format string, campaigns []models.CampaignStats) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(campaigns)
+	case "table":
+		if len(campaigns) == 0 {
+			_, err := fmt.Fprintln(w, "no campaigns found")
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSTARTS\tENDS\tELIGIBILITY\tMULTIPLIER\tBONUS\tORDERS\tCREDITED\tDISABLED") //nolint:errcheck
+		for _, c := range campaigns {
+			eligibility := string(c.Eligibility)
+			switch c.Eligibility {
+			case models.CampaignEligibilityTier:
+				eligibility += ":" + string(c.Tier)
+			case models.CampaignEligibilityNewUsers:
+				eligibility += fmt.Sprintf(":%dd", c.NewUserDays)
+			}
+			disabled := "-"
+			if c.DisabledAt != nil {
+				disabled = c.DisabledAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", //nolint:errcheck
+				c.ID,
+				c.Name,
+				c.StartsAt.Format(time.RFC3339),
+				c.EndsAt.Format(time.RFC3339),
+				eligibility,
+				c.Multiplier,
+				c.FixedBonus,
+				c.Orders,
+				c.Credited,
+				disabled,
+			)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+func printDrifts(w io.Writer, format string, drifts []models.AccountDrift) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(drifts)
+	case "table":
+		if len(drifts) == 0 {
+			_, err := fmt.Fprintln(w, "no drifts found")
+			return err
+		}
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ACCOUNT\tCURRENT\tEXPECTED\tWITHDRAWN\tEXPECTED\tHELD\tEXPECTED\tDEBT\tMISSING ACCRUALS\tADJUSTMENT\tREPAIRED") //nolint:errcheck
+		for _, d := range drifts {
+			numbers := make([]string, 0, len(d.MissingAccruals))
+			for _, o := range d.MissingAccruals {
+				numbers = append(numbers, o.Number)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\n", //nolint:errcheck
+				d.AccountID,
+				d.CurrentPointsTotal,
+				d.ExpectedPointsTotal,
+				d.WithdrawnTotal,
+				d.ExpectedWithdrawnTotal,
+				d.HeldTotal,
+				d.ExpectedHeldTotal,
+				d.DebtTotal,
+				strings.Join(numbers, ","),
+				d.Adjustment,
+				d.Repaired,
+			)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
 }
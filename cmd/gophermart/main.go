@@ -14,10 +14,30 @@ func main() {
 
 	app, err := app.New(context.Background(), app.Options{
 		RunAddress:           flags.RunAddress,
+		AdminRunAddress:      flags.AdminRunAddress,
 		AccrualSystemAddress: flags.AccrualSystemAddress,
+		AccrualWorkers:       flags.AccrualWorkers,
+		AccrualBatchSize:     flags.AccrualBatchSize,
+		AccrualRateLimit:     flags.AccrualRateLimit,
+		AccrualBackoffBase:   flags.AccrualBackoffBase,
+		AccrualBackoffMax:    flags.AccrualBackoffMax,
+		AccrualOrderMaxAge:   flags.AccrualOrderMaxAge,
+		AccrualLeaseTTL:      flags.AccrualLeaseTTL,
+		AccrualTimeout:       flags.AccrualTimeout,
 		DatabaseURI:          flags.DatabaseURI,
 		TokenSecret:          flags.TokenSecret,
 		TokenDuration:        flags.TokenDuration,
+		IdempotencyKeyTTL:    flags.IdempotencyKeyTTL,
+		HoldTTL:              flags.HoldTTL,
+		PointsLifetimeMonths: flags.PointsLifetimeMonths,
+		TransferMinSum:       flags.TransferMinSum,
+		TransferDailyLimit:   flags.TransferDailyLimit,
+		WithdrawalRules:      flags.WithdrawalRules,
+		TierRules:            flags.TierRules,
+		TierWindowMonths:     flags.TierWindowMonths,
+		ReferrerBonus:        flags.ReferrerBonus,
+		RefereeBonus:         flags.RefereeBonus,
+		ReferralLimit:        flags.ReferralLimit,
 	})
 	if err != nil {
 		panic(err)
@@ -0,0 +1,19 @@
+package accrual
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// backoff returns the delay before the next check of the order after the given number of attempts.
+// The base delay doubles with every attempt up to maxDelay, then a random half of it is taken away
+// so that orders uploaded together do not hit accrual system at the same time.
+func backoff(attempts int, base, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxDelay)
+
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/http"
+	"sync"
 	"time"
 
 	"github.com/madatsci/gophermart/internal/app/config"
@@ -16,93 +16,192 @@ import (
 
 type (
 	AccrualService struct {
-		client AccrualProvider
-		store  store.Store
-		logger *zap.SugaredLogger
+		client    AccrualProvider
+		store     store.Store
+		workers   int
+		batchSize int
+		logger    *zap.SugaredLogger
+
+		// Orders which have not changed are polled again with exponential backoff from backoffBase
+		// to backoffMax, orders not registered in accrual system for orderMaxAge become invalid.
+		backoffBase time.Duration
+		backoffMax  time.Duration
+		orderMaxAge time.Duration
+		// leaseTTL is how long orders claimed by the instance are skipped by other instances.
+		leaseTTL time.Duration
 	}
 
 	AccrualProvider interface {
-		GetOrder(number string) (client.OrderResponse, error)
+		GetOrder(ctx context.Context, number string) (client.OrderResponse, error)
 	}
 )
 
-const syncOrdersLimit = 10
-
 // New creates new accrual service.
-func New(config *config.Config, store store.Store, logger *zap.SugaredLogger) *AccrualService {
-	return &AccrualService{
-		client: client.New(config, logger),
-		store:  store,
-		logger: logger,
+func New(config *config.Config, store store.Store, logger *zap.SugaredLogger) (*AccrualService, error) {
+	c, err := client.New(config, logger, client.WithTimeout(config.AccrualTimeout))
+	if err != nil {
+		return nil, err
 	}
+
+	return &AccrualService{
+		client:    c,
+		store:     store,
+		workers:   config.AccrualWorkers,
+		batchSize: config.AccrualBatchSize,
+		logger:    logger,
+
+		backoffBase: config.AccrualBackoffBase,
+		backoffMax:  config.AccrualBackoffMax,
+		orderMaxAge: config.AccrualOrderMaxAge,
+		leaseTTL:    config.AccrualLeaseTTL,
+	}, nil
 }
 
-// UpdateOrders fetches orders from accrual system and updates their status and accrual.
+// SyncOrders claims a batch of orders due to be checked and fetches them from accrual system
+// by a pool of workers to update their status and accrual. Requests of all workers share the rate
+// limiter of the client. Claimed orders are not polled by other instances until they are rescheduled,
+// orders left unprocessed, e.g. when the instance stops, are picked up after the lease expires.
 func (a *AccrualService) SyncOrders(ctx context.Context) error {
-	orders, err := a.store.ListOrdersByStatus(ctx, []models.OrderStatus{models.OrderStatusNew, models.OrderStatusProcessing}, syncOrdersLimit)
+	statuses := []models.OrderStatus{models.OrderStatusNew, models.OrderStatusProcessing}
+	orders, err := a.store.ClaimDueOrders(ctx, statuses, time.Now(), a.leaseTTL, a.batchSize)
 	if err != nil {
 		return err
 	}
 
+	var (
+		wg       sync.WaitGroup
+		jobs     = make(chan models.Order)
+		nWorkers = min(max(a.workers, 1), len(orders))
+	)
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for o := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				a.syncOrder(ctx, o)
+			}
+		}()
+	}
+
+feed:
 	for _, o := range orders {
-		if ctx.Err() != nil {
-			return nil
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- o:
 		}
+	}
+	close(jobs)
+	wg.Wait()
 
-		or, err := a.client.GetOrder(o.Number)
-		if err != nil {
-			var requestErr *client.RequestError
-			if errors.As(err, &requestErr) {
-				if requestErr.StatusCode == http.StatusNoContent {
-					a.logError(o.Number, errors.New("order is not registered in accrual system"))
-					continue
-				}
-				if requestErr.StatusCode == http.StatusTooManyRequests && requestErr.RetryAfter != 0 {
-					err = &ErrTooManyRequests{
-						RetryAfter: requestErr.RetryAfter,
-					}
-					a.logError(o.Number, err)
+	return nil
+}
 
-					return err
-				}
+// syncOrder fetches the order from accrual system and updates it, errors are logged.
+// Unless the status has changed, the next check of the order is postponed.
+func (a *AccrualService) syncOrder(ctx context.Context, o models.Order) {
+	or, err := a.client.GetOrder(ctx, o.Number)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case errors.Is(err, client.ErrRateLimited):
+			// the order is not to blame, it is released to be polled again as soon as the client lets
+			a.releaseOrder(ctx, o)
+			return
+		case errors.Is(err, client.ErrNotRegistered):
+			if a.orderMaxAge > 0 && time.Since(o.CreatedAt) > a.orderMaxAge {
+				a.invalidateOrder(ctx, o)
+				return
 			}
-			a.logError(o.Number, err)
-			continue
 		}
+		a.logError(o.Number, err)
+		a.scheduleCheck(ctx, o)
+		return
+	}
+
+	newStatus, err := mapOrderStatus(or.Status)
+	if err != nil {
+		a.logError(o.Number, err)
+		a.scheduleCheck(ctx, o)
+		return
+	}
+
+	prevStatus := o.Status
+	if newStatus == prevStatus {
+		a.scheduleCheck(ctx, o)
+		return
+	}
 
-		newStatus, err := mapOrderStatus(or.Status)
-		if err != nil {
-			a.logError(o.Number, err)
-			continue
+	updated := o
+	updated.Status = newStatus
+	updated.Accrual = or.Accrual
+	updated.UpdatedAt = time.Now()
+	updated.Attempts = 0
+	updated.NextCheckAt = updated.UpdatedAt
+
+	if _, err := a.store.ProcessOrder(ctx, updated, prevStatus); err != nil {
+		a.logError(o.Number, err)
+		// the lease is released with a backoff instead of being held until it expires
+		if ctx.Err() == nil {
+			a.scheduleCheck(ctx, o)
 		}
+		return
+	}
 
-		prevStatus := o.Status
-		if newStatus != prevStatus {
-			o.Status = newStatus
-			o.Accrual = or.Accrual
-			o.UpdatedAt = time.Now()
+	a.logger.With(
+		"number", o.Number,
+		"prev_status", prevStatus,
+		"new_status", newStatus,
+		"accrual", updated.Accrual,
+	).Info("updated order")
+}
 
-			_, err := a.store.UpdateOrder(ctx, o, prevStatus)
-			if err != nil {
-				a.logError(o.Number, err)
-				continue
-			}
+// scheduleCheck counts the attempt and postpones the next check of the order.
+func (a *AccrualService) scheduleCheck(ctx context.Context, o models.Order) {
+	o.Attempts++
+	o.NextCheckAt = time.Now().Add(backoff(o.Attempts, a.backoffBase, a.backoffMax))
 
-			_, err = a.store.AddBalance(ctx, o)
-			if err != nil {
-				a.logger.With("number", o.Number, "err", err).Errorln("could not add accrued points to balance")
-			}
+	if err := a.store.ScheduleOrderCheck(ctx, o); err != nil {
+		a.logError(o.Number, err)
+	}
+}
+
+// releaseOrder gives up the lease on the order without counting the attempt.
+func (a *AccrualService) releaseOrder(ctx context.Context, o models.Order) {
+	o.NextCheckAt = time.Now()
+
+	if err := a.store.ScheduleOrderCheck(ctx, o); err != nil {
+		a.logError(o.Number, err)
+	}
+}
 
-			a.logger.With(
-				"number", o.Number,
-				"prev_status", prevStatus,
-				"new_status", newStatus,
-				"accrual", o.Accrual,
-			).Info("updated order")
+// invalidateOrder moves the order which has never been registered in accrual system to INVALID status.
+func (a *AccrualService) invalidateOrder(ctx context.Context, o models.Order) {
+	invalid := o
+	invalid.Status = models.OrderStatusInvalid
+	invalid.UpdatedAt = time.Now()
+	invalid.NextCheckAt = invalid.UpdatedAt
+
+	prevStatus := o.Status
+	if _, err := a.store.UpdateOrder(ctx, invalid, prevStatus); err != nil {
+		a.logError(o.Number, err)
+		if ctx.Err() == nil {
+			a.scheduleCheck(ctx, o)
 		}
+		return
 	}
 
-	return nil
+	a.logger.With(
+		"number", o.Number,
+		"prev_status", prevStatus,
+		"age", time.Since(o.CreatedAt).Round(time.Second),
+	).Info("order has not been registered in accrual system, marked invalid")
 }
 
 func (a *AccrualService) logError(orderNumber string, err error) {
@@ -123,11 +222,3 @@ func mapOrderStatus(accrualOrderStatus client.OrderStatus) (models.OrderStatus,
 		return "", fmt.Errorf("unknown order status received from accrual system: %s", accrualOrderStatus)
 	}
 }
-
-type ErrTooManyRequests struct {
-	RetryAfter time.Duration
-}
-
-func (e *ErrTooManyRequests) Error() string {
-	return fmt.Sprintf("too many requests, retry after %s", e.RetryAfter)
-}
@@ -0,0 +1,225 @@
+package accrual
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/accrual/client"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeProvider struct {
+	mu       sync.Mutex
+	active   int
+	parallel int
+	requests atomic.Int32
+}
+
+func (p *fakeProvider) GetOrder(_ context.Context, number string) (client.OrderResponse, error) {
+	p.requests.Add(1)
+
+	p.mu.Lock()
+	p.active++
+	p.parallel = max(p.parallel, p.active)
+	p.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	p.mu.Lock()
+	p.active--
+	p.mu.Unlock()
+
+	return client.OrderResponse{Order: number, Status: client.OrderStatusProcessed, Accrual: points.FromInt(10)}, nil
+}
+
+func newTestOrders(n int) []models.Order {
+	orders := make([]models.Order, n)
+	for i := range orders {
+		orders[i] = models.Order{Number: fmt.Sprintf("%d", 1000+i), Status: models.OrderStatusNew}
+	}
+
+	return orders
+}
+
+func TestSyncOrders(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("orders are fetched in parallel", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		p := &fakeProvider{}
+		a := &AccrualService{client: p, store: m, workers: 4, batchSize: 8, logger: zap.NewNop().Sugar()}
+
+		m.EXPECT().ClaimDueOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 8).Return(newTestOrders(8), nil)
+		m.EXPECT().ProcessOrder(gomock.Any(), gomock.Any(), models.OrderStatusNew).Return(models.Order{}, nil).Times(8)
+
+		require.NoError(t, a.SyncOrders(context.Background()))
+		assert.Equal(t, int32(8), p.requests.Load())
+		assert.Equal(t, 4, p.parallel)
+	})
+
+	t.Run("cancelled context stops the batch", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		p := &fakeProvider{}
+		a := &AccrualService{client: p, store: m, workers: 2, batchSize: 10, logger: zap.NewNop().Sugar()}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		m.EXPECT().ClaimDueOrders(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), 10).Return(newTestOrders(10), nil)
+
+		require.NoError(t, a.SyncOrders(ctx))
+		assert.Zero(t, p.requests.Load())
+	})
+}
+
+type stubProvider struct {
+	resp client.OrderResponse
+	err  error
+}
+
+func (p stubProvider) GetOrder(_ context.Context, _ string) (client.OrderResponse, error) {
+	return p.resp, p.err
+}
+
+func TestSyncOrderSchedule(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	newService := func(m *mocks.MockStore, p AccrualProvider) *AccrualService {
+		return &AccrualService{
+			client:      p,
+			store:       m,
+			logger:      zap.NewNop().Sugar(),
+			backoffBase: 10 * time.Second,
+			backoffMax:  time.Minute,
+			orderMaxAge: 24 * time.Hour,
+		}
+	}
+
+	t.Run("unchanged order is postponed", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		a := newService(m, stubProvider{resp: client.OrderResponse{Status: client.OrderStatusProcessing}})
+		o := models.Order{Number: "1000", Status: models.OrderStatusProcessing, Attempts: 2, CreatedAt: time.Now()}
+
+		m.EXPECT().ScheduleOrderCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o models.Order) error {
+			assert.Equal(t, 3, o.Attempts)
+			assert.WithinRange(t, o.NextCheckAt, time.Now().Add(20*time.Second), time.Now().Add(40*time.Second))
+			return nil
+		})
+
+		a.syncOrder(context.Background(), o)
+	})
+
+	t.Run("changed order starts schedule over", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		a := newService(m, stubProvider{resp: client.OrderResponse{Status: client.OrderStatusProcessing}})
+		o := models.Order{Number: "1000", Status: models.OrderStatusNew, Attempts: 5, CreatedAt: time.Now()}
+
+		m.EXPECT().ProcessOrder(gomock.Any(), gomock.Any(), models.OrderStatusNew).DoAndReturn(
+			func(_ context.Context, o models.Order, _ models.OrderStatus) (models.Order, error) {
+				assert.Equal(t, models.OrderStatusProcessing, o.Status)
+				assert.Zero(t, o.Attempts)
+				assert.False(t, o.NextCheckAt.After(time.Now()))
+				return o, nil
+			})
+
+		a.syncOrder(context.Background(), o)
+	})
+
+	t.Run("failed update releases the lease with backoff", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		a := newService(m, stubProvider{resp: client.OrderResponse{Status: client.OrderStatusProcessed, Accrual: points.FromInt(10)}})
+		o := models.Order{Number: "1000", Status: models.OrderStatusNew, Attempts: 1, CreatedAt: time.Now()}
+
+		m.EXPECT().ProcessOrder(gomock.Any(), gomock.Any(), models.OrderStatusNew).Return(models.Order{}, errors.New("connection reset"))
+		m.EXPECT().ScheduleOrderCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o models.Order) error {
+			assert.Equal(t, models.OrderStatusNew, o.Status, "the order is scheduled in its stored status")
+			assert.Equal(t, 2, o.Attempts)
+			assert.WithinRange(t, o.NextCheckAt, time.Now().Add(10*time.Second), time.Now().Add(20*time.Second))
+			return nil
+		})
+
+		a.syncOrder(context.Background(), o)
+	})
+
+	t.Run("failed poll is postponed", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		a := newService(m, stubProvider{err: client.ErrServer})
+		o := models.Order{Number: "1000", Status: models.OrderStatusNew, CreatedAt: time.Now()}
+
+		m.EXPECT().ScheduleOrderCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o models.Order) error {
+			assert.Equal(t, 1, o.Attempts)
+			return nil
+		})
+
+		a.syncOrder(context.Background(), o)
+	})
+
+	t.Run("rate limited order is released", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		a := newService(m, stubProvider{err: client.ErrRateLimited})
+		o := models.Order{Number: "1000", Status: models.OrderStatusNew, Attempts: 2, CreatedAt: time.Now()}
+
+		m.EXPECT().ScheduleOrderCheck(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, o models.Order) error {
+			assert.Equal(t, 2, o.Attempts, "rate limited poll must not count")
+			assert.False(t, o.NextCheckAt.After(time.Now()))
+			return nil
+		})
+
+		a.syncOrder(context.Background(), o)
+	})
+
+	t.Run("unregistered order becomes invalid with age", func(t *testing.T) {
+		m := mocks.NewMockStore(ctrl)
+		a := newService(m, stubProvider{err: &client.RequestError{StatusCode: http.StatusNoContent}})
+
+		young := models.Order{Number: "1000", Status: models.OrderStatusNew, CreatedAt: time.Now().Add(-time.Hour)}
+		m.EXPECT().ScheduleOrderCheck(gomock.Any(), gomock.Any()).Return(nil)
+		a.syncOrder(context.Background(), young)
+
+		old := models.Order{Number: "1001", Status: models.OrderStatusNew, CreatedAt: time.Now().Add(-25 * time.Hour)}
+		m.EXPECT().UpdateOrder(gomock.Any(), gomock.Any(), models.OrderStatusNew).DoAndReturn(
+			func(_ context.Context, o models.Order, _ models.OrderStatus) (models.Order, error) {
+				assert.Equal(t, models.OrderStatusInvalid, o.Status)
+				return o, nil
+			})
+		a.syncOrder(context.Background(), old)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	base, maxDelay := 10*time.Second, time.Minute
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 2, want: 20 * time.Second},
+		{attempts: 3, want: 40 * time.Second},
+		{attempts: 4, want: time.Minute},
+		{attempts: 100, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempts), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				d := backoff(tt.attempts, base, maxDelay)
+				assert.GreaterOrEqual(t, d, tt.want/2)
+				assert.LessOrEqual(t, d, tt.want)
+			}
+		})
+	}
+}
@@ -2,16 +2,18 @@ package app
 
 import (
 	"context"
-	"errors"
 	"time"
 
 	"github.com/madatsci/gophermart/internal/app/accrual"
 	"github.com/madatsci/gophermart/internal/app/config"
 	"github.com/madatsci/gophermart/internal/app/database"
 	"github.com/madatsci/gophermart/internal/app/logger"
+	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/server"
 	"github.com/madatsci/gophermart/internal/app/store"
 	db "github.com/madatsci/gophermart/internal/app/store/database"
+	"github.com/madatsci/gophermart/internal/app/store/memory"
+	"github.com/madatsci/gophermart/pkg/points"
 	"go.uber.org/zap"
 )
 
@@ -26,10 +28,30 @@ type (
 
 	Options struct {
 		RunAddress           string
+		AdminRunAddress      string
 		AccrualSystemAddress string
+		AccrualWorkers       int
+		AccrualBatchSize     int
+		AccrualRateLimit     int
+		AccrualBackoffBase   time.Duration
+		AccrualBackoffMax    time.Duration
+		AccrualOrderMaxAge   time.Duration
+		AccrualLeaseTTL      time.Duration
+		AccrualTimeout       time.Duration
 		DatabaseURI          string
 		TokenSecret          []byte
 		TokenDuration        time.Duration
+		IdempotencyKeyTTL    time.Duration
+		HoldTTL              time.Duration
+		PointsLifetimeMonths int
+		TransferMinSum       points.Points
+		TransferDailyLimit   points.Points
+		WithdrawalRules      models.WithdrawalRules
+		TierRules            []models.TierRule
+		TierWindowMonths     int
+		ReferrerBonus        points.Points
+		RefereeBonus         points.Points
+		ReferralLimit        int
 	}
 
 	AccrualService interface {
@@ -40,6 +62,46 @@ type (
 // New creates new App.
 func New(ctx context.Context, opts Options) (*App, error) {
 	config := config.New(opts.RunAddress, opts.AccrualSystemAddress, opts.DatabaseURI, opts.TokenSecret, opts.TokenDuration)
+	config.AdminRunAddress = opts.AdminRunAddress
+	if opts.AccrualWorkers != 0 {
+		config.AccrualWorkers = opts.AccrualWorkers
+	}
+	if opts.AccrualBatchSize != 0 {
+		config.AccrualBatchSize = opts.AccrualBatchSize
+	}
+	config.AccrualRateLimit = opts.AccrualRateLimit
+	if opts.AccrualBackoffBase != 0 {
+		config.AccrualBackoffBase = opts.AccrualBackoffBase
+	}
+	if opts.AccrualBackoffMax != 0 {
+		config.AccrualBackoffMax = opts.AccrualBackoffMax
+	}
+	config.AccrualOrderMaxAge = opts.AccrualOrderMaxAge
+	if opts.AccrualLeaseTTL != 0 {
+		config.AccrualLeaseTTL = opts.AccrualLeaseTTL
+	}
+	if opts.AccrualTimeout != 0 {
+		config.AccrualTimeout = opts.AccrualTimeout
+	}
+	if opts.IdempotencyKeyTTL != 0 {
+		config.IdempotencyKeyTTL = opts.IdempotencyKeyTTL
+	}
+	if opts.HoldTTL != 0 {
+		config.HoldTTL = opts.HoldTTL
+	}
+	config.PointsLifetimeMonths = opts.PointsLifetimeMonths
+	if opts.TransferMinSum != 0 {
+		config.TransferMinSum = opts.TransferMinSum
+	}
+	config.TransferDailyLimit = opts.TransferDailyLimit
+	config.WithdrawalRules = opts.WithdrawalRules
+	if len(opts.TierRules) > 0 {
+		config.TierRules = opts.TierRules
+	}
+	config.TierWindowMonths = opts.TierWindowMonths
+	config.ReferrerBonus = opts.ReferrerBonus
+	config.RefereeBonus = opts.RefereeBonus
+	config.ReferralLimit = opts.ReferralLimit
 
 	log, err := logger.New()
 	if err != nil {
@@ -57,16 +119,27 @@ func New(ctx context.Context, opts Options) (*App, error) {
 		config: config,
 		logger: log,
 		store:  store,
-		as:     accrual.New(config, store, log),
 		server: srv,
 	}
 
+	// the app is used by CLI just for the store, orders are not synced without accrual system address
+	if config.AccrualSystemAddress != "" {
+		if app.as, err = accrual.New(config, store, log); err != nil {
+			return nil, err
+		}
+	}
+
 	return app, nil
 }
 
 // Start starts the application.
 func (a *App) Start(ctx context.Context) error {
-	go a.syncOrders(ctx)
+	if a.as != nil {
+		go a.syncOrders(ctx)
+	}
+	go a.purgeIdempotencyKeys(ctx)
+	go a.expirePoints(ctx)
+	go a.expireHolds(ctx)
 	return a.server.Start()
 }
 
@@ -78,35 +151,93 @@ func (a *App) Store() store.Store {
 func (a *App) syncOrders(ctx context.Context) {
 	a.logger.Info("starting orders sync")
 	ticker := time.NewTicker(a.config.AccrualFetchPeriod)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			err := a.as.SyncOrders(ctx)
-			if err != nil {
+			// requests to accrual system are held by the client while it asks to retry later
+			if err := a.as.SyncOrders(ctx); err != nil {
 				a.logger.With("err", err).Errorln("could not sync orders")
-				var accrualErr *accrual.ErrTooManyRequests
-				if errors.As(err, &accrualErr) {
-					a.logger.With("err", err).Info("pausing orders sync")
-					ticker.Stop()
-					time.Sleep(accrualErr.RetryAfter)
-					ticker = time.NewTicker(a.config.AccrualFetchPeriod)
-				}
+			}
+		}
+	}
+}
+
+func (a *App) purgeIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(a.config.IdempotencyKeyPurgePeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := a.store.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+			if err != nil {
+				a.logger.With("err", err).Errorln("could not purge expired idempotency keys")
+				continue
+			}
+			if deleted > 0 {
+				a.logger.With("deleted", deleted).Info("purged expired idempotency keys")
+			}
+		}
+	}
+}
+
+func (a *App) expirePoints(ctx context.Context) {
+	ticker := time.NewTicker(a.config.PointsExpirationPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := a.store.ExpirePoints(ctx, time.Now())
+			if err != nil {
+				a.logger.With("err", err).Errorln("could not expire points")
+			}
+			if len(expired) > 0 {
+				a.logger.With("lots", len(expired)).Info("expired points")
+			}
+		}
+	}
+}
+
+func (a *App) expireHolds(ctx context.Context) {
+	ticker := time.NewTicker(a.config.HoldExpirationPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := a.store.ExpireHolds(ctx, time.Now())
+			if err != nil {
+				a.logger.With("err", err).Errorln("could not expire holds")
+			}
+			if expired > 0 {
+				a.logger.With("expired", expired).Info("released expired holds")
 			}
 		}
 	}
 }
 
 func newStore(ctx context.Context, cfg *config.Config) (store.Store, error) {
+	tierPolicy := models.TierPolicy{Rules: cfg.TierRules, WindowMonths: cfg.TierWindowMonths}
+	referralBonuses := models.ReferralBonuses{Referrer: cfg.ReferrerBonus, Referee: cfg.RefereeBonus}
+
 	if cfg.DatabaseURI != "" {
 		conn, err := database.NewClient(ctx, cfg.DatabaseURI)
 		if err != nil {
 			return nil, err
 		}
-		return db.New(ctx, conn)
+		return db.New(ctx, conn, db.WithPointsLifetime(cfg.PointsLifetimeMonths), db.WithTierPolicy(tierPolicy), db.WithReferralBonuses(referralBonuses))
 	}
 
-	return nil, errors.New("database URI must be provided")
+	return memory.New(memory.WithPointsLifetime(cfg.PointsLifetimeMonths), memory.WithTierPolicy(tierPolicy), memory.WithReferralBonuses(referralBonuses)), nil
 }
@@ -1,13 +1,58 @@
 package config
 
-import "time"
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+)
 
 type Config struct {
 	RunAddress           string
+	AdminRunAddress      string
 	AccrualSystemAddress string
 	DatabaseURI          string
 	AccrualFetchPeriod   time.Duration
 
+	// AccrualWorkers fetch AccrualBatchSize orders in parallel, AccrualRateLimit caps requests
+	// of all workers per minute.
+	AccrualWorkers   int
+	AccrualBatchSize int
+	AccrualRateLimit int
+
+	// Unchanged orders are polled with exponential backoff from AccrualBackoffBase up to AccrualBackoffMax,
+	// orders not registered in accrual system for AccrualOrderMaxAge become invalid, zero keeps polling them.
+	AccrualBackoffBase time.Duration
+	AccrualBackoffMax  time.Duration
+	AccrualOrderMaxAge time.Duration
+	// AccrualLeaseTTL is how long orders claimed for sync by one instance are skipped by others,
+	// it must be longer than a batch takes to sync.
+	AccrualLeaseTTL time.Duration
+	// AccrualTimeout limits a single request to accrual system, failed requests are retried.
+	AccrualTimeout time.Duration
+
+	IdempotencyKeyTTL         time.Duration
+	IdempotencyKeyPurgePeriod time.Duration
+
+	HoldTTL              time.Duration
+	HoldExpirationPeriod time.Duration
+
+	PointsLifetimeMonths     int
+	PointsExpirationPeriod   time.Duration
+	PointsExpiringSoonPeriod time.Duration
+
+	TransferMinSum     points.Points
+	TransferDailyLimit points.Points
+
+	WithdrawalRules models.WithdrawalRules
+
+	TierRules        []models.TierRule
+	TierWindowMonths int
+
+	ReferrerBonus points.Points
+	RefereeBonus  points.Points
+	ReferralLimit int
+
 	TokenSecret    []byte
 	TokenDuration  time.Duration
 	TokenIssuer    string
@@ -22,6 +67,27 @@ func New(runAddress, accrualSystemAddress, databaseURI string, tokenSecret []byt
 		DatabaseURI:          databaseURI,
 		AccrualFetchPeriod:   20 * time.Second,
 
+		AccrualWorkers:   4,
+		AccrualBatchSize: 100,
+
+		AccrualBackoffBase: 20 * time.Second,
+		AccrualBackoffMax:  time.Hour,
+		AccrualLeaseTTL:    5 * time.Minute,
+		AccrualTimeout:     10 * time.Second,
+
+		IdempotencyKeyTTL:         24 * time.Hour,
+		IdempotencyKeyPurgePeriod: time.Hour,
+
+		HoldTTL:              15 * time.Minute,
+		HoldExpirationPeriod: time.Minute,
+
+		PointsExpirationPeriod:   time.Hour,
+		PointsExpiringSoonPeriod: 30 * 24 * time.Hour,
+
+		TransferMinSum: points.FromInt(1),
+
+		TierRules: models.DefaultTierRules(),
+
 		TokenSecret:    tokenSecret,
 		TokenDuration:  tokenDuration,
 		TokenIssuer:    "gophermart",
@@ -9,16 +9,47 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
 )
 
 var (
 	RunAddress           = "localhost:8080"
 	AccrualSystemAddress = "http://localhost:8081"
+	AccrualWorkers       = 4
+	AccrualBatchSize     = 100
+	AccrualRateLimit     int
+	AccrualBackoffBase   = time.Second * 20
+	AccrualBackoffMax    = time.Hour
+	AccrualOrderMaxAge   = time.Hour * 24 * 7
+	AccrualLeaseTTL      = time.Minute * 5
+	AccrualTimeout       = time.Second * 10
+
+	AdminRunAddress string
 
 	DatabaseURI string
 
 	TokenSecret   = []byte("secret_key")
 	TokenDuration = time.Hour * 24 * 365
+
+	IdempotencyKeyTTL = time.Hour * 24
+
+	HoldTTL = time.Minute * 15
+
+	PointsLifetimeMonths int
+
+	TransferMinSum     = points.FromInt(1)
+	TransferDailyLimit points.Points
+
+	WithdrawalRules models.WithdrawalRules
+
+	TierRules        []models.TierRule
+	TierWindowMonths int
+
+	ReferrerBonus = points.FromInt(100)
+	RefereeBonus  = points.FromInt(50)
+	ReferralLimit = 10
 )
 
 func Parse() error {
@@ -31,6 +62,15 @@ func Parse() error {
 		return nil
 	})
 
+	flag.Func("admin-address", "address and port to run admin API in the form of host:port, admin API is served with the rest of API if not set", func(flagValue string) error {
+		if err := validateAddress(flagValue); err != nil {
+			return fmt.Errorf("invalid admin address: %s", err)
+		}
+
+		AdminRunAddress = flagValue
+		return nil
+	})
+
 	flag.Func("d", "database URI", func(flagValue string) error {
 		if flagValue == "" {
 			return errors.New("invalid database URI")
@@ -50,6 +90,86 @@ func Parse() error {
 		return nil
 	})
 
+	flag.Func("accrual-workers", "number of workers fetching orders from accrual system in parallel", func(flagValue string) error {
+		n, err := strconv.Atoi(flagValue)
+		if err != nil || n < 1 {
+			return errors.New("invalid number")
+		}
+
+		AccrualWorkers = n
+		return nil
+	})
+
+	flag.Func("accrual-batch-size", "maximum number of orders synced with accrual system at once", func(flagValue string) error {
+		n, err := strconv.Atoi(flagValue)
+		if err != nil || n < 1 {
+			return errors.New("invalid number")
+		}
+
+		AccrualBatchSize = n
+		return nil
+	})
+
+	flag.Func("accrual-rate-limit", "maximum requests to accrual system per minute shared by all workers, 0 means no limit", func(flagValue string) error {
+		n, err := strconv.Atoi(flagValue)
+		if err != nil || n < 0 {
+			return errors.New("invalid number")
+		}
+
+		AccrualRateLimit = n
+		return nil
+	})
+
+	flag.Func("accrual-backoff-base", "delay before the second check of an order which has not changed in accrual system, doubled with every next check", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration <= 0 {
+			return errors.New("invalid duration")
+		}
+
+		AccrualBackoffBase = duration
+		return nil
+	})
+
+	flag.Func("accrual-backoff-max", "maximum delay between checks of an order in accrual system", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration <= 0 {
+			return errors.New("invalid duration")
+		}
+
+		AccrualBackoffMax = duration
+		return nil
+	})
+
+	flag.Func("accrual-order-max-age", "age after which an order not registered in accrual system becomes invalid, 0 means never", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration < 0 {
+			return errors.New("invalid duration")
+		}
+
+		AccrualOrderMaxAge = duration
+		return nil
+	})
+
+	flag.Func("accrual-lease-ttl", "how long orders claimed for sync by the instance are skipped by other instances", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration <= 0 {
+			return errors.New("invalid duration")
+		}
+
+		AccrualLeaseTTL = duration
+		return nil
+	})
+
+	flag.Func("accrual-timeout", "time limit of a single request to accrual system", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration <= 0 {
+			return errors.New("invalid duration")
+		}
+
+		AccrualTimeout = duration
+		return nil
+	})
+
 	flag.Func("token-secret", "authentication token secret key", func(flagValue string) error {
 		if flagValue == "" {
 			return errors.New("invalid secret key")
@@ -73,12 +193,180 @@ func Parse() error {
 		return nil
 	})
 
+	flag.Func("idempotency-key-ttl", "how long responses to requests with Idempotency-Key are stored", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration <= 0 {
+			return errors.New("invalid duration")
+		}
+
+		IdempotencyKeyTTL = duration
+		return nil
+	})
+
+	flag.Func("hold-ttl", "how long points are reserved by a hold unless it is captured or voided", func(flagValue string) error {
+		duration, err := time.ParseDuration(flagValue)
+		if err != nil || duration <= 0 {
+			return errors.New("invalid duration")
+		}
+
+		HoldTTL = duration
+		return nil
+	})
+
+	flag.Func("points-lifetime", "number of months after which earned points expire, 0 means never", func(flagValue string) error {
+		months, err := strconv.Atoi(flagValue)
+		if err != nil || months < 0 {
+			return errors.New("invalid number of months")
+		}
+
+		PointsLifetimeMonths = months
+		return nil
+	})
+
+	flag.Func("transfer-min-sum", "minimum points which can be transferred to another user", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum <= 0 {
+			return errors.New("invalid sum")
+		}
+
+		TransferMinSum = sum
+		return nil
+	})
+
+	flag.Func("transfer-daily-limit", "maximum points which the user can transfer within 24 hours, 0 means no limit", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		TransferDailyLimit = sum
+		return nil
+	})
+
+	flag.Func("withdrawal-min-sum", "minimum points which can be withdrawn at once, 0 means no minimum", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		WithdrawalRules.MinSum = sum
+		return nil
+	})
+
+	flag.Func("withdrawal-max-sum", "maximum points which can be withdrawn at once, 0 means no maximum", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		WithdrawalRules.MaxSum = sum
+		return nil
+	})
+
+	flag.Func("withdrawal-daily-limit", "maximum points which the user can withdraw within 24 hours, 0 means no limit", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		WithdrawalRules.DailyLimit = sum
+		return nil
+	})
+
+	flag.Func("withdrawal-monthly-limit", "maximum points which the user can withdraw within a month, 0 means no limit", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		WithdrawalRules.MonthlyLimit = sum
+		return nil
+	})
+
+	flag.Func("withdrawal-granularity", "withdrawn sums must be multiples of it, e.g. 1 allows only whole points, 0 means any sum", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		WithdrawalRules.Granularity = sum
+		return nil
+	})
+
+	flag.Func("withdrawal-max-order-share", "maximum percentage of the order total which can be paid with points, 0 means no limit", func(flagValue string) error {
+		share, err := strconv.Atoi(flagValue)
+		if err != nil || share < 0 || share > 100 {
+			return errors.New("invalid percentage")
+		}
+
+		WithdrawalRules.MaxOrderShare = share
+		return nil
+	})
+
+	flag.Func("tier-rules", "membership tiers in the form of tier:threshold:multiplier separated by commas, e.g. bronze:0:1,silver:1000:1.25,gold:5000:1.5", func(flagValue string) error {
+		rules, err := models.ParseTierRules(flagValue)
+		if err != nil {
+			return err
+		}
+
+		TierRules = rules
+		return nil
+	})
+
+	flag.Func("tier-window", "number of months which accruals qualify for a tier, 0 means the whole history", func(flagValue string) error {
+		months, err := strconv.Atoi(flagValue)
+		if err != nil || months < 0 {
+			return errors.New("invalid number of months")
+		}
+
+		TierWindowMonths = months
+		return nil
+	})
+
+	flag.Func("referrer-bonus", "points credited to the referrer for the first processed order of the referee", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		ReferrerBonus = sum
+		return nil
+	})
+
+	flag.Func("referee-bonus", "points credited to the referee for their first processed order", func(flagValue string) error {
+		sum, err := points.Parse(flagValue)
+		if err != nil || sum < 0 {
+			return errors.New("invalid sum")
+		}
+
+		RefereeBonus = sum
+		return nil
+	})
+
+	flag.Func("referral-limit", "maximum number of users invited by one referrer, 0 means no limit", func(flagValue string) error {
+		limit, err := strconv.Atoi(flagValue)
+		if err != nil || limit < 0 {
+			return errors.New("invalid limit")
+		}
+
+		ReferralLimit = limit
+		return nil
+	})
+
 	flag.Parse()
 
 	if envRunAddress := os.Getenv("RUN_ADDRESS"); envRunAddress != "" {
 		RunAddress = envRunAddress
 	}
 
+	if envAdminRunAddress := os.Getenv("ADMIN_RUN_ADDRESS"); envAdminRunAddress != "" {
+		if err := validateAddress(envAdminRunAddress); err != nil {
+			return fmt.Errorf("invalid ADMIN_RUN_ADDRESS: %s", envAdminRunAddress)
+		}
+
+		AdminRunAddress = envAdminRunAddress
+	}
+
 	if envDatabaseURI := os.Getenv("DATABASE_URI"); envDatabaseURI != "" {
 		DatabaseURI = envDatabaseURI
 	}
@@ -87,6 +375,78 @@ func Parse() error {
 		AccrualSystemAddress = envAccrualSystemAddress
 	}
 
+	if envAccrualWorkers := os.Getenv("ACCRUAL_WORKERS"); envAccrualWorkers != "" {
+		n, err := strconv.Atoi(envAccrualWorkers)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid ACCRUAL_WORKERS: %s", envAccrualWorkers)
+		}
+
+		AccrualWorkers = n
+	}
+
+	if envAccrualBatchSize := os.Getenv("ACCRUAL_BATCH_SIZE"); envAccrualBatchSize != "" {
+		n, err := strconv.Atoi(envAccrualBatchSize)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid ACCRUAL_BATCH_SIZE: %s", envAccrualBatchSize)
+		}
+
+		AccrualBatchSize = n
+	}
+
+	if envAccrualRateLimit := os.Getenv("ACCRUAL_RATE_LIMIT"); envAccrualRateLimit != "" {
+		n, err := strconv.Atoi(envAccrualRateLimit)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid ACCRUAL_RATE_LIMIT: %s", envAccrualRateLimit)
+		}
+
+		AccrualRateLimit = n
+	}
+
+	if envAccrualBackoffBase := os.Getenv("ACCRUAL_BACKOFF_BASE"); envAccrualBackoffBase != "" {
+		duration, err := time.ParseDuration(envAccrualBackoffBase)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid ACCRUAL_BACKOFF_BASE: %s", envAccrualBackoffBase)
+		}
+
+		AccrualBackoffBase = duration
+	}
+
+	if envAccrualBackoffMax := os.Getenv("ACCRUAL_BACKOFF_MAX"); envAccrualBackoffMax != "" {
+		duration, err := time.ParseDuration(envAccrualBackoffMax)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid ACCRUAL_BACKOFF_MAX: %s", envAccrualBackoffMax)
+		}
+
+		AccrualBackoffMax = duration
+	}
+
+	if envAccrualOrderMaxAge := os.Getenv("ACCRUAL_ORDER_MAX_AGE"); envAccrualOrderMaxAge != "" {
+		duration, err := time.ParseDuration(envAccrualOrderMaxAge)
+		if err != nil || duration < 0 {
+			return fmt.Errorf("invalid ACCRUAL_ORDER_MAX_AGE: %s", envAccrualOrderMaxAge)
+		}
+
+		AccrualOrderMaxAge = duration
+	}
+
+	if envAccrualLeaseTTL := os.Getenv("ACCRUAL_LEASE_TTL"); envAccrualLeaseTTL != "" {
+		duration, err := time.ParseDuration(envAccrualLeaseTTL)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid ACCRUAL_LEASE_TTL: %s", envAccrualLeaseTTL)
+		}
+
+		AccrualLeaseTTL = duration
+	}
+
+	if envAccrualTimeout := os.Getenv("ACCRUAL_TIMEOUT"); envAccrualTimeout != "" {
+		duration, err := time.ParseDuration(envAccrualTimeout)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid ACCRUAL_TIMEOUT: %s", envAccrualTimeout)
+		}
+
+		AccrualTimeout = duration
+	}
+
 	if envTokenSecretKey := os.Getenv("TOKEN_SECRET_KEY"); envTokenSecretKey != "" {
 		TokenSecret = []byte(envTokenSecretKey)
 	}
@@ -100,6 +460,150 @@ func Parse() error {
 		TokenDuration = duration
 	}
 
+	if envIdempotencyKeyTTL := os.Getenv("IDEMPOTENCY_KEY_TTL"); envIdempotencyKeyTTL != "" {
+		duration, err := time.ParseDuration(envIdempotencyKeyTTL)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid IDEMPOTENCY_KEY_TTL: %s", envIdempotencyKeyTTL)
+		}
+
+		IdempotencyKeyTTL = duration
+	}
+
+	if envHoldTTL := os.Getenv("HOLD_TTL"); envHoldTTL != "" {
+		duration, err := time.ParseDuration(envHoldTTL)
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("invalid HOLD_TTL: %s", envHoldTTL)
+		}
+
+		HoldTTL = duration
+	}
+
+	if envPointsLifetime := os.Getenv("POINTS_LIFETIME_MONTHS"); envPointsLifetime != "" {
+		months, err := strconv.Atoi(envPointsLifetime)
+		if err != nil || months < 0 {
+			return fmt.Errorf("invalid POINTS_LIFETIME_MONTHS: %s", envPointsLifetime)
+		}
+
+		PointsLifetimeMonths = months
+	}
+
+	if envTransferMinSum := os.Getenv("TRANSFER_MIN_SUM"); envTransferMinSum != "" {
+		sum, err := points.Parse(envTransferMinSum)
+		if err != nil || sum <= 0 {
+			return fmt.Errorf("invalid TRANSFER_MIN_SUM: %s", envTransferMinSum)
+		}
+
+		TransferMinSum = sum
+	}
+
+	if envTransferDailyLimit := os.Getenv("TRANSFER_DAILY_LIMIT"); envTransferDailyLimit != "" {
+		sum, err := points.Parse(envTransferDailyLimit)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid TRANSFER_DAILY_LIMIT: %s", envTransferDailyLimit)
+		}
+
+		TransferDailyLimit = sum
+	}
+
+	if envWithdrawalMinSum := os.Getenv("WITHDRAWAL_MIN_SUM"); envWithdrawalMinSum != "" {
+		sum, err := points.Parse(envWithdrawalMinSum)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid WITHDRAWAL_MIN_SUM: %s", envWithdrawalMinSum)
+		}
+
+		WithdrawalRules.MinSum = sum
+	}
+
+	if envWithdrawalMaxSum := os.Getenv("WITHDRAWAL_MAX_SUM"); envWithdrawalMaxSum != "" {
+		sum, err := points.Parse(envWithdrawalMaxSum)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid WITHDRAWAL_MAX_SUM: %s", envWithdrawalMaxSum)
+		}
+
+		WithdrawalRules.MaxSum = sum
+	}
+
+	if envWithdrawalDailyLimit := os.Getenv("WITHDRAWAL_DAILY_LIMIT"); envWithdrawalDailyLimit != "" {
+		sum, err := points.Parse(envWithdrawalDailyLimit)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid WITHDRAWAL_DAILY_LIMIT: %s", envWithdrawalDailyLimit)
+		}
+
+		WithdrawalRules.DailyLimit = sum
+	}
+
+	if envWithdrawalMonthlyLimit := os.Getenv("WITHDRAWAL_MONTHLY_LIMIT"); envWithdrawalMonthlyLimit != "" {
+		sum, err := points.Parse(envWithdrawalMonthlyLimit)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid WITHDRAWAL_MONTHLY_LIMIT: %s", envWithdrawalMonthlyLimit)
+		}
+
+		WithdrawalRules.MonthlyLimit = sum
+	}
+
+	if envWithdrawalGranularity := os.Getenv("WITHDRAWAL_GRANULARITY"); envWithdrawalGranularity != "" {
+		sum, err := points.Parse(envWithdrawalGranularity)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid WITHDRAWAL_GRANULARITY: %s", envWithdrawalGranularity)
+		}
+
+		WithdrawalRules.Granularity = sum
+	}
+
+	if envWithdrawalMaxOrderShare := os.Getenv("WITHDRAWAL_MAX_ORDER_SHARE"); envWithdrawalMaxOrderShare != "" {
+		share, err := strconv.Atoi(envWithdrawalMaxOrderShare)
+		if err != nil || share < 0 || share > 100 {
+			return fmt.Errorf("invalid WITHDRAWAL_MAX_ORDER_SHARE: %s", envWithdrawalMaxOrderShare)
+		}
+
+		WithdrawalRules.MaxOrderShare = share
+	}
+
+	if envTierRules := os.Getenv("TIER_RULES"); envTierRules != "" {
+		rules, err := models.ParseTierRules(envTierRules)
+		if err != nil {
+			return fmt.Errorf("invalid TIER_RULES: %s", err)
+		}
+
+		TierRules = rules
+	}
+
+	if envTierWindow := os.Getenv("TIER_WINDOW_MONTHS"); envTierWindow != "" {
+		months, err := strconv.Atoi(envTierWindow)
+		if err != nil || months < 0 {
+			return fmt.Errorf("invalid TIER_WINDOW_MONTHS: %s", envTierWindow)
+		}
+
+		TierWindowMonths = months
+	}
+
+	if envReferrerBonus := os.Getenv("REFERRER_BONUS"); envReferrerBonus != "" {
+		sum, err := points.Parse(envReferrerBonus)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid REFERRER_BONUS: %s", envReferrerBonus)
+		}
+
+		ReferrerBonus = sum
+	}
+
+	if envRefereeBonus := os.Getenv("REFEREE_BONUS"); envRefereeBonus != "" {
+		sum, err := points.Parse(envRefereeBonus)
+		if err != nil || sum < 0 {
+			return fmt.Errorf("invalid REFEREE_BONUS: %s", envRefereeBonus)
+		}
+
+		RefereeBonus = sum
+	}
+
+	if envReferralLimit := os.Getenv("REFERRAL_LIMIT"); envReferralLimit != "" {
+		limit, err := strconv.Atoi(envReferralLimit)
+		if err != nil || limit < 0 {
+			return fmt.Errorf("invalid REFERRAL_LIMIT: %s", envReferralLimit)
+		}
+
+		ReferralLimit = limit
+	}
+
 	return nil
 }
 
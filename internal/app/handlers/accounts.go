@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/store"
@@ -11,7 +12,7 @@ import (
 	"github.com/pkg/errors"
 )
 
-// GetBalance returns user account balance.
+// GetBalance returns user account balance with held points and points which expire soon.
 func (h *Handlers) GetBalance(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
@@ -36,8 +37,30 @@ func (h *Handlers) GetBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	lots, err := h.s.ListExpiringLots(r.Context(), acc.ID, time.Now().Add(h.c.PointsExpiringSoonPeriod))
+	if err != nil {
+		h.handleError("GetBalance", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	balance := models.Balance{
+		Current:   acc.CurrentPointsTotal,
+		Withdrawn: acc.WithdrawnTotal,
+		Held:      acc.HeldTotal,
+		Debt:      acc.DebtTotal,
+		Tier:      acc.Tier,
+		Available: acc.Available(),
+	}
+	for _, lot := range lots {
+		balance.ExpiringSoon += lot.Remaining
+	}
+	if len(lots) > 0 {
+		balance.NextExpiration = &lots[0].ExpiresAt
+	}
+
 	enc := json.NewEncoder(w)
-	if err := enc.Encode(acc); err != nil {
+	if err := enc.Encode(balance); err != nil {
 		h.handleError("GetBalance", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
@@ -58,26 +81,56 @@ func (h *Handlers) WithdrawPoints(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
 	if err := dec.Decode(&request); err != nil {
 		h.handleError("WithdrawPoints", err)
-		w.WriteHeader(http.StatusBadRequest)
+		h.writeError(w, "WithdrawPoints", http.StatusBadRequest, errorResponse{Code: "invalid_request", Message: "request body is not valid JSON"})
 		return
 	}
 	if request.Order == "" || request.Sum <= 0 {
 		h.handleError("WithdrawPoints", errors.New("invalid parameters"))
-		w.WriteHeader(http.StatusBadRequest)
+		h.writeError(w, "WithdrawPoints", http.StatusBadRequest, errorResponse{Code: "invalid_request", Message: "order and positive sum are required"})
 		return
 	}
 	if !luhn.VerifyLuhn(request.Order) {
-		w.WriteHeader(http.StatusUnprocessableEntity)
+		h.writeError(w, "WithdrawPoints", http.StatusUnprocessableEntity, errorResponse{Code: "invalid_order_number", Message: "order number is invalid"})
+		return
+	}
+	if violation, limit := h.c.WithdrawalRules.Check(request.Sum, request.OrderTotal); violation != "" {
+		h.handleError("WithdrawPoints", fmt.Errorf("withdrawal of %s violates %s", request.Sum, violation))
+		h.writeError(w, "WithdrawPoints", http.StatusUnprocessableEntity, errorResponse{
+			Code:    string(violation),
+			Message: withdrawalViolationMessage(violation),
+			Limit:   limit,
+		})
 		return
 	}
 
-	acc, err := h.s.WithdrawBalance(r.Context(), userID, request.Order, request.Sum)
+	acc, err := h.s.WithdrawBalance(r.Context(), userID, request.Order, request.Sum, h.c.WithdrawalRules)
 	if err != nil {
 		h.handleError("WithdrawPoints", err)
 
+		if errors.Is(err, store.ErrAccountFrozen) {
+			h.writeError(w, "WithdrawPoints", http.StatusForbidden, errorResponse{Code: "account_frozen", Message: "account is frozen"})
+			return
+		}
+
 		var balanceErr *store.NotEnoughBalanceError
 		if errors.As(err, &balanceErr) {
-			w.WriteHeader(http.StatusPaymentRequired)
+			h.writeError(w, "WithdrawPoints", http.StatusPaymentRequired, errorResponse{Code: "insufficient_balance", Message: "not enough points available"})
+			return
+		}
+
+		var limitErr *store.WithdrawalLimitError
+		if errors.As(err, &limitErr) {
+			h.writeError(w, "WithdrawPoints", http.StatusUnprocessableEntity, errorResponse{
+				Code:    string(limitErr.Violation),
+				Message: withdrawalViolationMessage(limitErr.Violation),
+				Limit:   limitErr.Limit,
+			})
+			return
+		}
+
+		var sErr store.StoreError
+		if errors.As(err, &sErr) && sErr.IntegrityViolation() {
+			h.writeError(w, "WithdrawPoints", http.StatusConflict, errorResponse{Code: "order_already_paid", Message: "order has already been paid with points or is reserved by a hold"})
 			return
 		}
 
@@ -91,3 +144,24 @@ func (h *Handlers) WithdrawPoints(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+func withdrawalViolationMessage(violation models.WithdrawalViolation) string {
+	switch violation {
+	case models.WithdrawalViolationMinSum:
+		return "sum is less than the minimum withdrawal"
+	case models.WithdrawalViolationMaxSum:
+		return "sum is greater than the maximum withdrawal"
+	case models.WithdrawalViolationGranularity:
+		return "sum must be a multiple of the limit"
+	case models.WithdrawalViolationOrderTotal:
+		return "order total is required"
+	case models.WithdrawalViolationOrderShare:
+		return "sum exceeds the share of the order payable with points"
+	case models.WithdrawalViolationDailyLimit:
+		return "withdrawals within 24 hours would exceed the limit"
+	case models.WithdrawalViolationMonthlyLimit:
+		return "withdrawals within a month would exceed the limit"
+	default:
+		return "withdrawal is not allowed"
+	}
+}
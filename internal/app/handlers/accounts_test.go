@@ -8,12 +8,15 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store"
 	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,10 +34,11 @@ func TestGetBalanceHandler(t *testing.T) {
 
 	t.Run("positive case", func(t *testing.T) {
 		acc := models.Account{
-			CurrentPointsTotal: 500,
-			WithdrawnTotal:     1000,
+			CurrentPointsTotal: points.FromInt(500),
+			WithdrawnTotal:     points.FromInt(1000),
 		}
 		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListExpiringLots(gomock.Any(), acc.ID, gomock.Any()).Return([]models.PointsLot{}, nil)
 
 		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
 		require.NoError(t, err)
@@ -53,7 +57,40 @@ func TestGetBalanceHandler(t *testing.T) {
 		respStr, err := io.ReadAll(resp.Body)
 		require.NoError(t, err)
 
-		assert.Equal(t, `{"current":500,"withdrawn":1000}`+"\n", string(respStr), "unexpected response body")
+		assert.Equal(t, `{"current":500,"withdrawn":1000,"held":0,"available":500,"expiring_soon":0}`+"\n", string(respStr), "unexpected response body")
+	})
+
+	t.Run("points expiring soon", func(t *testing.T) {
+		acc := models.Account{
+			ID:                 uuid.NewString(),
+			CurrentPointsTotal: points.FromInt(500),
+			HeldTotal:          points.FromInt(150),
+		}
+		expiresAt := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+		lots := []models.PointsLot{
+			{Remaining: points.FromInt(100), ExpiresAt: expiresAt},
+			{Remaining: points.FromMinor(2050), ExpiresAt: expiresAt.Add(24 * time.Hour)},
+		}
+		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListExpiringLots(gomock.Any(), acc.ID, gomock.Any()).Return(lots, nil)
+
+		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+
+		r := httptest.NewRecorder()
+
+		h.GetBalance(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		assert.Equal(t, `{"current":500,"withdrawn":0,"held":150,"available":350,"expiring_soon":120.5,"next_expiration":"2024-12-01T10:00:00Z"}`+"\n", string(respStr), "unexpected response body")
 	})
 
 	t.Run("unauthorized user", func(t *testing.T) {
@@ -99,14 +136,14 @@ func TestWithdrawPointsHandler(t *testing.T) {
 
 	t.Run("positive case", func(t *testing.T) {
 		order := "1234567890003"
-		sum := float32(100)
+		sum := points.FromInt(100)
 		accAfter := models.Account{
-			CurrentPointsTotal: 400,
-			WithdrawnTotal:     1100,
+			CurrentPointsTotal: points.FromInt(400),
+			WithdrawnTotal:     points.FromInt(1100),
 		}
-		m.EXPECT().WithdrawBalance(gomock.Any(), userID, order, sum).Return(accAfter, nil)
+		m.EXPECT().WithdrawBalance(gomock.Any(), userID, order, sum, gomock.Any()).Return(accAfter, nil)
 
-		requestBody := fmt.Sprintf(`{"order":"%s","sum":%f}`, order, sum)
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
 		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
 		require.NoError(t, err)
 		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
@@ -128,10 +165,52 @@ func TestWithdrawPointsHandler(t *testing.T) {
 		assert.Equal(t, `{"current":400,"withdrawn":1100}`+"\n", string(respStr), "unexpected response body")
 	})
 
+	t.Run("order already paid", func(t *testing.T) {
+		order := "1234567890003"
+		sum := points.FromInt(100)
+		m.EXPECT().WithdrawBalance(gomock.Any(), userID, order, sum, gomock.Any()).Return(models.Account{}, &createUserError{})
+
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
+		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+
+		h.WithdrawPoints(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("account frozen", func(t *testing.T) {
+		order := "1234567890003"
+		sum := points.FromInt(100)
+		m.EXPECT().WithdrawBalance(gomock.Any(), userID, order, sum, gomock.Any()).Return(models.Account{}, fmt.Errorf("%w: account", store.ErrAccountFrozen))
+
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
+		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+
+		h.WithdrawPoints(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "unexpected response code")
+	})
+
 	t.Run("unauthorized user", func(t *testing.T) {
 		order := "1234567890003"
-		sum := float32(100)
-		requestBody := fmt.Sprintf(`{"order":"%s","sum":%f}`, order, sum)
+		sum := points.FromInt(100)
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
 		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
 		require.NoError(t, err)
 		req.Header.Set("Content-Type", "application/json")
@@ -147,8 +226,8 @@ func TestWithdrawPointsHandler(t *testing.T) {
 
 	t.Run("bad request (no order number)", func(t *testing.T) {
 		order := ""
-		sum := float32(100)
-		requestBody := fmt.Sprintf(`{"order":"%s","sum":%f}`, order, sum)
+		sum := points.FromInt(100)
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
 		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
 		require.NoError(t, err)
 		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
@@ -166,8 +245,8 @@ func TestWithdrawPointsHandler(t *testing.T) {
 
 	t.Run("bad request (invalid sum)", func(t *testing.T) {
 		order := "1234567890003"
-		sum := float32(-1)
-		requestBody := fmt.Sprintf(`{"order":"%s","sum":%f}`, order, sum)
+		sum := points.FromInt(-1)
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
 		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
 		require.NoError(t, err)
 		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
@@ -185,8 +264,8 @@ func TestWithdrawPointsHandler(t *testing.T) {
 
 	t.Run("unprocessable entity", func(t *testing.T) {
 		order := "1234567890001"
-		sum := float32(100)
-		requestBody := fmt.Sprintf(`{"order":"%s","sum":%f}`, order, sum)
+		sum := points.FromInt(100)
+		requestBody := fmt.Sprintf(`{"order":"%s","sum":%s}`, order, sum)
 		req, err := http.NewRequest(http.MethodGet, path, strings.NewReader(requestBody))
 		require.NoError(t, err)
 		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
@@ -202,3 +281,110 @@ func TestWithdrawPointsHandler(t *testing.T) {
 		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
 	})
 }
+
+func TestWithdrawPointsRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+	h.c.WithdrawalRules = models.WithdrawalRules{
+		MinSum:        points.FromInt(10),
+		MaxSum:        points.FromInt(1000),
+		DailyLimit:    points.FromInt(1500),
+		MaxOrderShare: 50,
+		Granularity:   points.FromInt(1),
+	}
+
+	userID := uuid.NewString()
+	order := "1234567890003"
+
+	send := func(requestBody string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(requestBody))
+		require.NoError(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID))
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+		h.WithdrawPoints(r, req)
+
+		return r.Result()
+	}
+
+	tests := []struct {
+		name         string
+		requestBody  string
+		expectedBody string
+	}{
+		{
+			name:         "sum below minimum",
+			requestBody:  `{"order":"1234567890003","sum":5,"order_total":100}`,
+			expectedBody: `{"code":"sum_below_minimum","message":"sum is less than the minimum withdrawal","limit":10}`,
+		},
+		{
+			name:         "sum above maximum",
+			requestBody:  `{"order":"1234567890003","sum":1001,"order_total":5000}`,
+			expectedBody: `{"code":"sum_above_maximum","message":"sum is greater than the maximum withdrawal","limit":1000}`,
+		},
+		{
+			name:         "fractional sum",
+			requestBody:  `{"order":"1234567890003","sum":10.5,"order_total":100}`,
+			expectedBody: `{"code":"invalid_sum_granularity","message":"sum must be a multiple of the limit","limit":1}`,
+		},
+		{
+			name:         "no order total",
+			requestBody:  `{"order":"1234567890003","sum":10}`,
+			expectedBody: `{"code":"order_total_required","message":"order total is required"}`,
+		},
+		{
+			name:         "order share exceeded",
+			requestBody:  `{"order":"1234567890003","sum":60,"order_total":100}`,
+			expectedBody: `{"code":"order_share_exceeded","message":"sum exceeds the share of the order payable with points","limit":50}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := send(tt.requestBody)
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+
+			respStr, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBody+"\n", string(respStr), "unexpected response body")
+		})
+	}
+
+	t.Run("daily limit exceeded", func(t *testing.T) {
+		m.EXPECT().WithdrawBalance(gomock.Any(), userID, order, points.FromInt(50), h.c.WithdrawalRules).Return(models.Account{}, &store.WithdrawalLimitError{
+			Err:       errors.New("limit"),
+			Violation: models.WithdrawalViolationDailyLimit,
+			Limit:     points.FromInt(1500),
+		})
+
+		resp := send(`{"order":"1234567890003","sum":50,"order_total":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"code":"daily_limit_exceeded","message":"withdrawals within 24 hours would exceed the limit","limit":1500}`+"\n", string(respStr))
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		m.EXPECT().WithdrawBalance(gomock.Any(), userID, order, points.FromInt(50), h.c.WithdrawalRules).Return(models.Account{}, &store.NotEnoughBalanceError{
+			Err: errors.New("not enough balance"),
+		})
+
+		resp := send(`{"order":"1234567890003","sum":50,"order_total":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusPaymentRequired, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"code":"insufficient_balance","message":"not enough points available"}`+"\n", string(respStr))
+	})
+}
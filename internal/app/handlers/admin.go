@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/pkg/errors"
+)
+
+// SubjectUser finds the user by login from the URL of admin API and passes it to the next handler.
+// Handlers of the private API called after it serve the data of this user instead of the operator's one.
+func (h *Handlers) SubjectUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login := chi.URLParam(r, "login")
+
+		user, err := h.s.GetUserByLogin(r.Context(), login)
+		if err != nil {
+			if err.Error() == "sql: no rows in result set" {
+				h.handleError("SubjectUser", fmt.Errorf("user %s not found", login))
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			h.handleError("SubjectUser", err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), subjectUserKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetUser returns the user chosen by an operator with the state of their account.
+func (h *Handlers) GetUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	user, acc, ok := h.subjectAccount(w, r, "GetUser")
+	if !ok {
+		return
+	}
+
+	h.writeUserInfo(w, "GetUser", user, acc)
+}
+
+// FreezeAccount forbids spending points of the user chosen by an operator.
+func (h *Handlers) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.setAccountFrozen(w, r, "FreezeAccount", true)
+}
+
+// UnfreezeAccount allows spending points of the user chosen by an operator again.
+func (h *Handlers) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.setAccountFrozen(w, r, "UnfreezeAccount", false)
+}
+
+// AdjustBalance credits or debits points of the user chosen by an operator. The reason code is mandatory,
+// the operator is recorded on the transaction.
+func (h *Handlers) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	var request models.AdjustmentRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&request); err != nil {
+		h.handleError("AdjustBalance", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	operatorID, _ := r.Context().Value(middleware.AuthenticatedUserKey).(string)
+	adjustment := models.Adjustment{
+		Amount:   request.Sum,
+		Reason:   request.Reason,
+		Comment:  strings.TrimSpace(request.Comment),
+		Operator: operatorID,
+	}
+	if err := adjustment.Validate(); err != nil {
+		h.handleError("AdjustBalance", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	_, acc, ok := h.subjectAccount(w, r, "AdjustBalance")
+	if !ok {
+		return
+	}
+	adjustment.AccountID = acc.ID
+
+	transaction, err := h.s.AdjustBalance(r.Context(), adjustment)
+	if err != nil {
+		h.handleError("AdjustBalance", err)
+
+		var balanceErr *store.NotEnoughBalanceError
+		if errors.As(err, &balanceErr) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.log.With("accountID", acc.ID, "operatorID", operatorID, "sum", adjustment.Amount, "reason", adjustment.Reason).
+		Info("account balance adjusted")
+
+	w.WriteHeader(http.StatusCreated)
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(transaction); err != nil {
+		h.handleError("AdjustBalance", err)
+	}
+}
+
+func (h *Handlers) setAccountFrozen(w http.ResponseWriter, r *http.Request, method string, frozen bool) {
+	w.Header().Set("content-type", "application/json")
+
+	user, acc, ok := h.subjectAccount(w, r, method)
+	if !ok {
+		return
+	}
+
+	acc, err := h.s.SetAccountFrozen(r.Context(), acc.ID, frozen)
+	if err != nil {
+		h.handleError(method, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	operatorID, _ := r.Context().Value(middleware.AuthenticatedUserKey).(string)
+	h.log.With("accountID", acc.ID, "operatorID", operatorID, "frozen", frozen).Info("account freezing changed")
+
+	h.writeUserInfo(w, method, user, acc)
+}
+
+// subjectAccount returns the user chosen by an operator with their account. It writes the error response
+// and returns false if the account can not be found.
+func (h *Handlers) subjectAccount(w http.ResponseWriter, r *http.Request, method string) (models.User, models.Account, bool) {
+	user, ok := subjectUser(r)
+	if !ok {
+		h.handleError(method, errors.New("user is not chosen"))
+		w.WriteHeader(http.StatusInternalServerError)
+		return user, models.Account{}, false
+	}
+
+	acc, err := h.s.GetAccountByUserID(r.Context(), user.ID)
+	if err != nil {
+		h.handleError(method, fmt.Errorf("account not found for user %s", user.ID))
+		w.WriteHeader(http.StatusInternalServerError)
+		return user, acc, false
+	}
+
+	return user, acc, true
+}
+
+func (h *Handlers) writeUserInfo(w http.ResponseWriter, method string, user models.User, acc models.Account) {
+	info := models.UserInfo{
+		User:      user,
+		AccountID: acc.ID,
+	}
+	if acc.Frozen() {
+		info.FrozenAt = &acc.FrozenAt
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(info); err != nil {
+		h.handleError(method, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func subjectUser(r *http.Request) (models.User, bool) {
+	user, ok := r.Context().Value(subjectUserKey).(models.User)
+	return user, ok
+}
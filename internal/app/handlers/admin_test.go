@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	user := models.User{
+		ID:        uuid.NewString(),
+		Login:     "john_doe",
+		Password:  "password_hash",
+		Role:      models.RoleUser,
+		CreatedAt: time.Date(2024, 11, 3, 17, 0, 0, 0, time.UTC),
+	}
+	acc := models.Account{ID: uuid.NewString(), UserID: user.ID, CurrentPointsTotal: points.FromInt(100)}
+	operatorID := uuid.NewString()
+
+	r := chi.NewRouter()
+	r.Route("/api/admin/users/{login}", func(r chi.Router) {
+		r.Use(h.SubjectUser)
+		r.Get("/", h.GetUser)
+		r.Get("/balance", h.GetBalance)
+		r.Post("/freeze", h.FreezeAccount)
+		r.Post("/adjustments", h.AdjustBalance)
+	})
+
+	send := func(method, path, body string) *http.Response {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, operatorID)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		return w.Result()
+	}
+
+	t.Run("get user", func(t *testing.T) {
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+
+		resp := send(http.MethodGet, "/api/admin/users/john_doe", "")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		expectedBody := fmt.Sprintf(`{"id":"%s","login":"john_doe","role":"user","created_at":"2024-11-03T17:00:00Z","account_id":"%s"}`+"\n", user.ID, acc.ID)
+		assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		m.EXPECT().GetUserByLogin(gomock.Any(), "jane_doe").Return(models.User{}, sql.ErrNoRows)
+
+		resp := send(http.MethodGet, "/api/admin/users/jane_doe/balance", "")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("balance of the user", func(t *testing.T) {
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+		m.EXPECT().ListExpiringLots(gomock.Any(), acc.ID, gomock.Any()).Return(nil, nil)
+
+		resp := send(http.MethodGet, "/api/admin/users/john_doe/balance", "")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("freeze account", func(t *testing.T) {
+		frozen := acc
+		frozen.FrozenAt = time.Date(2024, 11, 5, 14, 0, 0, 0, time.UTC)
+
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+		m.EXPECT().SetAccountFrozen(gomock.Any(), acc.ID, true).Return(frozen, nil)
+
+		resp := send(http.MethodPost, "/api/admin/users/john_doe/freeze", "")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(respStr), `"frozen_at":"2024-11-05T14:00:00Z"`)
+	})
+
+	t.Run("adjust balance", func(t *testing.T) {
+		adjustment := models.Adjustment{
+			AccountID: acc.ID,
+			Amount:    points.FromMinor(-1050),
+			Reason:    models.AdjustmentReasonCorrection,
+			Comment:   "duplicate accrual",
+			Operator:  operatorID,
+		}
+		transaction := models.Transaction{
+			Amount:    adjustment.Amount,
+			Direction: models.TxDirectionAdjustment,
+			Reason:    adjustment.Reason,
+			Comment:   adjustment.Comment,
+			Operator:  operatorID,
+			CreatedAt: time.Date(2024, 11, 5, 14, 0, 0, 0, time.UTC),
+		}
+
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+		m.EXPECT().AdjustBalance(gomock.Any(), adjustment).Return(transaction, nil)
+
+		resp := send(http.MethodPost, "/api/admin/users/john_doe/adjustments", `{"sum":-10.5,"reason":"correction","comment":" duplicate accrual "}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"sum":-10.5,"order":"","reason":"correction","comment":"duplicate accrual","processed_at":"2024-11-05T14:00:00Z"}`+"\n", string(respStr))
+	})
+
+	t.Run("invalid adjustment", func(t *testing.T) {
+		for _, body := range []string{
+			`{"sum":10,"comment":"no reason"}`,
+			`{"sum":10,"reason":"bonus"}`,
+			`{"sum":10,"reason":"other","comment":" "}`,
+			`{"sum":0,"reason":"goodwill"}`,
+		} {
+			m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+
+			resp := send(http.MethodPost, "/api/admin/users/john_doe/adjustments", body)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "unexpected response code for %s", body)
+		}
+	})
+
+	t.Run("adjustment exceeds balance", func(t *testing.T) {
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+		m.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).
+			Return(models.Transaction{}, &store.NotEnoughBalanceError{Err: fmt.Errorf("not enough balance")})
+
+		resp := send(http.MethodPost, "/api/admin/users/john_doe/adjustments", `{"sum":-200,"reason":"correction"}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusPaymentRequired, resp.StatusCode, "unexpected response code")
+	})
+}
+
+func TestSubjectUserOverridesAuthenticatedUser(t *testing.T) {
+	user := models.User{ID: uuid.NewString()}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := context.WithValue(req.Context(), subjectUserKey, user)
+
+	userID, err := ensureUserID(req.WithContext(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+}
@@ -1,12 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/madatsci/gophermart/internal/app/config"
 	"github.com/madatsci/gophermart/internal/app/server/middleware"
 	"github.com/madatsci/gophermart/internal/app/store"
 	"github.com/madatsci/gophermart/pkg/jwt"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
@@ -25,14 +27,32 @@ type (
 		JWT    *jwt.JWT
 		Logger *zap.SugaredLogger
 	}
+
+	// errorResponse tells the client why the request has been rejected with a machine-readable code.
+	errorResponse struct {
+		Code    string        `json:"code"`
+		Message string        `json:"message"`
+		Limit   points.Points `json:"limit,omitempty"`
+	}
+
+	ctxKey int
 )
 
+// subjectUserKey is used to pass the user chosen by an operator in admin API.
+const subjectUserKey ctxKey = 0
+
 // New creates new Handlers.
 func New(opts Options) *Handlers {
 	return &Handlers{c: opts.Config, s: opts.Store, jwt: opts.JWT, log: opts.Logger}
 }
 
+// ensureUserID returns ID of the user whose data is requested: the user chosen by an operator
+// in admin API or the authenticated user otherwise.
 func ensureUserID(r *http.Request) (string, error) {
+	if user, ok := subjectUser(r); ok {
+		return user.ID, nil
+	}
+
 	userIDCtx := r.Context().Value(middleware.AuthenticatedUserKey)
 	userID, ok := userIDCtx.(string)
 	if !ok {
@@ -45,3 +65,13 @@ func ensureUserID(r *http.Request) (string, error) {
 func (h *Handlers) handleError(method string, err error) {
 	h.log.With("method", method, "err", err).Errorln("error handling request")
 }
+
+// writeError responds with the status and the reason of the rejection.
+func (h *Handlers) writeError(w http.ResponseWriter, method string, status int, resp errorResponse) {
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(resp); err != nil {
+		h.handleError(method, err)
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/pkg/luhn"
+	"github.com/pkg/errors"
+)
+
+// CreateHold reserves points of the authorized user for the order.
+func (h *Handlers) CreateHold(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("CreateHold", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var request models.HoldRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&request); err != nil {
+		h.handleError("CreateHold", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if request.Order == "" || request.Sum <= 0 {
+		h.handleError("CreateHold", errors.New("invalid parameters"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !luhn.VerifyLuhn(request.Order) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	// a captured hold is a withdrawal, so it is subject to the same rules
+	if violation, limit := h.c.WithdrawalRules.Check(request.Sum, request.OrderTotal); violation != "" {
+		h.handleError("CreateHold", fmt.Errorf("hold of %s violates %s", request.Sum, violation))
+		h.writeError(w, "CreateHold", http.StatusUnprocessableEntity, errorResponse{
+			Code:    string(violation),
+			Message: withdrawalViolationMessage(violation),
+			Limit:   limit,
+		})
+		return
+	}
+
+	hold, err := h.s.CreateHold(r.Context(), userID, request.Order, request.Sum, time.Now().Add(h.c.HoldTTL), h.c.WithdrawalRules)
+	if err != nil {
+		h.handleError("CreateHold", err)
+
+		if errors.Is(err, store.ErrAccountFrozen) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var limitErr *store.WithdrawalLimitError
+		if errors.As(err, &limitErr) {
+			h.writeError(w, "CreateHold", http.StatusUnprocessableEntity, errorResponse{
+				Code:    string(limitErr.Violation),
+				Message: withdrawalViolationMessage(limitErr.Violation),
+				Limit:   limitErr.Limit,
+			})
+			return
+		}
+
+		var balanceErr *store.NotEnoughBalanceError
+		if errors.As(err, &balanceErr) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			return
+		}
+
+		var sErr store.StoreError
+		if errors.As(err, &sErr) && sErr.IntegrityViolation() {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(hold); err != nil {
+		h.handleError("CreateHold", err)
+	}
+}
+
+// CaptureHold withdraws points reserved by the hold.
+func (h *Handlers) CaptureHold(w http.ResponseWriter, r *http.Request) {
+	h.releaseHold(w, r, "CaptureHold", h.s.CaptureHold)
+}
+
+// VoidHold releases points reserved by the hold.
+func (h *Handlers) VoidHold(w http.ResponseWriter, r *http.Request) {
+	h.releaseHold(w, r, "VoidHold", h.s.VoidHold)
+}
+
+func (h *Handlers) releaseHold(
+	w http.ResponseWriter,
+	r *http.Request,
+	method string,
+	release func(ctx context.Context, userID string, holdID string) (models.Hold, error),
+) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError(method, err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	hold, err := release(r.Context(), userID, chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleError(method, err)
+
+		if err.Error() == "sql: no rows in result set" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, store.ErrHoldNotActive) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		var balanceErr *store.NotEnoughBalanceError
+		if errors.As(err, &balanceErr) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(hold); err != nil {
+		h.handleError(method, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateHoldHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	path := "/api/user/balance/holds"
+	userID := uuid.NewString()
+
+	send := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+		h.CreateHold(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("positive case", func(t *testing.T) {
+		hold := models.Hold{
+			ID:          uuid.NewString(),
+			OrderNumber: "2377225624",
+			Amount:      points.FromInt(100),
+			Status:      models.HoldStatusActive,
+			ExpiresAt:   time.Date(2024, 11, 5, 14, 15, 0, 0, time.UTC),
+			CreatedAt:   time.Date(2024, 11, 5, 14, 0, 0, 0, time.UTC),
+		}
+		m.EXPECT().CreateHold(gomock.Any(), userID, "2377225624", points.FromInt(100), gomock.Any(), gomock.Any()).Return(hold, nil)
+
+		resp := send(`{"order":"2377225624","sum":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		expectedBody := fmt.Sprintf(`{"id":"%s","order":"2377225624","sum":100,"status":"active","expires_at":"2024-11-05T14:15:00Z","created_at":"2024-11-05T14:00:00Z"}`+"\n", hold.ID)
+		assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		resp := send(`{"order":"2377225624","sum":0}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("invalid order number", func(t *testing.T) {
+		resp := send(`{"order":"123123","sum":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		m.EXPECT().CreateHold(gomock.Any(), userID, "2377225624", points.FromInt(100), gomock.Any(), gomock.Any()).Return(models.Hold{}, &store.NotEnoughBalanceError{Err: fmt.Errorf("not enough balance")})
+
+		resp := send(`{"order":"2377225624","sum":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusPaymentRequired, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("order already reserved", func(t *testing.T) {
+		m.EXPECT().CreateHold(gomock.Any(), userID, "2377225624", points.FromInt(100), gomock.Any(), gomock.Any()).Return(models.Hold{}, &createUserError{})
+
+		resp := send(`{"order":"2377225624","sum":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "unexpected response code")
+	})
+}
+
+func TestCreateHoldRules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+	h.c.WithdrawalRules = models.WithdrawalRules{MinSum: points.FromInt(10), DailyLimit: points.FromInt(500)}
+
+	userID := uuid.NewString()
+
+	send := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, "/api/user/balance/holds", strings.NewReader(body))
+		require.NoError(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID))
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+		h.CreateHold(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("sum below minimum", func(t *testing.T) {
+		resp := send(`{"order":"2377225624","sum":5}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"code":"sum_below_minimum","message":"sum is less than the minimum withdrawal","limit":10}`, string(respStr))
+	})
+
+	t.Run("daily limit exceeded", func(t *testing.T) {
+		m.EXPECT().CreateHold(gomock.Any(), userID, "2377225624", points.FromInt(100), gomock.Any(), h.c.WithdrawalRules).Return(models.Hold{}, &store.WithdrawalLimitError{
+			Err:       fmt.Errorf("daily limit exceeded"),
+			Violation: models.WithdrawalViolationDailyLimit,
+			Limit:     points.FromInt(500),
+		})
+
+		resp := send(`{"order":"2377225624","sum":100}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"code":"daily_limit_exceeded","message":"withdrawals within 24 hours would exceed the limit","limit":500}`, string(respStr))
+	})
+}
+
+func TestReleaseHoldHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	userID := uuid.NewString()
+	holdID := uuid.NewString()
+
+	send := func(handler http.HandlerFunc, action string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, "/api/user/balance/holds/"+holdID+"/"+action, http.NoBody)
+		require.NoError(t, err)
+
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", holdID)
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		ctx = context.WithValue(ctx, middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+
+		r := httptest.NewRecorder()
+		handler(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("capture", func(t *testing.T) {
+		hold := models.Hold{ID: holdID, Status: models.HoldStatusCaptured}
+		m.EXPECT().CaptureHold(gomock.Any(), userID, holdID).Return(hold, nil)
+
+		resp := send(h.CaptureHold, "capture")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(respStr), `"status":"captured"`)
+	})
+
+	t.Run("void", func(t *testing.T) {
+		hold := models.Hold{ID: holdID, Status: models.HoldStatusVoided}
+		m.EXPECT().VoidHold(gomock.Any(), userID, holdID).Return(hold, nil)
+
+		resp := send(h.VoidHold, "void")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("hold not found", func(t *testing.T) {
+		m.EXPECT().CaptureHold(gomock.Any(), userID, holdID).Return(models.Hold{}, sql.ErrNoRows)
+
+		resp := send(h.CaptureHold, "capture")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("hold is not active", func(t *testing.T) {
+		m.EXPECT().VoidHold(gomock.Any(), userID, holdID).Return(models.Hold{}, store.ErrHoldNotActive)
+
+		resp := send(h.VoidHold, "void")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "unexpected response code")
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,8 +16,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-const listOrdersLimit = 100
-
 // CreateOrder registers a new order number.
 func (h *Handlers) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	userID, err := ensureUserID(r)
@@ -93,7 +93,8 @@ func (h *Handlers) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 }
 
-// GetOrders returns all orders created by the authorized user.
+// GetOrders returns a page of orders created by the authorized user.
+// Supports limit, cursor, sort, status, from and to query parameters.
 func (h *Handlers) GetOrders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
@@ -104,6 +105,13 @@ func (h *Handlers) GetOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		h.handleError("GetOrders", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
 	acc, err := h.s.GetAccountByUserID(r.Context(), userID)
 	if err != nil {
 		h.handleError("GetOrders", fmt.Errorf("account not found for user %s", userID))
@@ -111,7 +119,10 @@ func (h *Handlers) GetOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	orders, err := h.s.ListOrdersByAccountID(r.Context(), acc.ID, listOrdersLimit)
+	limit := filter.Limit
+	filter.Limit++
+
+	orders, err := h.s.ListOrders(r.Context(), acc.ID, filter)
 	if err != nil {
 		h.handleError("GetOrders", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -122,9 +133,38 @@ func (h *Handlers) GetOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	orders = setNextCursor(w, orders, limit, func(o models.Order) models.Cursor {
+		return models.Cursor{CreatedAt: o.CreatedAt, ID: o.ID}
+	})
+
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(orders); err != nil {
 		h.handleError("GetOrders", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+func parseOrderFilter(q url.Values) (models.OrderFilter, error) {
+	var filter models.OrderFilter
+
+	page, err := parsePage(q)
+	if err != nil {
+		return filter, err
+	}
+	filter.Page = page
+
+	filter.From, filter.To, err = parseTimeRange(q)
+	if err != nil {
+		return filter, err
+	}
+
+	for _, v := range splitQueryValues(q, "status") {
+		status := models.OrderStatus(strings.ToUpper(v))
+		if !status.Valid() {
+			return filter, fmt.Errorf("invalid status: %s", v)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	return filter, nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/server/middleware"
 	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -44,8 +45,8 @@ func TestCreateOrderHandler(t *testing.T) {
 		order := "1234567890003"
 		acc := models.Account{
 			UserID:             userID,
-			CurrentPointsTotal: 500,
-			WithdrawnTotal:     1000,
+			CurrentPointsTotal: points.FromInt(500),
+			WithdrawnTotal:     points.FromInt(1000),
 		}
 		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
 		m.EXPECT().CreateOrder(gomock.Any(), gomock.Any()).Return(nil)
@@ -118,8 +119,8 @@ func TestCreateOrderHandler(t *testing.T) {
 		order := "1234567890003"
 		acc := models.Account{
 			UserID:             userID,
-			CurrentPointsTotal: 500,
-			WithdrawnTotal:     1000,
+			CurrentPointsTotal: points.FromInt(500),
+			WithdrawnTotal:     points.FromInt(1000),
 		}
 		existingOrder := models.Order{
 			Number: order,
@@ -150,8 +151,8 @@ func TestCreateOrderHandler(t *testing.T) {
 		order := "1234567890003"
 		acc := models.Account{
 			UserID:             userID,
-			CurrentPointsTotal: 500,
-			WithdrawnTotal:     1000,
+			CurrentPointsTotal: points.FromInt(500),
+			WithdrawnTotal:     points.FromInt(1000),
 		}
 		existingOrder := models.Order{
 			Number: order,
@@ -179,6 +180,10 @@ func TestCreateOrderHandler(t *testing.T) {
 	})
 }
 
+var defaultOrderFilter = models.OrderFilter{
+	Page: models.Page{Limit: defaultListLimit + 1, Order: models.SortOrderDesc},
+}
+
 func TestGetOrdersHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -220,13 +225,13 @@ func TestGetOrdersHandler(t *testing.T) {
 			{
 				Number:    "4444",
 				Status:    models.OrderStatusProcessed,
-				Accrual:   100.5,
+				Accrual:   points.FromMinor(10050),
 				AccountID: acc.ID,
 				CreatedAt: createdAt,
 			},
 		}
 		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
-		m.EXPECT().ListOrdersByAccountID(gomock.Any(), acc.ID, listOrdersLimit).Return(orders, nil)
+		m.EXPECT().ListOrders(gomock.Any(), acc.ID, defaultOrderFilter).Return(orders, nil)
 
 		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
 		require.NoError(t, err)
@@ -288,7 +293,7 @@ func TestGetOrdersHandler(t *testing.T) {
 		}
 		orders := []models.Order{}
 		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
-		m.EXPECT().ListOrdersByAccountID(gomock.Any(), acc.ID, listOrdersLimit).Return(orders, nil)
+		m.EXPECT().ListOrders(gomock.Any(), acc.ID, defaultOrderFilter).Return(orders, nil)
 
 		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
 		require.NoError(t, err)
@@ -309,4 +314,63 @@ func TestGetOrdersHandler(t *testing.T) {
 
 		assert.Equal(t, "", string(respStr), "unexpected response body")
 	})
+	t.Run("next page", func(t *testing.T) {
+		acc := models.Account{
+			ID:     uuid.NewString(),
+			UserID: userID,
+		}
+		createdAt := time.Now().UTC()
+		orders := []models.Order{
+			{ID: uuid.NewString(), Number: "1111", Status: models.OrderStatusProcessed, AccountID: acc.ID, CreatedAt: createdAt},
+			{ID: uuid.NewString(), Number: "2222", Status: models.OrderStatusProcessed, AccountID: acc.ID, CreatedAt: createdAt.Add(-time.Second)},
+		}
+		from := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+		filter := models.OrderFilter{
+			Page:     models.Page{Limit: 2, Order: models.SortOrderDesc},
+			Statuses: []models.OrderStatus{models.OrderStatusProcessed},
+			From:     from,
+		}
+		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListOrders(gomock.Any(), acc.ID, filter).Return(orders, nil)
+
+		req, err := http.NewRequest(http.MethodGet, path+"?limit=1&status=processed&from=2024-11-01", http.NoBody)
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+
+		r := httptest.NewRecorder()
+
+		h.GetOrders(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(respStr), `"number":"1111"`)
+		assert.NotContains(t, string(respStr), `"number":"2222"`)
+
+		cursor, err := decodeCursor(resp.Header.Get(NextCursorHeader))
+		require.NoError(t, err)
+		assert.Equal(t, orders[0].ID, cursor.ID)
+		assert.True(t, orders[0].CreatedAt.Equal(cursor.CreatedAt))
+	})
+
+	t.Run("invalid query parameters", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=abc", "cursor=abc", "sort=up", "status=UNKNOWN", "from=yesterday", "from=2024-11-02&to=2024-11-01"} {
+			req, err := http.NewRequest(http.MethodGet, path+"?"+query, http.NoBody)
+			require.NoError(t, err)
+			ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+			req = req.WithContext(ctx)
+
+			r := httptest.NewRecorder()
+
+			h.GetOrders(r, req)
+			resp := r.Result()
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "unexpected response code for %s", query)
+		}
+	})
 }
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+
+	// NextCursorHeader contains the cursor of the next page if there are more items.
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// parsePage parses limit, cursor and sort query parameters.
+func parsePage(q url.Values) (models.Page, error) {
+	page := models.Page{
+		Limit: defaultListLimit,
+		Order: models.SortOrderDesc,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return page, fmt.Errorf("invalid limit: %s", v)
+		}
+		page.Limit = limit
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return page, err
+		}
+		page.After = &c
+	}
+
+	if v := q.Get("sort"); v != "" {
+		order := models.SortOrder(strings.ToLower(v))
+		if order != models.SortOrderAsc && order != models.SortOrderDesc {
+			return page, fmt.Errorf("invalid sort: %s", v)
+		}
+		page.Order = order
+	}
+
+	return page, nil
+}
+
+// parseTimeRange parses from (inclusive) and to (exclusive) query parameters
+// in RFC 3339 or YYYY-MM-DD format.
+func parseTimeRange(q url.Values) (time.Time, time.Time, error) {
+	from, err := parseTime(q.Get("from"))
+	if err != nil {
+		return from, time.Time{}, errors.Wrap(err, "invalid from")
+	}
+
+	to, err := parseTime(q.Get("to"))
+	if err != nil {
+		return from, to, errors.Wrap(err, "invalid to")
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, v)
+}
+
+// splitQueryValues supports both repeated and comma-separated query parameters.
+func splitQueryValues(q url.Values, key string) []string {
+	var result []string
+	for _, v := range q[key] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+
+	return result
+}
+
+// setNextCursor trims the extra item fetched to detect the next page and sets the next page cursor header.
+func setNextCursor[T any](w http.ResponseWriter, items []T, limit int, key func(T) models.Cursor) []T {
+	if len(items) <= limit {
+		return items
+	}
+
+	items = items[:limit]
+	w.Header().Set(NextCursorHeader, encodeCursor(key(items[limit-1])))
+
+	return items
+}
+
+func encodeCursor(c models.Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (models.Cursor, error) {
+	var c models.Cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err = json.Unmarshal(b, &c); err != nil || c.ID == "" || c.CreatedAt.IsZero() {
+		return c, errors.New("invalid cursor")
+	}
+
+	return c, nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/pkg/errors"
+)
+
+// RedeemPromoCode credits points of the promo code to the authorized user.
+func (h *Handlers) RedeemPromoCode(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("RedeemPromoCode", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var request models.PromoRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&request); err != nil {
+		h.handleError("RedeemPromoCode", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code := strings.ToUpper(strings.TrimSpace(request.Code))
+	if code == "" {
+		h.handleError("RedeemPromoCode", errors.New("empty promo code"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	redemption, err := h.s.RedeemPromoCode(r.Context(), userID, code)
+	if err != nil {
+		h.handleError("RedeemPromoCode", err)
+
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.Is(err, store.ErrPromoCodeRedeemed):
+			w.WriteHeader(http.StatusConflict)
+		case errors.Is(err, store.ErrPromoCodeInactive), errors.Is(err, store.ErrPromoCodeExhausted):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	h.log.With("userID", userID, "code", redemption.Code, "sum", redemption.Amount).Info("promo code redeemed")
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(redemption); err != nil {
+		h.handleError("RedeemPromoCode", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
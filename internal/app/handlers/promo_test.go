@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedeemPromoCodeHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	path := "/api/user/promo"
+	userID := uuid.NewString()
+
+	send := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+		h.RedeemPromoCode(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("positive case", func(t *testing.T) {
+		m.EXPECT().RedeemPromoCode(gomock.Any(), userID, "WELCOME").Return(models.PromoRedemption{
+			Code:      "WELCOME",
+			Amount:    points.FromInt(100),
+			CreatedAt: time.Date(2024, 11, 24, 10, 0, 0, 0, time.UTC),
+		}, nil)
+
+		resp := send(`{"code":" welcome "}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"code":"WELCOME","sum":100,"processed_at":"2024-11-24T10:00:00Z"}`+"\n", string(respStr), "unexpected response body")
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, body := range []string{`{"code":""}`, `{`} {
+			resp := send(body)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "unexpected response code for %s", body)
+		}
+	})
+
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{name: "unknown code", err: sql.ErrNoRows, code: http.StatusNotFound},
+		{name: "already redeemed", err: fmt.Errorf("%w: WELCOME", store.ErrPromoCodeRedeemed), code: http.StatusConflict},
+		{name: "inactive", err: fmt.Errorf("%w: WELCOME", store.ErrPromoCodeInactive), code: http.StatusUnprocessableEntity},
+		{name: "exhausted", err: fmt.Errorf("%w: WELCOME", store.ErrPromoCodeExhausted), code: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.EXPECT().RedeemPromoCode(gomock.Any(), userID, "WELCOME").Return(models.PromoRedemption{}, tt.err)
+
+			resp := send(`{"code":"WELCOME"}`)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.code, resp.StatusCode, "unexpected response code")
+		})
+	}
+}
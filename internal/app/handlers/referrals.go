@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetReferrals returns referral code of the authorized user with invited users and bonuses earned for them.
+func (h *Handlers) GetReferrals(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("GetReferrals", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	summary, err := h.s.GetReferralSummary(r.Context(), userID)
+	if err != nil {
+		h.handleError("GetReferrals", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(summary); err != nil {
+		h.handleError("GetReferrals", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
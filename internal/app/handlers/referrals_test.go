@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetReferralsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	userID := uuid.NewString()
+	rewardedAt := time.Date(2024, 11, 23, 12, 0, 0, 0, time.UTC)
+
+	req, err := http.NewRequest(http.MethodGet, "/api/user/referrals", nil)
+	require.NoError(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID))
+
+	m.EXPECT().GetReferralSummary(gomock.Any(), userID).Return(models.ReferralSummary{
+		Code:   "ABCD2345",
+		Earned: points.FromInt(100),
+		Referrals: []models.Referral{
+			{
+				Login:     "jane_doe",
+				Status:    models.ReferralStatusPending,
+				CreatedAt: time.Date(2024, 11, 23, 11, 0, 0, 0, time.UTC),
+			},
+			{
+				Login:         "john_doe",
+				Status:        models.ReferralStatusRewarded,
+				ReferrerBonus: points.FromInt(100),
+				RefereeBonus:  points.FromInt(50),
+				CreatedAt:     time.Date(2024, 11, 23, 10, 0, 0, 0, time.UTC),
+				RewardedAt:    &rewardedAt,
+			},
+		},
+	}, nil)
+
+	r := httptest.NewRecorder()
+	h.GetReferrals(r, req)
+
+	resp := r.Result()
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+	respStr, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	expectedBody := `{"code":"ABCD2345","earned":100,"referrals":[` +
+		`{"status":"pending","bonus":0,"registered_at":"2024-11-23T11:00:00Z","login":"jane_doe"},` +
+		`{"status":"rewarded","bonus":100,"registered_at":"2024-11-23T10:00:00Z","rewarded_at":"2024-11-23T12:00:00Z","login":"john_doe"}]}` + "\n"
+	assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetTier returns the membership tier of the authorized user and the progress to the next tier.
+func (h *Handlers) GetTier(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("GetTier", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	acc, err := h.s.GetAccountByUserID(r.Context(), userID)
+	if err != nil {
+		h.handleError("GetTier", fmt.Errorf("account not found for user %s", userID))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	status, err := h.s.RefreshTier(r.Context(), acc.ID)
+	if err != nil {
+		h.handleError("GetTier", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(status); err != nil {
+		h.handleError("GetTier", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetTierHistory returns tier changes of the authorized user, the latest first.
+func (h *Handlers) GetTierHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("GetTierHistory", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	acc, err := h.s.GetAccountByUserID(r.Context(), userID)
+	if err != nil {
+		h.handleError("GetTierHistory", fmt.Errorf("account not found for user %s", userID))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	changes, err := h.s.ListTierChanges(r.Context(), acc.ID)
+	if err != nil {
+		h.handleError("GetTierHistory", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(changes) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(changes); err != nil {
+		h.handleError("GetTierHistory", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTierHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	userID := uuid.NewString()
+	acc := models.Account{ID: uuid.NewString(), UserID: userID}
+
+	req, err := http.NewRequest(http.MethodGet, "/api/user/tier", nil)
+	require.NoError(t, err)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID))
+
+	m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+	m.EXPECT().RefreshTier(gomock.Any(), acc.ID).Return(models.TierStatus{
+		Tier:          models.TierSilver,
+		Multiplier:    points.FromMinor(125),
+		Qualifying:    points.FromInt(2000),
+		NextTier:      models.TierGold,
+		NextThreshold: points.FromInt(5000),
+		Remaining:     points.FromInt(3000),
+		Progress:      25,
+	}, nil)
+
+	r := httptest.NewRecorder()
+	h.GetTier(r, req)
+
+	resp := r.Result()
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+	respStr, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	expectedBody := `{"tier":"silver","multiplier":1.25,"qualifying":2000,"next_tier":"gold","next_threshold":5000,"remaining":3000,"progress":25}` + "\n"
+	assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+}
+
+func TestGetTierHistoryHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	userID := uuid.NewString()
+	acc := models.Account{ID: uuid.NewString(), UserID: userID}
+
+	send := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, "/api/user/tier/history", nil)
+		require.NoError(t, err)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID))
+
+		r := httptest.NewRecorder()
+		h.GetTierHistory(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("positive case", func(t *testing.T) {
+		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListTierChanges(gomock.Any(), acc.ID).Return([]models.TierChange{
+			{
+				AccountID:  acc.ID,
+				FromTier:   models.TierBronze,
+				ToTier:     models.TierSilver,
+				Qualifying: points.FromInt(1200),
+				CreatedAt:  time.Date(2024, 11, 22, 10, 0, 0, 0, time.UTC),
+			},
+		}, nil)
+
+		resp := send()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		expectedBody := `[{"from":"bronze","to":"silver","qualifying":1200,"changed_at":"2024-11-22T10:00:00Z"}]` + "\n"
+		assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListTierChanges(gomock.Any(), acc.ID).Return(nil, nil)
+
+		resp := send()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode, "unexpected response code")
+	})
+}
@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/madatsci/gophermart/internal/app/models"
 )
 
-const listWithdrawalsLimit = 100
-
+// GetWithdrawals returns a page of withdrawals of the authorized user.
+// Supports limit, cursor, sort, from and to query parameters.
 func (h *Handlers) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("content-type", "application/json")
 
@@ -20,6 +22,14 @@ func (h *Handlers) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter, err := parseTransactionFilter(r.URL.Query())
+	if err != nil {
+		h.handleError("GetWithdrawals", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	filter.Directions = []models.TxDirection{models.TxDirectionWithdrawal}
+
 	acc, err := h.s.GetAccountByUserID(r.Context(), userID)
 	if err != nil {
 		h.handleError("GetWithdrawals", fmt.Errorf("account not found for user %s", userID))
@@ -27,7 +37,10 @@ func (h *Handlers) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	txs, err := h.s.GetWithdrawals(r.Context(), acc.ID, models.TxDirectionWithdrawal, listWithdrawalsLimit)
+	limit := filter.Limit
+	filter.Limit++
+
+	txs, err := h.s.ListTransactions(r.Context(), acc.ID, filter)
 	if err != nil {
 		h.handleError("GetWithdrawals", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -38,9 +51,90 @@ func (h *Handlers) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	txs = setNextCursor(w, txs, limit, transactionCursor)
+
 	enc := json.NewEncoder(w)
 	if err := enc.Encode(txs); err != nil {
 		h.handleError("GetWithdrawals", err)
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+// GetTransactions returns a page of all transactions of the authorized user with balance after each of them.
+// Supports limit, cursor, sort, direction, from and to query parameters.
+func (h *Handlers) GetTransactions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("GetTransactions", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	filter, err := parseTransactionFilter(q)
+	if err != nil {
+		h.handleError("GetTransactions", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, v := range splitQueryValues(q, "direction") {
+		direction := models.TxDirection(strings.ToLower(v))
+		if !direction.Valid() {
+			h.handleError("GetTransactions", fmt.Errorf("invalid direction: %s", v))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		filter.Directions = append(filter.Directions, direction)
+	}
+
+	acc, err := h.s.GetAccountByUserID(r.Context(), userID)
+	if err != nil {
+		h.handleError("GetTransactions", fmt.Errorf("account not found for user %s", userID))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	limit := filter.Limit
+	filter.Limit++
+
+	entries, err := h.s.ListStatement(r.Context(), acc.ID, filter)
+	if err != nil {
+		h.handleError("GetTransactions", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if len(entries) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	entries = setNextCursor(w, entries, limit, func(e models.StatementEntry) models.Cursor {
+		return models.Cursor{CreatedAt: e.CreatedAt, ID: e.ID}
+	})
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(entries); err != nil {
+		h.handleError("GetTransactions", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func parseTransactionFilter(q url.Values) (models.TransactionFilter, error) {
+	var filter models.TransactionFilter
+
+	page, err := parsePage(q)
+	if err != nil {
+		return filter, err
+	}
+	filter.Page = page
+
+	filter.From, filter.To, err = parseTimeRange(q)
+
+	return filter, err
+}
+
+func transactionCursor(tx models.Transaction) models.Cursor {
+	return models.Cursor{CreatedAt: tx.CreatedAt, ID: tx.ID}
+}
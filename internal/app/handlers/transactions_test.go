@@ -14,11 +14,17 @@ import (
 	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/server/middleware"
 	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+var withdrawalsFilter = models.TransactionFilter{
+	Page:       models.Page{Limit: defaultListLimit + 1, Order: models.SortOrderDesc},
+	Directions: []models.TxDirection{models.TxDirectionWithdrawal},
+}
+
 func TestGetWithdrawalsHandler(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -37,7 +43,7 @@ func TestGetWithdrawalsHandler(t *testing.T) {
 		createdAt := time.Now()
 		txs := []models.Transaction{
 			{
-				Amount:      100,
+				Amount:      points.FromInt(100),
 				OrderNumber: "1111",
 				Direction:   models.TxDirectionWithdrawal,
 				AccountID:   acc.ID,
@@ -52,7 +58,7 @@ func TestGetWithdrawalsHandler(t *testing.T) {
 			},
 		}
 		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
-		m.EXPECT().GetWithdrawals(gomock.Any(), acc.ID, models.TxDirectionWithdrawal, listWithdrawalsLimit).Return(txs, nil)
+		m.EXPECT().ListTransactions(gomock.Any(), acc.ID, withdrawalsFilter).Return(txs, nil)
 
 		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
 		require.NoError(t, err)
@@ -114,7 +120,7 @@ func TestGetWithdrawalsHandler(t *testing.T) {
 		}
 		txs := []models.Transaction{}
 		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
-		m.EXPECT().GetWithdrawals(gomock.Any(), acc.ID, models.TxDirectionWithdrawal, listWithdrawalsLimit).Return(txs, nil)
+		m.EXPECT().ListTransactions(gomock.Any(), acc.ID, withdrawalsFilter).Return(txs, nil)
 
 		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
 		require.NoError(t, err)
@@ -136,3 +142,120 @@ func TestGetWithdrawalsHandler(t *testing.T) {
 		assert.Equal(t, "", string(respStr), "unexpected response body")
 	})
 }
+
+func TestGetTransactionsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+
+	path := "/api/user/transactions"
+	userID := uuid.NewString()
+
+	t.Run("positive case", func(t *testing.T) {
+		acc := models.Account{
+			ID:     uuid.NewString(),
+			UserID: userID,
+		}
+		createdAt := time.Now()
+		entries := []models.StatementEntry{
+			{
+				ID:           uuid.NewString(),
+				Direction:    models.TxDirectionWithdrawal,
+				OrderNumber:  "2222",
+				Amount:       points.FromInt(100),
+				BalanceAfter: points.FromMinor(40050),
+				CreatedAt:    createdAt,
+			},
+			{
+				ID:           uuid.NewString(),
+				Direction:    models.TxDirectionAccrual,
+				OrderNumber:  "1111",
+				Amount:       points.FromMinor(50050),
+				BalanceAfter: points.FromMinor(50050),
+				CreatedAt:    createdAt,
+			},
+		}
+		filter := models.TransactionFilter{
+			Page: models.Page{Limit: defaultListLimit + 1, Order: models.SortOrderDesc},
+		}
+		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListStatement(gomock.Any(), acc.ID, filter).Return(entries, nil)
+
+		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+
+		r := httptest.NewRecorder()
+
+		h.GetTransactions(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+		assert.Empty(t, resp.Header.Get(NextCursorHeader))
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		ft := createdAt.Format(time.RFC3339Nano)
+		expectedBody := fmt.Sprintf(`[{"direction":"withdrawal","order":"2222","sum":100,"balance_after":400.5,"processed_at":"%s"},{"direction":"accrual","order":"1111","sum":500.5,"balance_after":500.5,"processed_at":"%s"}]`+"\n", ft, ft)
+		assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+	})
+
+	t.Run("direction filter", func(t *testing.T) {
+		acc := models.Account{
+			ID:     uuid.NewString(),
+			UserID: userID,
+		}
+		filter := models.TransactionFilter{
+			Page:       models.Page{Limit: 11, Order: models.SortOrderAsc},
+			Directions: []models.TxDirection{models.TxDirectionAccrual},
+		}
+		m.EXPECT().GetAccountByUserID(gomock.Any(), userID).Return(acc, nil)
+		m.EXPECT().ListStatement(gomock.Any(), acc.ID, filter).Return([]models.StatementEntry{}, nil)
+
+		req, err := http.NewRequest(http.MethodGet, path+"?direction=accrual&sort=asc&limit=10", http.NoBody)
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+
+		r := httptest.NewRecorder()
+
+		h.GetTransactions(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("invalid direction", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, path+"?direction=bonus", http.NoBody)
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+
+		r := httptest.NewRecorder()
+
+		h.GetTransactions(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "unexpected response code")
+	})
+
+	t.Run("unauthorized user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, path, http.NoBody)
+		require.NoError(t, err)
+
+		r := httptest.NewRecorder()
+
+		h.GetTransactions(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "unexpected response code")
+	})
+}
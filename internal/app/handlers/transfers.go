@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/pkg/errors"
+)
+
+// TransferPoints sends points of the authorized user to another user identified by login.
+func (h *Handlers) TransferPoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("content-type", "application/json")
+
+	userID, err := ensureUserID(r)
+	if err != nil {
+		h.handleError("TransferPoints", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var request models.TransferRequest
+	dec := json.NewDecoder(r.Body)
+	if err := dec.Decode(&request); err != nil {
+		h.handleError("TransferPoints", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	request.Recipient = strings.TrimSpace(request.Recipient)
+	if request.Recipient == "" || request.Sum <= 0 {
+		h.handleError("TransferPoints", errors.New("invalid parameters"))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if request.Sum < h.c.TransferMinSum {
+		h.handleError("TransferPoints", fmt.Errorf("transfer of %s is less than minimum of %s", request.Sum, h.c.TransferMinSum))
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+
+	transfer, err := h.s.TransferPoints(r.Context(), userID, request.Recipient, request.Sum, h.c.TransferDailyLimit)
+	if err != nil {
+		h.handleError("TransferPoints", err)
+
+		switch {
+		case errors.Is(err, store.ErrRecipientNotFound):
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case errors.Is(err, store.ErrSelfTransfer):
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		case errors.Is(err, store.ErrAccountFrozen):
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var balanceErr *store.NotEnoughBalanceError
+		if errors.As(err, &balanceErr) {
+			w.WriteHeader(http.StatusPaymentRequired)
+			return
+		}
+
+		var limitErr *store.TransferLimitError
+		if errors.As(err, &limitErr) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	h.log.With("userID", userID, "recipient", transfer.Recipient, "sum", transfer.Amount).Info("points transferred")
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(transfer); err != nil {
+		h.handleError("TransferPoints", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
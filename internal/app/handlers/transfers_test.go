@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/server/middleware"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransferPointsHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	h := newTestHandlers(m)
+	h.c.TransferMinSum = points.FromInt(5)
+	h.c.TransferDailyLimit = points.FromInt(500)
+
+	path := "/api/user/balance/transfer"
+	userID := uuid.NewString()
+
+	send := func(body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		require.NoError(t, err)
+		ctx := context.WithValue(req.Context(), middleware.AuthenticatedUserKey, userID)
+		req = req.WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+		h.TransferPoints(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("positive case", func(t *testing.T) {
+		transfer := models.Transfer{
+			ID:        uuid.NewString(),
+			Recipient: "jane_doe",
+			Amount:    points.FromMinor(1050),
+			CreatedAt: time.Date(2024, 11, 21, 10, 0, 0, 0, time.UTC),
+		}
+		m.EXPECT().TransferPoints(gomock.Any(), userID, "jane_doe", points.FromMinor(1050), points.FromInt(500)).Return(transfer, nil)
+
+		resp := send(`{"recipient":"jane_doe","sum":10.5}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		respStr, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		expectedBody := fmt.Sprintf(`{"id":"%s","recipient":"jane_doe","sum":10.5,"processed_at":"2024-11-21T10:00:00Z"}`+"\n", transfer.ID)
+		assert.Equal(t, expectedBody, string(respStr), "unexpected response body")
+	})
+
+	t.Run("bad request", func(t *testing.T) {
+		for _, body := range []string{`{"recipient":"","sum":10}`, `{"recipient":"jane_doe","sum":-10}`, `{`} {
+			resp := send(body)
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "unexpected response code for %s", body)
+		}
+	})
+
+	t.Run("less than minimum", func(t *testing.T) {
+		resp := send(`{"recipient":"jane_doe","sum":4.99}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+	})
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"recipient not found", fmt.Errorf("%w: jane_doe", store.ErrRecipientNotFound), http.StatusNotFound},
+		{"self transfer", store.ErrSelfTransfer, http.StatusBadRequest},
+		{"account frozen", fmt.Errorf("%w: account", store.ErrAccountFrozen), http.StatusForbidden},
+		{"not enough balance", &store.NotEnoughBalanceError{Err: fmt.Errorf("not enough balance")}, http.StatusPaymentRequired},
+		{"daily limit exceeded", &store.TransferLimitError{Err: fmt.Errorf("limit exceeded")}, http.StatusUnprocessableEntity},
+		{"store error", fmt.Errorf("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.EXPECT().TransferPoints(gomock.Any(), userID, "jane_doe", points.FromInt(10), points.FromInt(500)).Return(models.Transfer{}, tt.err)
+
+			resp := send(`{"recipient":"jane_doe","sum":10}`)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.status, resp.StatusCode, "unexpected response code")
+		})
+	}
+}
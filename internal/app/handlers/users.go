@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"crypto/rand"
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -17,6 +20,12 @@ var (
 	errInvalidCredentials = errors.New("invalid credentials")
 )
 
+// referralCodeAlphabet has no characters which are easily confused, such as 0 and O or 1 and I.
+const referralCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// referralCodeAttempts limits how many times a referral code is generated for a new user.
+const referralCodeAttempts = 3
+
 // RegisterUser handles user registration.
 func (h *Handlers) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	var request models.UserReristerRequest
@@ -33,6 +42,20 @@ func (h *Handlers) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var referrer models.User
+	if code := strings.ToUpper(strings.TrimSpace(request.ReferralCode)); code != "" {
+		var err error
+		if referrer, err = h.referrer(r, code); err != nil {
+			h.handleError("RegisterUser", err)
+			if errors.Is(err, sql.ErrNoRows) || errors.Is(err, store.ErrReferralLimitReached) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	pwdHash, err := hash.HashPassword(request.Password)
 	if err != nil {
 		h.handleError("RegisterUser", err)
@@ -44,11 +67,12 @@ func (h *Handlers) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		ID:        uuid.NewString(),
 		Login:     request.Login,
 		Password:  pwdHash,
+		Role:      models.RoleUser,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	_, err = h.s.CreateUser(r.Context(), user)
+	user, err = h.createUser(r, user)
 	if err != nil {
 		var sErr store.StoreError
 		if errors.As(err, &sErr) && sErr.IntegrityViolation() {
@@ -81,6 +105,20 @@ func (h *Handlers) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	h.log.With("ID", user.ID, "login", user.Login).Info("new user registered")
 	h.log.With("ID", account.ID, "userID", user.ID).Info("new account created")
 
+	if referrer.ID != "" {
+		referral := models.Referral{
+			ID:         uuid.NewString(),
+			ReferrerID: referrer.ID,
+			RefereeID:  user.ID,
+			Status:     models.ReferralStatusPending,
+			CreatedAt:  time.Now(),
+		}
+		// the user has already been registered, so the referral which could not be saved is only logged
+		if _, err = h.s.CreateReferral(r.Context(), referral, h.c.ReferralLimit); err != nil {
+			h.handleError("RegisterUser", errors.Wrap(err, "could not create referral"))
+		}
+	}
+
 	if err = h.authenticateUser(w, user); err != nil {
 		h.handleError("RegisterUser", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -91,6 +129,57 @@ func (h *Handlers) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// referrer finds the owner of the referral code and checks that they can invite one more user.
+func (h *Handlers) referrer(r *http.Request, code string) (models.User, error) {
+	referrer, err := h.s.GetUserByReferralCode(r.Context(), code)
+	if err != nil {
+		return referrer, errors.Wrapf(err, "invalid referral code %s", code)
+	}
+
+	if h.c.ReferralLimit > 0 {
+		summary, err := h.s.GetReferralSummary(r.Context(), referrer.ID)
+		if err != nil {
+			return referrer, err
+		}
+		if len(summary.Referrals) >= h.c.ReferralLimit {
+			return referrer, errors.Wrapf(store.ErrReferralLimitReached, "referral code %s", code)
+		}
+	}
+
+	return referrer, nil
+}
+
+// createUser saves the user with a new referral code. The code is generated again
+// if it has already been taken by another user.
+func (h *Handlers) createUser(r *http.Request, user models.User) (models.User, error) {
+	var err error
+	for i := 0; i < referralCodeAttempts; i++ {
+		if user.ReferralCode, err = newReferralCode(); err != nil {
+			return user, err
+		}
+
+		if _, err = h.s.CreateUser(r.Context(), user); !errors.Is(err, store.ErrReferralCodeTaken) {
+			return user, err
+		}
+	}
+
+	return user, err
+}
+
+// newReferralCode generates a random code of 8 characters.
+func newReferralCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	for i := range b {
+		b[i] = referralCodeAlphabet[int(b[i])%len(referralCodeAlphabet)]
+	}
+
+	return string(b), nil
+}
+
 // LoginUser handles user authentication.
 func (h *Handlers) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var request models.UserLoginRequest
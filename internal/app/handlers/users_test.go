@@ -1,14 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
 	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
 	"github.com/madatsci/gophermart/pkg/hash"
 	"github.com/stretchr/testify/assert"
@@ -108,6 +113,80 @@ func TestRegisterUserHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusConflict, resp.StatusCode, "unexpected response code")
 	})
+
+	t.Run("taken referral code is generated again", func(t *testing.T) {
+		var codes []string
+		m.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (models.User, error) {
+			codes = append(codes, user.ReferralCode)
+			return models.User{}, fmt.Errorf("%w: %s", store.ErrReferralCodeTaken, user.ReferralCode)
+		})
+		m.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (models.User, error) {
+			codes = append(codes, user.ReferralCode)
+			return user, nil
+		})
+		m.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(models.Account{}, nil)
+
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(validRequestBody))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		r := httptest.NewRecorder()
+
+		h.RegisterUser(r, req)
+		resp := r.Result()
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+		require.Len(t, codes, 2)
+		assert.NotEqual(t, codes[0], codes[1])
+	})
+
+	t.Run("referral code", func(t *testing.T) {
+		h.c.ReferralLimit = 2
+		defer func() { h.c.ReferralLimit = 0 }()
+
+		referrer := models.User{ID: uuid.NewString(), Login: "jane_doe", ReferralCode: "ABCD2345"}
+		send := func(body string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+
+			r := httptest.NewRecorder()
+			h.RegisterUser(r, req)
+
+			return r.Result()
+		}
+
+		m.EXPECT().GetUserByReferralCode(gomock.Any(), "ABCD2345").Return(referrer, nil)
+		m.EXPECT().GetReferralSummary(gomock.Any(), referrer.ID).Return(models.ReferralSummary{Referrals: []models.Referral{{}}}, nil)
+		m.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, user models.User) (models.User, error) {
+			assert.Len(t, user.ReferralCode, 8)
+			return user, nil
+		})
+		m.EXPECT().CreateAccount(gomock.Any(), gomock.Any()).Return(models.Account{}, nil)
+		m.EXPECT().CreateReferral(gomock.Any(), gomock.Any(), 2).DoAndReturn(func(_ context.Context, referral models.Referral, _ int) (models.Referral, error) {
+			assert.Equal(t, referrer.ID, referral.ReferrerID)
+			assert.Equal(t, models.ReferralStatusPending, referral.Status)
+			return referral, nil
+		})
+
+		resp := send(`{"login":"john_doe","password":"my_secret_password","referral_code":" abcd2345 "}`)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "unexpected response code")
+
+		m.EXPECT().GetUserByReferralCode(gomock.Any(), "UNKNOWN1").Return(models.User{}, sql.ErrNoRows)
+
+		resp = send(`{"login":"john_doe","password":"my_secret_password","referral_code":"UNKNOWN1"}`)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "unexpected response code")
+
+		m.EXPECT().GetUserByReferralCode(gomock.Any(), "ABCD2345").Return(referrer, nil)
+		m.EXPECT().GetReferralSummary(gomock.Any(), referrer.ID).Return(models.ReferralSummary{Referrals: []models.Referral{{}, {}}}, nil)
+
+		resp = send(`{"login":"john_doe","password":"my_secret_password","referral_code":"ABCD2345"}`)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode, "limit reached: unexpected response code")
+	})
 }
 
 func TestLoginUserHandler(t *testing.T) {
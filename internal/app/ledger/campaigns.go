@@ -0,0 +1,35 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+// ApplyCampaign returns the campaign which gives the account the biggest bonus to the accrual
+// and the bonus. The multiplier of a campaign applies to the accrual already multiplied by the tier.
+// Campaigns which are not active or the account is not eligible for are skipped, zero bonus
+// means no campaign applies.
+func ApplyCampaign(campaigns []models.Campaign, acc models.Account, accrual points.Points, now time.Time) (models.Campaign, points.Points) {
+	var (
+		best  models.Campaign
+		bonus points.Points
+	)
+
+	for _, c := range campaigns {
+		if !c.Active(now) || !c.Eligible(acc, now) {
+			continue
+		}
+
+		b := c.FixedBonus
+		if c.Multiplier != 0 {
+			b = Multiply(accrual, c.Multiplier) - accrual
+		}
+		if b > bonus {
+			best, bonus = c, b
+		}
+	}
+
+	return best, bonus
+}
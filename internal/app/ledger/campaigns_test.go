@@ -0,0 +1,110 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCampaign(t *testing.T) {
+	now := time.Date(2024, 11, 25, 12, 0, 0, 0, time.UTC)
+	disabledAt := now.Add(-time.Hour)
+
+	weekend := models.Campaign{
+		ID:          "weekend",
+		StartsAt:    now.Add(-24 * time.Hour),
+		EndsAt:      now.Add(24 * time.Hour),
+		Eligibility: models.CampaignEligibilityAll,
+		Multiplier:  points.FromInt(2),
+	}
+	gold := models.Campaign{
+		ID:          "gold",
+		StartsAt:    now.Add(-24 * time.Hour),
+		EndsAt:      now.Add(24 * time.Hour),
+		Eligibility: models.CampaignEligibilityTier,
+		Tier:        models.TierGold,
+		FixedBonus:  points.FromInt(500),
+	}
+	welcome := models.Campaign{
+		ID:          "welcome",
+		StartsAt:    now.Add(-24 * time.Hour),
+		EndsAt:      now.Add(24 * time.Hour),
+		Eligibility: models.CampaignEligibilityNewUsers,
+		NewUserDays: 30,
+		FixedBonus:  points.FromInt(50),
+	}
+	ended := weekend
+	ended.ID = "ended"
+	ended.EndsAt = now
+	disabled := weekend
+	disabled.ID = "disabled"
+	disabled.DisabledAt = &disabledAt
+
+	tests := []struct {
+		name      string
+		campaigns []models.Campaign
+		acc       models.Account
+		accrual   points.Points
+		campaign  string
+		bonus     points.Points
+	}{
+		{
+			name:    "no campaigns",
+			acc:     models.Account{Tier: models.TierBronze},
+			accrual: points.FromInt(100),
+		},
+		{
+			name:      "multiplier",
+			campaigns: []models.Campaign{weekend},
+			acc:       models.Account{Tier: models.TierBronze},
+			accrual:   points.FromInt(100),
+			campaign:  "weekend",
+			bonus:     points.FromInt(100),
+		},
+		{
+			name:      "ended and disabled campaigns are skipped",
+			campaigns: []models.Campaign{ended, disabled},
+			acc:       models.Account{Tier: models.TierBronze},
+			accrual:   points.FromInt(100),
+		},
+		{
+			name:      "tier below campaign tier",
+			campaigns: []models.Campaign{gold},
+			acc:       models.Account{Tier: models.TierSilver},
+			accrual:   points.FromInt(100),
+		},
+		{
+			name:      "biggest bonus wins",
+			campaigns: []models.Campaign{weekend, gold},
+			acc:       models.Account{Tier: models.TierGold},
+			accrual:   points.FromInt(100),
+			campaign:  "gold",
+			bonus:     points.FromInt(500),
+		},
+		{
+			name:      "new user",
+			campaigns: []models.Campaign{welcome},
+			acc:       models.Account{Tier: models.TierBronze, CreatedAt: now.AddDate(0, 0, -29)},
+			accrual:   points.FromInt(10),
+			campaign:  "welcome",
+			bonus:     points.FromInt(50),
+		},
+		{
+			name:      "user registered too long ago",
+			campaigns: []models.Campaign{welcome},
+			acc:       models.Account{Tier: models.TierBronze, CreatedAt: now.AddDate(0, 0, -30)},
+			accrual:   points.FromInt(10),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			campaign, bonus := ApplyCampaign(tt.campaigns, tt.acc, tt.accrual, now)
+			assert.Equal(t, tt.campaign, campaign.ID)
+			assert.Equal(t, tt.bonus, bonus)
+		})
+	}
+}
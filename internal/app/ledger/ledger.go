@@ -0,0 +1,221 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnbalanced is returned when posting entries do not sum up to zero.
+	ErrUnbalanced = errors.New("ledger posting is not balanced")
+	// ErrInvalidAmount is returned when transaction amount is not allowed for its direction.
+	ErrInvalidAmount = errors.New("invalid transaction amount")
+)
+
+// Entries builds balanced ledger entries for the transaction:
+//
+//	accrual:      program    -> wallet
+//	withdrawal:   wallet     -> redemption
+//	adjustment:   program    -> wallet (negative amount moves points back to program)
+//	expiry:       wallet     -> program
+//	refund:       redemption -> wallet
+//	clawback:     wallet     -> program
+//	transfer_out: wallet     -> transfers
+//	transfer_in:  transfers  -> wallet
+//	referral:     program    -> wallet
+//	promo:        program    -> wallet
+func Entries(tx models.Transaction) ([]models.LedgerEntry, error) {
+	if tx.Amount == 0 || (tx.Amount < 0 && tx.Direction != models.TxDirectionAdjustment) {
+		return nil, errors.Wrapf(ErrInvalidAmount, "%s of %s", tx.Direction, tx.Amount)
+	}
+
+	var from, to models.LedgerAccount
+
+	switch tx.Direction {
+	case models.TxDirectionAccrual, models.TxDirectionAdjustment, models.TxDirectionReferral,
+		models.TxDirectionPromo:
+		from, to = models.LedgerAccountProgram, models.LedgerAccountWallet
+	case models.TxDirectionWithdrawal:
+		from, to = models.LedgerAccountWallet, models.LedgerAccountRedemption
+	case models.TxDirectionExpiry, models.TxDirectionClawback:
+		from, to = models.LedgerAccountWallet, models.LedgerAccountProgram
+	case models.TxDirectionRefund:
+		from, to = models.LedgerAccountRedemption, models.LedgerAccountWallet
+	case models.TxDirectionTransferOut:
+		from, to = models.LedgerAccountWallet, models.LedgerAccountTransfers
+	case models.TxDirectionTransferIn:
+		from, to = models.LedgerAccountTransfers, models.LedgerAccountWallet
+	default:
+		return nil, fmt.Errorf("unknown transaction direction: %s", tx.Direction)
+	}
+
+	entries := []models.LedgerEntry{
+		newEntry(tx, from, -tx.Amount),
+		newEntry(tx, to, tx.Amount),
+	}
+
+	if err := Validate(entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Validate ensures that entries sum up to zero.
+func Validate(entries []models.LedgerEntry) error {
+	var sum points.Points
+	for _, e := range entries {
+		sum += e.Amount
+	}
+	if sum != 0 {
+		return errors.Wrapf(ErrUnbalanced, "entries sum is %s", sum)
+	}
+
+	return nil
+}
+
+// Apply updates cached totals of the account with the posted entries.
+func Apply(acc models.Account, tx models.Transaction, entries []models.LedgerEntry) models.Account {
+	for _, e := range entries {
+		if e.LedgerAccount == models.LedgerAccountWallet && e.AccountID == acc.ID {
+			acc.CurrentPointsTotal += e.Amount
+		}
+	}
+
+	switch tx.Direction {
+	case models.TxDirectionWithdrawal:
+		acc.WithdrawnTotal += tx.Amount
+	case models.TxDirectionRefund:
+		acc.WithdrawnTotal -= tx.Amount
+	}
+
+	return acc
+}
+
+func newEntry(tx models.Transaction, account models.LedgerAccount, amount points.Points) models.LedgerEntry {
+	e := models.LedgerEntry{
+		ID:            uuid.NewString(),
+		TransactionID: tx.ID,
+		LedgerAccount: account,
+		Amount:        amount,
+		CreatedAt:     tx.CreatedAt,
+	}
+	if account == models.LedgerAccountWallet {
+		e.AccountID = tx.AccountID
+	}
+
+	return e
+}
+
+// Delta returns the change of wallet balance caused by the transaction.
+func Delta(tx models.Transaction) points.Points {
+	switch tx.Direction {
+	case models.TxDirectionAccrual, models.TxDirectionAdjustment, models.TxDirectionRefund, models.TxDirectionTransferIn,
+		models.TxDirectionReferral, models.TxDirectionPromo:
+		return tx.Amount
+	case models.TxDirectionWithdrawal, models.TxDirectionExpiry, models.TxDirectionClawback, models.TxDirectionTransferOut:
+		return -tx.Amount
+	default:
+		return 0
+	}
+}
+
+// Reconcile compares cached totals of the account with balances of its wallet and redemption ledger accounts
+// and the sum of its active holds. Processed orders which accrual has never been credited are reported
+// as missing, their points are expected only once they are credited with the tier and campaigns of the account.
+// Debt is reported as is, it is not derived from the ledger because forgiven points are not recorded.
+func Reconcile(acc models.Account, balances map[models.LedgerAccount]points.Points, held points.Points, missing []models.Order) models.AccountDrift {
+	return models.AccountDrift{
+		AccountID:              acc.ID,
+		CurrentPointsTotal:     acc.CurrentPointsTotal,
+		ExpectedPointsTotal:    balances[models.LedgerAccountWallet],
+		WithdrawnTotal:         acc.WithdrawnTotal,
+		ExpectedWithdrawnTotal: balances[models.LedgerAccountRedemption],
+		HeldTotal:              acc.HeldTotal,
+		ExpectedHeldTotal:      held,
+		DebtTotal:              acc.DebtTotal,
+		MissingAccruals:        missing,
+	}
+}
+
+// ReconciliationOperator is the operator of adjustments posted by reconciliation.
+const ReconciliationOperator = "reconciliation"
+
+// Correction resets cached totals of the account to the values derived by Reconcile and returns
+// the adjustment which books the difference between the cached balance, the balance the user has seen,
+// and the ledger. Once the adjustment is posted, the cached balance is derived from ledger entries again.
+// Zero amount means the balance does not need an adjustment.
+func Correction(acc models.Account, d models.AccountDrift) (models.Account, models.Adjustment) {
+	adjustment := models.Adjustment{
+		AccountID: acc.ID,
+		Amount:    acc.CurrentPointsTotal - d.ExpectedPointsTotal,
+		Reason:    models.AdjustmentReasonCorrection,
+		Comment:   "ledger reconciliation",
+		Operator:  ReconciliationOperator,
+	}
+
+	acc.CurrentPointsTotal = d.ExpectedPointsTotal
+	acc.WithdrawnTotal = d.ExpectedWithdrawnTotal
+	acc.HeldTotal = d.ExpectedHeldTotal
+
+	return acc, adjustment
+}
+
+// Clawback splits accrual of a returned order into points taken back from the account,
+// points recorded as a debt and forgiven points according to the policy.
+func Clawback(acc models.Account, orderNumber string, accrual points.Points, policy models.ClawbackPolicy) models.Clawback {
+	c := models.Clawback{
+		OrderNumber: orderNumber,
+		Accrual:     accrual,
+		Clawed:      min(accrual, max(acc.Available(), 0)),
+	}
+
+	switch policy {
+	case models.ClawbackPolicyNegative:
+		c.Clawed = accrual
+	case models.ClawbackPolicyDebt:
+		c.Debt = accrual - c.Clawed
+	default:
+		c.Forgiven = accrual - c.Clawed
+	}
+
+	return c
+}
+
+// RefundAmount checks that the refund does not exceed the part of the withdrawal which has not been refunded yet.
+// Zero sum means the whole remaining part.
+func RefundAmount(withdrawal models.Transaction, refunded, sum points.Points) (points.Points, error) {
+	refundable := withdrawal.Amount - refunded
+	if sum == 0 {
+		sum = refundable
+	}
+	if sum <= 0 || sum > refundable {
+		return sum, &store.RefundExceededError{
+			Err:             fmt.Errorf("refund of order %s exceeds withdrawn points", withdrawal.OrderNumber),
+			Refundable:      refundable,
+			RefundRequested: sum,
+		}
+	}
+
+	return sum, nil
+}
+
+// CheckTransferLimit ensures that the transfer together with transfers made within the last 24 hours
+// does not exceed the daily limit.
+func CheckTransferLimit(transferred, sum, dailyLimit points.Points) error {
+	if transferred+sum <= dailyLimit {
+		return nil
+	}
+
+	return &store.TransferLimitError{
+		Err:         fmt.Errorf("transfer of %s exceeds daily limit of %s", sum, dailyLimit),
+		Limit:       dailyLimit,
+		Transferred: transferred,
+		Requested:   sum,
+	}
+}
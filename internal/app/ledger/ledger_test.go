@@ -0,0 +1,217 @@
+package ledger
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntries(t *testing.T) {
+	accountID := uuid.NewString()
+
+	tests := []struct {
+		direction models.TxDirection
+		amount    points.Points
+		from, to  models.LedgerAccount
+	}{
+		{models.TxDirectionAccrual, points.FromInt(100), models.LedgerAccountProgram, models.LedgerAccountWallet},
+		{models.TxDirectionWithdrawal, points.FromInt(50), models.LedgerAccountWallet, models.LedgerAccountRedemption},
+		{models.TxDirectionAdjustment, points.FromInt(-10), models.LedgerAccountProgram, models.LedgerAccountWallet},
+		{models.TxDirectionExpiry, points.FromInt(5), models.LedgerAccountWallet, models.LedgerAccountProgram},
+		{models.TxDirectionRefund, points.FromInt(20), models.LedgerAccountRedemption, models.LedgerAccountWallet},
+		{models.TxDirectionClawback, points.FromInt(15), models.LedgerAccountWallet, models.LedgerAccountProgram},
+		{models.TxDirectionTransferOut, points.FromInt(25), models.LedgerAccountWallet, models.LedgerAccountTransfers},
+		{models.TxDirectionTransferIn, points.FromInt(25), models.LedgerAccountTransfers, models.LedgerAccountWallet},
+		{models.TxDirectionReferral, points.FromInt(50), models.LedgerAccountProgram, models.LedgerAccountWallet},
+		{models.TxDirectionPromo, points.FromInt(20), models.LedgerAccountProgram, models.LedgerAccountWallet},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.direction), func(t *testing.T) {
+			tx := models.Transaction{ID: uuid.NewString(), AccountID: accountID, Amount: tt.amount, Direction: tt.direction}
+
+			entries, err := Entries(tx)
+			require.NoError(t, err)
+			require.Len(t, entries, 2)
+			require.NoError(t, Validate(entries))
+
+			assert.Equal(t, tt.from, entries[0].LedgerAccount)
+			assert.Equal(t, -tt.amount, entries[0].Amount)
+			assert.Equal(t, tt.to, entries[1].LedgerAccount)
+			assert.Equal(t, tt.amount, entries[1].Amount)
+
+			for _, e := range entries {
+				assert.Equal(t, tx.ID, e.TransactionID)
+				if e.LedgerAccount == models.LedgerAccountWallet {
+					assert.Equal(t, accountID, e.AccountID)
+				} else {
+					assert.Empty(t, e.AccountID)
+				}
+			}
+		})
+	}
+}
+
+func TestEntriesInvalid(t *testing.T) {
+	_, err := Entries(models.Transaction{Direction: models.TxDirectionAccrual})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = Entries(models.Transaction{Direction: models.TxDirectionWithdrawal, Amount: -1})
+	assert.ErrorIs(t, err, ErrInvalidAmount)
+
+	_, err = Entries(models.Transaction{Direction: "unknown", Amount: 1})
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate([]models.LedgerEntry{{Amount: 100}, {Amount: -99}})
+	assert.ErrorIs(t, err, ErrUnbalanced)
+}
+
+func TestApply(t *testing.T) {
+	acc := models.Account{ID: uuid.NewString(), CurrentPointsTotal: points.FromInt(100)}
+
+	tx := models.Transaction{ID: uuid.NewString(), AccountID: acc.ID, Amount: points.FromInt(30), Direction: models.TxDirectionWithdrawal}
+	entries, err := Entries(tx)
+	require.NoError(t, err)
+
+	acc = Apply(acc, tx, entries)
+	assert.Equal(t, points.FromInt(70), acc.CurrentPointsTotal)
+	assert.Equal(t, points.FromInt(30), acc.WithdrawnTotal)
+
+	tx = models.Transaction{ID: uuid.NewString(), AccountID: acc.ID, Amount: points.FromInt(10), Direction: models.TxDirectionRefund, ParentID: tx.ID}
+	entries, err = Entries(tx)
+	require.NoError(t, err)
+
+	acc = Apply(acc, tx, entries)
+	assert.Equal(t, points.FromInt(80), acc.CurrentPointsTotal)
+	assert.Equal(t, points.FromInt(20), acc.WithdrawnTotal)
+}
+
+func TestReconcile(t *testing.T) {
+	acc := models.Account{
+		ID:                 uuid.NewString(),
+		CurrentPointsTotal: points.FromInt(80),
+		WithdrawnTotal:     points.FromInt(20),
+		HeldTotal:          points.FromInt(10),
+	}
+	balances := map[models.LedgerAccount]points.Points{
+		models.LedgerAccountWallet:     points.FromInt(80),
+		models.LedgerAccountRedemption: points.FromInt(20),
+	}
+
+	d := Reconcile(acc, balances, points.FromInt(10), nil)
+	assert.False(t, d.HasDrift())
+
+	d = Reconcile(acc, balances, points.FromInt(10), []models.Order{{Number: "1111", Accrual: points.FromInt(50)}})
+	assert.True(t, d.HasDrift())
+	assert.Equal(t, points.FromInt(80), d.ExpectedPointsTotal)
+
+	d = Reconcile(acc, balances, 0, nil)
+	assert.True(t, d.HasDrift())
+	assert.Equal(t, points.Points(0), d.ExpectedHeldTotal)
+
+	acc.CurrentPointsTotal = points.FromMinor(7999)
+	d = Reconcile(acc, balances, points.FromInt(10), nil)
+	assert.True(t, d.HasDrift())
+
+	balances[models.LedgerAccountRedemption] = points.FromInt(15)
+	d = Reconcile(acc, balances, points.FromInt(10), nil)
+	assert.Equal(t, points.FromInt(15), d.ExpectedWithdrawnTotal)
+}
+
+func TestCorrection(t *testing.T) {
+	acc := models.Account{
+		ID:                 uuid.NewString(),
+		CurrentPointsTotal: points.FromInt(120),
+		WithdrawnTotal:     points.FromInt(30),
+	}
+	d := models.AccountDrift{
+		ExpectedPointsTotal:    points.FromInt(100),
+		ExpectedWithdrawnTotal: points.FromInt(20),
+		ExpectedHeldTotal:      points.FromInt(10),
+	}
+
+	acc, adjustment := Correction(acc, d)
+	assert.Equal(t, points.FromInt(100), acc.CurrentPointsTotal)
+	assert.Equal(t, points.FromInt(20), acc.WithdrawnTotal)
+	assert.Equal(t, points.FromInt(10), acc.HeldTotal)
+	assert.Equal(t, points.FromInt(20), adjustment.Amount)
+	assert.NoError(t, adjustment.Validate())
+}
+
+func TestClawback(t *testing.T) {
+	acc := models.Account{CurrentPointsTotal: points.FromInt(50), HeldTotal: points.FromInt(10)}
+	accrual := points.FromInt(100)
+
+	tests := []struct {
+		policy                 models.ClawbackPolicy
+		clawed, debt, forgiven points.Points
+	}{
+		{models.ClawbackPolicyNegative, points.FromInt(100), 0, 0},
+		{models.ClawbackPolicyDebt, points.FromInt(40), points.FromInt(60), 0},
+		{models.ClawbackPolicyPartial, points.FromInt(40), 0, points.FromInt(60)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			c := Clawback(acc, "1111", accrual, tt.policy)
+			assert.Equal(t, "1111", c.OrderNumber)
+			assert.Equal(t, accrual, c.Accrual)
+			assert.Equal(t, tt.clawed, c.Clawed)
+			assert.Equal(t, tt.debt, c.Debt)
+			assert.Equal(t, tt.forgiven, c.Forgiven)
+		})
+	}
+
+	t.Run("enough points", func(t *testing.T) {
+		c := Clawback(acc, "1111", points.FromInt(30), models.ClawbackPolicyDebt)
+		assert.Equal(t, points.FromInt(30), c.Clawed)
+		assert.Zero(t, c.Debt)
+	})
+
+	t.Run("negative balance", func(t *testing.T) {
+		c := Clawback(models.Account{CurrentPointsTotal: points.FromInt(-5)}, "1111", accrual, models.ClawbackPolicyPartial)
+		assert.Zero(t, c.Clawed)
+		assert.Equal(t, accrual, c.Forgiven)
+	})
+}
+
+func TestRefundAmount(t *testing.T) {
+	withdrawal := models.Transaction{OrderNumber: "1111", Amount: points.FromInt(100)}
+
+	sum, err := RefundAmount(withdrawal, points.FromInt(30), 0)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(70), sum)
+
+	sum, err = RefundAmount(withdrawal, points.FromInt(30), points.FromInt(20))
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(20), sum)
+
+	_, err = RefundAmount(withdrawal, points.FromInt(30), points.FromInt(80))
+	var refundErr *store.RefundExceededError
+	require.ErrorAs(t, err, &refundErr)
+	assert.Equal(t, points.FromInt(70), refundErr.Refundable)
+	assert.Equal(t, points.FromInt(80), refundErr.RefundRequested)
+
+	_, err = RefundAmount(withdrawal, points.FromInt(100), 0)
+	require.ErrorAs(t, err, &refundErr)
+}
+
+func TestCheckTransferLimit(t *testing.T) {
+	limit := points.FromInt(100)
+
+	require.NoError(t, CheckTransferLimit(points.FromInt(60), points.FromInt(40), limit))
+
+	err := CheckTransferLimit(points.FromInt(60), points.FromInt(50), limit)
+	var limitErr *store.TransferLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, limit, limitErr.Limit)
+	assert.Equal(t, points.FromInt(60), limitErr.Transferred)
+	assert.Equal(t, points.FromInt(50), limitErr.Requested)
+}
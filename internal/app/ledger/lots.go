@@ -0,0 +1,163 @@
+package ledger
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+// NewLot creates a lot of points credited by the transaction. Points expire lifetimeMonths
+// after the transaction, zero lifetime means the points never expire. Points covering
+// a negative balance do not remain in the lot, so balance is the account balance after the transaction.
+func NewLot(tx models.Transaction, balance points.Points, lifetimeMonths int) models.PointsLot {
+	lot := models.PointsLot{
+		ID:            uuid.NewString(),
+		AccountID:     tx.AccountID,
+		TransactionID: tx.ID,
+		Amount:        Delta(tx),
+		Remaining:     max(min(Delta(tx), balance), 0),
+		CreatedAt:     tx.CreatedAt,
+		UpdatedAt:     tx.CreatedAt,
+	}
+	if lifetimeMonths > 0 {
+		lot.ExpiresAt = tx.CreatedAt.AddDate(0, lifetimeMonths, 0)
+	}
+
+	return lot
+}
+
+// SortLots sorts lots in the order of consumption: the earliest expiring first,
+// lots which never expire last.
+func SortLots(lots []models.PointsLot) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		a, b := lots[i], lots[j]
+		if a.Expires() != b.Expires() {
+			return a.Expires()
+		}
+		if !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// SortUsages sorts usages of lots in the order their points are carried: the earliest expiring first,
+// usages of lots which never expire last.
+func SortUsages(usages []models.PointsLotUsage) {
+	sort.SliceStable(usages, func(i, j int) bool {
+		a, b := usages[i], usages[j]
+		if a.ExpiresAt.IsZero() != b.ExpiresAt.IsZero() {
+			return !a.ExpiresAt.IsZero()
+		}
+		if !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// Consume takes amount from lots sorted by SortLots. It returns the changed lots
+// and the amount which could not be covered by the lots.
+func Consume(lots []models.PointsLot, amount points.Points) ([]models.PointsLot, points.Points) {
+	changed := make([]models.PointsLot, 0)
+	for _, lot := range lots {
+		if amount <= 0 {
+			break
+		}
+		if lot.Remaining <= 0 {
+			continue
+		}
+
+		take := min(lot.Remaining, amount)
+		lot.Remaining -= take
+		amount -= take
+		changed = append(changed, lot)
+	}
+
+	return changed, amount
+}
+
+// NewUsages records points the debit transaction took from lots, changed are the lots returned
+// by Consume for the same lots.
+func NewUsages(tx models.Transaction, lots, changed []models.PointsLot) []models.PointsLotUsage {
+	before := make(map[string]points.Points, len(lots))
+	for _, lot := range lots {
+		before[lot.ID] = lot.Remaining
+	}
+
+	usages := make([]models.PointsLotUsage, 0, len(changed))
+	for _, lot := range changed {
+		usages = append(usages, models.PointsLotUsage{
+			ID:            uuid.NewString(),
+			LotID:         lot.ID,
+			TransactionID: tx.ID,
+			Amount:        before[lot.ID] - lot.Remaining,
+			ExpiresAt:     lot.ExpiresAt,
+			CreatedAt:     tx.CreatedAt,
+			UpdatedAt:     tx.CreatedAt,
+		})
+	}
+
+	return usages
+}
+
+// CarriesLots returns true if points credited by the transaction come back from or pass on
+// points debited by its parent transaction, so they are carried with CarryLots.
+func CarriesLots(tx models.Transaction) bool {
+	if tx.ParentID == "" {
+		return false
+	}
+
+	return tx.Direction == models.TxDirectionTransferIn || tx.Direction == models.TxDirectionRefund
+}
+
+// CarryLots creates lots of points credited by the transaction which keep expiry dates of the lots
+// the parent transaction took the points from, so transfers and refunds never extend the lifetime of points.
+// Points not covered by usages of the parent get a lot expiring like the one of NewLot. It returns
+// the lots and the usages which points have been carried.
+func CarryLots(tx models.Transaction, balance points.Points, lifetimeMonths int, usages []models.PointsLotUsage) ([]models.PointsLot, []models.PointsLotUsage) {
+	delta := Delta(tx)
+	lots := make([]models.PointsLot, 0)
+	changed := make([]models.PointsLotUsage, 0)
+
+	left := delta
+	for _, usage := range usages {
+		if left <= 0 {
+			break
+		}
+
+		take := min(usage.Amount-usage.Carried, left)
+		if take <= 0 {
+			continue
+		}
+		usage.Carried += take
+		usage.UpdatedAt = tx.CreatedAt
+		left -= take
+		changed = append(changed, usage)
+
+		lot := NewLot(tx, take, 0)
+		lot.Amount, lot.Remaining = take, take
+		lot.ExpiresAt = usage.ExpiresAt
+		lots = append(lots, lot)
+	}
+	if left > 0 {
+		lot := NewLot(tx, left, lifetimeMonths)
+		lot.Amount, lot.Remaining = left, left
+		lots = append(lots, lot)
+	}
+
+	// points covering a negative balance do not remain in lots, the earliest expiring are taken first
+	uncovered := delta - max(min(delta, balance), 0)
+	for i := range lots {
+		take := min(lots[i].Remaining, uncovered)
+		lots[i].Remaining -= take
+		uncovered -= take
+	}
+
+	return lots, changed
+}
@@ -0,0 +1,100 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLot(t *testing.T) {
+	tx := models.Transaction{
+		ID:        uuid.NewString(),
+		AccountID: uuid.NewString(),
+		Amount:    points.FromInt(100),
+		Direction: models.TxDirectionAccrual,
+		CreatedAt: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+	}
+
+	lot := NewLot(tx, tx.Amount, 0)
+	assert.False(t, lot.Expires())
+	assert.Equal(t, tx.Amount, lot.Remaining)
+
+	lot = NewLot(tx, points.FromInt(30), 0)
+	assert.Equal(t, tx.Amount, lot.Amount)
+	assert.Equal(t, points.FromInt(30), lot.Remaining)
+
+	lot = NewLot(tx, tx.Amount, 6)
+	assert.Equal(t, time.Date(2024, 7, 31, 12, 0, 0, 0, time.UTC), lot.ExpiresAt)
+	assert.False(t, lot.Due(lot.ExpiresAt.Add(-time.Second)))
+	assert.True(t, lot.Due(lot.ExpiresAt))
+}
+
+func TestConsume(t *testing.T) {
+	now := time.Now()
+	lots := []models.PointsLot{
+		{ID: "never", Remaining: points.FromInt(100), CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: "late", Remaining: points.FromInt(100), CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(48 * time.Hour)},
+		{ID: "early", Remaining: points.FromInt(100), CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(24 * time.Hour)},
+	}
+
+	SortLots(lots)
+	assert.Equal(t, []string{"early", "late", "never"}, []string{lots[0].ID, lots[1].ID, lots[2].ID})
+
+	changed, left := Consume(lots, points.FromInt(150))
+	require.Len(t, changed, 2)
+	assert.Equal(t, points.Points(0), changed[0].Remaining)
+	assert.Equal(t, points.FromInt(50), changed[1].Remaining)
+	assert.Equal(t, points.Points(0), left)
+
+	_, left = Consume(lots, points.FromInt(350))
+	assert.Equal(t, points.FromInt(50), left)
+}
+
+func TestCarryLots(t *testing.T) {
+	now := time.Now()
+	out := models.Transaction{ID: uuid.NewString(), Amount: points.FromInt(150), Direction: models.TxDirectionTransferOut, CreatedAt: now}
+	lots := []models.PointsLot{
+		{ID: "early", Remaining: points.FromInt(100), ExpiresAt: now.Add(24 * time.Hour)},
+		{ID: "never", Remaining: points.FromInt(100)},
+	}
+	changed, _ := Consume(lots, out.Amount)
+	usages := NewUsages(out, lots, changed)
+	require.Len(t, usages, 2)
+	assert.Equal(t, points.FromInt(100), usages[0].Amount)
+	assert.Equal(t, points.FromInt(50), usages[1].Amount)
+
+	in := models.Transaction{ID: uuid.NewString(), Amount: points.FromInt(150), Direction: models.TxDirectionTransferIn, ParentID: out.ID, CreatedAt: now}
+	require.True(t, CarriesLots(in))
+
+	t.Run("lots keep expiry dates", func(t *testing.T) {
+		carried, changed := CarryLots(in, in.Amount, 6, usages)
+		require.Len(t, carried, 2)
+		assert.Equal(t, lots[0].ExpiresAt, carried[0].ExpiresAt)
+		assert.Equal(t, points.FromInt(100), carried[0].Remaining)
+		assert.False(t, carried[1].Expires())
+		assert.Equal(t, points.FromInt(50), carried[1].Remaining)
+		require.Len(t, changed, 2)
+		assert.Equal(t, changed[0].Amount, changed[0].Carried)
+	})
+
+	t.Run("points above usages get a new lot", func(t *testing.T) {
+		usages[0].Carried = points.FromInt(60)
+		carried, _ := CarryLots(in, in.Amount, 6, usages)
+		require.Len(t, carried, 3)
+		assert.Equal(t, points.FromInt(60), carried[2].Amount)
+		assert.Equal(t, now.AddDate(0, 6, 0), carried[2].ExpiresAt)
+	})
+
+	t.Run("points covering negative balance do not remain", func(t *testing.T) {
+		usages[0].Carried = 0
+		carried, _ := CarryLots(in, points.FromInt(120), 6, usages)
+		require.Len(t, carried, 2)
+		assert.Equal(t, points.FromInt(70), carried[0].Remaining)
+		assert.Equal(t, points.FromInt(50), carried[1].Remaining)
+	})
+}
@@ -0,0 +1,52 @@
+package ledger
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+// EvaluateTier returns the tier reached with qualifying points and the progress to the next tier.
+// Without rules every account is bronze and accruals are credited as is.
+func EvaluateTier(policy models.TierPolicy, qualifying points.Points) models.TierStatus {
+	status := models.TierStatus{
+		Tier:         models.TierBronze,
+		Multiplier:   points.FromInt(1),
+		Qualifying:   qualifying,
+		WindowMonths: policy.WindowMonths,
+		Progress:     100,
+	}
+
+	var threshold points.Points
+	for _, rule := range policy.Rules {
+		if qualifying < rule.Threshold {
+			status.NextTier = rule.Tier
+			status.NextThreshold = rule.Threshold
+			status.Remaining = rule.Threshold - qualifying
+			status.Progress = int((qualifying - threshold).Minor() * 100 / (rule.Threshold - threshold).Minor())
+			break
+		}
+
+		status.Tier = rule.Tier
+		status.Multiplier = rule.Multiplier
+		threshold = rule.Threshold
+	}
+
+	return status
+}
+
+// Multiply applies the multiplier to the accrual, the result is rounded down to minor units.
+func Multiply(amount points.Points, multiplier points.Points) points.Points {
+	return points.FromMinor(amount.Minor() * multiplier.Minor() / points.FromInt(1).Minor())
+}
+
+// QualifyingSince returns the time from which accruals qualify for a tier, zero time means
+// the whole account history.
+func QualifyingSince(policy models.TierPolicy, now time.Time) time.Time {
+	if policy.WindowMonths == 0 {
+		return time.Time{}
+	}
+
+	return now.AddDate(0, -policy.WindowMonths, 0)
+}
@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTierRules = []models.TierRule{
+	{Tier: models.TierBronze, Threshold: 0, Multiplier: points.FromInt(1)},
+	{Tier: models.TierSilver, Threshold: points.FromInt(1000), Multiplier: points.FromMinor(125)},
+	{Tier: models.TierGold, Threshold: points.FromInt(5000), Multiplier: points.FromMinor(150)},
+}
+
+func TestEvaluateTier(t *testing.T) {
+	policy := models.TierPolicy{Rules: testTierRules}
+
+	tests := []struct {
+		name       string
+		qualifying points.Points
+		want       models.TierStatus
+	}{
+		{
+			name:       "new account",
+			qualifying: 0,
+			want: models.TierStatus{
+				Tier:          models.TierBronze,
+				Multiplier:    points.FromInt(1),
+				NextTier:      models.TierSilver,
+				NextThreshold: points.FromInt(1000),
+				Remaining:     points.FromInt(1000),
+				Progress:      0,
+			},
+		},
+		{
+			name:       "halfway to silver",
+			qualifying: points.FromInt(500),
+			want: models.TierStatus{
+				Tier:          models.TierBronze,
+				Multiplier:    points.FromInt(1),
+				Qualifying:    points.FromInt(500),
+				NextTier:      models.TierSilver,
+				NextThreshold: points.FromInt(1000),
+				Remaining:     points.FromInt(500),
+				Progress:      50,
+			},
+		},
+		{
+			name:       "silver reached",
+			qualifying: points.FromInt(2000),
+			want: models.TierStatus{
+				Tier:          models.TierSilver,
+				Multiplier:    points.FromMinor(125),
+				Qualifying:    points.FromInt(2000),
+				NextTier:      models.TierGold,
+				NextThreshold: points.FromInt(5000),
+				Remaining:     points.FromInt(3000),
+				Progress:      25,
+			},
+		},
+		{
+			name:       "gold is the top tier",
+			qualifying: points.FromInt(5000),
+			want: models.TierStatus{
+				Tier:       models.TierGold,
+				Multiplier: points.FromMinor(150),
+				Qualifying: points.FromInt(5000),
+				Progress:   100,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, EvaluateTier(policy, tt.qualifying))
+		})
+	}
+
+	status := EvaluateTier(models.TierPolicy{}, points.FromInt(10000))
+	assert.Equal(t, models.TierBronze, status.Tier)
+	assert.Equal(t, points.FromInt(1), status.Multiplier)
+}
+
+func TestMultiply(t *testing.T) {
+	assert.Equal(t, points.FromInt(100), Multiply(points.FromInt(100), points.FromInt(1)))
+	assert.Equal(t, points.FromInt(125), Multiply(points.FromInt(100), points.FromMinor(125)))
+	// 0.33 * 1.5 = 0.495 is rounded down
+	assert.Equal(t, points.FromMinor(49), Multiply(points.FromMinor(33), points.FromMinor(150)))
+}
+
+func TestQualifyingSince(t *testing.T) {
+	now := time.Date(2024, 11, 22, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, QualifyingSince(models.TierPolicy{}, now).IsZero())
+	assert.Equal(t, time.Date(2023, 11, 22, 12, 0, 0, 0, time.UTC), QualifyingSince(models.TierPolicy{WindowMonths: 12}, now))
+}
@@ -2,16 +2,45 @@ package models
 
 import (
 	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
 )
 
 type Account struct {
-	ID                 string    `bun:",pk,type:uuid" json:"-"`
-	UserID             string    `bun:",unique,notnull" json:"-"`
-	CurrentPointsTotal float32   `bun:",notnull,default:0" json:"current"`
-	WithdrawnTotal     float32   `bun:",notnull,default:0" json:"withdrawn"`
-	CreatedAt          time.Time `bun:",notnull,default:current_timestamp" json:"-"`
-	UpdatedAt          time.Time `bun:",notnull,default:current_timestamp" json:"-"`
+	ID                 string        `bun:",pk,type:uuid" json:"-"`
+	UserID             string        `bun:",unique,notnull" json:"-"`
+	CurrentPointsTotal points.Points `bun:",notnull,default:0" json:"current"`
+	WithdrawnTotal     points.Points `bun:",notnull,default:0" json:"withdrawn"`
+	HeldTotal          points.Points `bun:",notnull,default:0" json:"-"`
+	DebtTotal          points.Points `bun:",notnull,default:0" json:"-"`
+	Tier               Tier          `bun:",notnull,default:'bronze'" json:"-"`
+	FrozenAt           time.Time     `bun:",nullzero" json:"-"`
+	CreatedAt          time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+	UpdatedAt          time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
 
 	User   User     `bun:"rel:belongs-to,join:user_id=id" json:"-"`
 	Orders []*Order `bun:"rel:has-many,join:id=account_id" json:"-"`
 }
+
+// Frozen returns true if the account has been frozen by an operator. Points of a frozen account
+// can not be spent.
+func (a Account) Frozen() bool {
+	return !a.FrozenAt.IsZero()
+}
+
+// Available returns points which are not reserved by holds.
+func (a Account) Available() points.Points {
+	return a.CurrentPointsTotal - a.HeldTotal
+}
+
+// Balance is the account balance with points which expire soon.
+type Balance struct {
+	Current        points.Points `json:"current"`
+	Withdrawn      points.Points `json:"withdrawn"`
+	Held           points.Points `json:"held"`
+	Available      points.Points `json:"available"`
+	Debt           points.Points `json:"debt,omitempty"`
+	Tier           Tier          `json:"tier,omitempty"`
+	ExpiringSoon   points.Points `json:"expiring_soon"`
+	NextExpiration *time.Time    `json:"next_expiration,omitempty"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// Adjustment is a manual credit (positive amount) or debit (negative amount) made by an operator.
+	Adjustment struct {
+		AccountID string
+		Amount    points.Points
+		Reason    AdjustmentReason
+		Comment   string
+		// Operator identifies who made the adjustment: user ID for admin API and operator name for CLI.
+		Operator string
+	}
+
+	// AdjustmentReason is a machine-readable reason of an adjustment used for reporting.
+	AdjustmentReason string
+)
+
+const (
+	AdjustmentReasonGoodwill     AdjustmentReason = "goodwill"
+	AdjustmentReasonCompensation AdjustmentReason = "compensation"
+	AdjustmentReasonCorrection   AdjustmentReason = "correction"
+	AdjustmentReasonFraud        AdjustmentReason = "fraud"
+	// AdjustmentReasonOther requires a comment explaining the adjustment.
+	AdjustmentReasonOther AdjustmentReason = "other"
+)
+
+// Valid returns true if the reason is known.
+func (r AdjustmentReason) Valid() bool {
+	switch r {
+	case AdjustmentReasonGoodwill, AdjustmentReasonCompensation, AdjustmentReasonCorrection, AdjustmentReasonFraud, AdjustmentReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate checks that the adjustment changes the balance and its reason is explained.
+func (a Adjustment) Validate() error {
+	switch {
+	case a.Amount == 0:
+		return errors.New("adjustment amount must not be zero")
+	case !a.Reason.Valid():
+		return fmt.Errorf("invalid adjustment reason: %s", a.Reason)
+	case a.Reason == AdjustmentReasonOther && a.Comment == "":
+		return errors.New("adjustment with other reason requires a comment")
+	case a.Operator == "":
+		return errors.New("adjustment operator is required")
+	default:
+		return nil
+	}
+}
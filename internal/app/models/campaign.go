@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// Campaign raises accruals of orders processed within its window, either by a multiplier
+	// or by a fixed bonus.
+	Campaign struct {
+		ID          string              `bun:",pk,type:uuid" json:"id"`
+		Name        string              `bun:",notnull" json:"name"`
+		StartsAt    time.Time           `bun:",notnull" json:"starts_at"`
+		EndsAt      time.Time           `bun:",notnull" json:"ends_at"`
+		Eligibility CampaignEligibility `bun:",notnull" json:"eligibility"`
+		// Tier is the lowest tier eligible for the campaign with tier eligibility.
+		Tier Tier `bun:",nullzero" json:"tier,omitempty"`
+		// NewUserDays is how many days after registration users are eligible for the campaign with new users eligibility.
+		NewUserDays int `bun:",nullzero" json:"new_user_days,omitempty"`
+		// Multiplier has two decimal places, e.g. 2 doubles the accrual.
+		Multiplier points.Points `bun:",nullzero" json:"multiplier,omitempty"`
+		FixedBonus points.Points `bun:",nullzero" json:"fixed_bonus,omitempty"`
+		DisabledAt *time.Time    `bun:",nullzero" json:"disabled_at,omitempty"`
+		CreatedAt  time.Time     `bun:",notnull,default:current_timestamp" json:"created_at"`
+		UpdatedAt  time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+	}
+
+	// CampaignStats is the campaign with accruals it has been applied to.
+	CampaignStats struct {
+		Campaign `bun:",extend"`
+
+		Orders   int           `bun:"orders" json:"orders"`
+		Credited points.Points `bun:"credited" json:"credited"`
+	}
+
+	// CampaignEligibility defines which users the campaign applies to.
+	CampaignEligibility string
+)
+
+const (
+	CampaignEligibilityAll CampaignEligibility = "all"
+	// CampaignEligibilityTier applies the campaign to users of the campaign tier or higher.
+	CampaignEligibilityTier CampaignEligibility = "tier"
+	// CampaignEligibilityNewUsers applies the campaign to users registered within NewUserDays days.
+	CampaignEligibilityNewUsers CampaignEligibility = "new_users"
+)
+
+// Valid returns true if the eligibility is known.
+func (e CampaignEligibility) Valid() bool {
+	switch e {
+	case CampaignEligibilityAll, CampaignEligibilityTier, CampaignEligibilityNewUsers:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate checks the window and eligibility of the campaign and that it raises accruals
+// either by a multiplier or by a fixed bonus.
+func (c Campaign) Validate() error {
+	switch {
+	case c.Name == "":
+		return errors.New("campaign name must not be empty")
+	case !c.EndsAt.After(c.StartsAt):
+		return errors.New("campaign must end after it starts")
+	case !c.Eligibility.Valid():
+		return fmt.Errorf("invalid campaign eligibility: %s", c.Eligibility)
+	case c.Eligibility == CampaignEligibilityTier && !c.Tier.Valid():
+		return fmt.Errorf("invalid campaign tier: %s", c.Tier)
+	case c.Eligibility == CampaignEligibilityNewUsers && c.NewUserDays <= 0:
+		return errors.New("campaign for new users requires positive number of days")
+	case (c.Multiplier == 0) == (c.FixedBonus == 0):
+		return errors.New("campaign requires either multiplier or fixed bonus")
+	case c.Multiplier != 0 && c.Multiplier <= points.FromInt(1):
+		return errors.New("campaign multiplier must be greater than 1")
+	case c.FixedBonus < 0:
+		return errors.New("campaign fixed bonus must be positive")
+	default:
+		return nil
+	}
+}
+
+// Active returns true if the campaign has not been disabled and now is within its window.
+func (c Campaign) Active(now time.Time) bool {
+	return c.DisabledAt == nil && !now.Before(c.StartsAt) && now.Before(c.EndsAt)
+}
+
+// Eligible returns true if the campaign applies to the account at the moment.
+func (c Campaign) Eligible(acc Account, now time.Time) bool {
+	switch c.Eligibility {
+	case CampaignEligibilityAll:
+		return true
+	case CampaignEligibilityTier:
+		return acc.Tier.Rank() >= c.Tier.Rank()
+	case CampaignEligibilityNewUsers:
+		return now.Before(acc.CreatedAt.AddDate(0, 0, c.NewUserDays))
+	default:
+		return false
+	}
+}
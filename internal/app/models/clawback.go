@@ -0,0 +1,37 @@
+package models
+
+import "github.com/madatsci/gophermart/pkg/points"
+
+type (
+	// ClawbackPolicy defines what happens when accrual of a returned order has already been spent.
+	ClawbackPolicy string
+
+	// Clawback is the result of taking back accrual of a returned order.
+	Clawback struct {
+		OrderNumber string        `json:"order"`
+		Accrual     points.Points `json:"accrual"`
+		Clawed      points.Points `json:"clawed"`
+		Debt        points.Points `json:"debt"`
+		Forgiven    points.Points `json:"forgiven"`
+	}
+)
+
+const (
+	// ClawbackPolicyNegative takes back the whole accrual, the balance may become negative.
+	ClawbackPolicyNegative ClawbackPolicy = "negative"
+	// ClawbackPolicyDebt takes back available points and records the rest as a debt
+	// which is settled from future accruals.
+	ClawbackPolicyDebt ClawbackPolicy = "debt"
+	// ClawbackPolicyPartial takes back available points and forgives the rest.
+	ClawbackPolicyPartial ClawbackPolicy = "partial"
+)
+
+// Valid returns true if the policy is known.
+func (p ClawbackPolicy) Valid() bool {
+	switch p {
+	case ClawbackPolicyNegative, ClawbackPolicyDebt, ClawbackPolicyPartial:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+type (
+	// Page describes keyset pagination: items after the cursor in the specified sort order.
+	Page struct {
+		Limit int
+		After *Cursor
+		Order SortOrder
+	}
+
+	// Cursor is a position in a list sorted by creation time and ID.
+	Cursor struct {
+		CreatedAt time.Time `json:"t"`
+		ID        string    `json:"id"`
+	}
+
+	SortOrder string
+
+	OrderFilter struct {
+		Page
+		Statuses []OrderStatus
+		From     time.Time
+		To       time.Time
+	}
+
+	TransactionFilter struct {
+		Page
+		Directions []TxDirection
+		From       time.Time
+		To         time.Time
+	}
+)
+
+const (
+	SortOrderAsc  SortOrder = "asc"
+	SortOrderDesc SortOrder = "desc"
+)
+
+// Desc returns true if items are sorted from newest to oldest, which is the default.
+func (p Page) Desc() bool {
+	return p.Order != SortOrderAsc
+}
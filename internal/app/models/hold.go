@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// Hold reserves points for an order until it is captured, voided or expires.
+	// Held points reduce available balance but are not withdrawn until the hold is captured.
+	Hold struct {
+		ID            string        `bun:",pk,type:uuid" json:"id"`
+		AccountID     string        `bun:",notnull,type:uuid" json:"-"`
+		OrderNumber   string        `bun:",notnull" json:"order"`
+		Amount        points.Points `bun:",notnull" json:"sum"`
+		Status        HoldStatus    `bun:",notnull" json:"status"`
+		TransactionID string        `bun:",nullzero,type:uuid" json:"-"`
+		ExpiresAt     time.Time     `bun:",notnull" json:"expires_at"`
+		CreatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"created_at"`
+		UpdatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+	}
+
+	HoldStatus string
+)
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusVoided   HoldStatus = "voided"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// Active returns true if the hold still reserves points at the moment.
+func (h Hold) Active(now time.Time) bool {
+	return h.Status == HoldStatusActive && h.ExpiresAt.After(now)
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyKey holds the response of a request made with Idempotency-Key header.
+// A key without status code is reserved by a request which is still in progress.
+type IdempotencyKey struct {
+	UserID       string    `bun:",pk,type:uuid"`
+	Key          string    `bun:",pk"`
+	RequestHash  string    `bun:",notnull"`
+	StatusCode   int       `bun:",nullzero"`
+	ContentType  string    `bun:",nullzero"`
+	ResponseBody []byte    `bun:",nullzero,type:bytea"`
+	CreatedAt    time.Time `bun:",notnull,default:current_timestamp"`
+	ExpiresAt    time.Time `bun:",notnull"`
+}
+
+// Completed returns true if the response has been saved.
+func (k IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
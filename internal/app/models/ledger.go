@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// LedgerEntry is one side of a balanced ledger posting. Entries of the same transaction always sum up to zero.
+	LedgerEntry struct {
+		ID            string        `bun:",pk,type:uuid" json:"-"`
+		TransactionID string        `bun:",notnull,type:uuid" json:"-"`
+		LedgerAccount LedgerAccount `bun:",notnull" json:"ledger_account"`
+		AccountID     string        `bun:",nullzero,type:uuid" json:"account_id,omitempty"`
+		Amount        points.Points `bun:",notnull" json:"amount"`
+		CreatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"created_at"`
+
+		Transaction *Transaction `bun:"rel:belongs-to,join:transaction_id=id" json:"-"`
+	}
+
+	// LedgerBalance is a balance of a ledger account derived from its entries.
+	LedgerBalance struct {
+		LedgerAccount LedgerAccount `bun:"ledger_account" json:"ledger_account"`
+		AccountID     string        `bun:"account_id" json:"account_id,omitempty"`
+		Balance       points.Points `bun:"balance" json:"balance"`
+	}
+
+	LedgerAccount string
+)
+
+const (
+	// LedgerAccountWallet is a user wallet, entries are linked to the user account.
+	LedgerAccountWallet LedgerAccount = "wallet"
+	// LedgerAccountProgram is the loyalty program liability, points are issued from it and returned to it.
+	LedgerAccountProgram LedgerAccount = "program"
+	// LedgerAccountRedemption is the sink for withdrawn points.
+	LedgerAccountRedemption LedgerAccount = "redemption"
+	// LedgerAccountTransfers is the clearing account of transfers between users, it is zero for completed transfers.
+	LedgerAccountTransfers LedgerAccount = "transfers"
+)
+
+// AccountDrift is a difference between cached account totals and totals derived from its history.
+type AccountDrift struct {
+	AccountID              string        `json:"account_id"`
+	CurrentPointsTotal     points.Points `json:"current"`
+	ExpectedPointsTotal    points.Points `json:"expected_current"`
+	WithdrawnTotal         points.Points `json:"withdrawn"`
+	ExpectedWithdrawnTotal points.Points `json:"expected_withdrawn"`
+	HeldTotal              points.Points `json:"held"`
+	ExpectedHeldTotal      points.Points `json:"expected_held"`
+	DebtTotal              points.Points `json:"debt"`
+	MissingAccruals        []Order       `json:"missing_accruals,omitempty"`
+	// Adjustment is the amount of the correction posted by the repair, see ledger.Correction.
+	Adjustment points.Points `json:"adjustment,omitempty"`
+	Repaired   bool          `json:"repaired"`
+}
+
+// HasDrift returns true if the account needs to be repaired.
+func (d AccountDrift) HasDrift() bool {
+	return d.CurrentPointsTotal != d.ExpectedPointsTotal ||
+		d.WithdrawnTotal != d.ExpectedWithdrawnTotal ||
+		d.HeldTotal != d.ExpectedHeldTotal ||
+		len(d.MissingAccruals) > 0
+}
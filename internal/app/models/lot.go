@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+// PointsLot is a portion of points credited by one transaction. Withdrawals consume lots
+// in FIFO order and the remaining points of a lot expire at once.
+type PointsLot struct {
+	ID            string        `bun:",pk,type:uuid" json:"-"`
+	AccountID     string        `bun:",notnull,type:uuid" json:"-"`
+	TransactionID string        `bun:",nullzero,type:uuid" json:"-"`
+	Amount        points.Points `bun:",notnull" json:"amount"`
+	Remaining     points.Points `bun:",notnull" json:"remaining"`
+	ExpiresAt     time.Time     `bun:",nullzero" json:"expires_at,omitempty"`
+	CreatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+
+	Transaction *Transaction `bun:"rel:belongs-to,join:transaction_id=id" json:"-"`
+}
+
+// Expires returns true if the lot has an expiry date.
+func (l PointsLot) Expires() bool {
+	return !l.ExpiresAt.IsZero()
+}
+
+// Due returns true if remaining points of the lot must expire at the moment.
+func (l PointsLot) Due(now time.Time) bool {
+	return l.Remaining > 0 && l.Expires() && !l.ExpiresAt.After(now)
+}
+
+// PointsLotUsage is a portion of points a debit transaction took from a lot. Points transferred
+// or refunded later are credited back with the expiry date of the lot they were taken from.
+type PointsLotUsage struct {
+	ID            string        `bun:",pk,type:uuid" json:"-"`
+	LotID         string        `bun:",notnull,type:uuid" json:"-"`
+	TransactionID string        `bun:",notnull,type:uuid" json:"-"`
+	Amount        points.Points `bun:",notnull" json:"amount"`
+	Carried       points.Points `bun:",notnull" json:"carried"`
+	ExpiresAt     time.Time     `bun:",nullzero" json:"expires_at,omitempty"`
+	CreatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+}
@@ -2,17 +2,23 @@ package models
 
 import (
 	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
 )
 
 type (
 	Order struct {
-		ID        string      `bun:",pk,type:uuid" json:"-"`
-		AccountID string      `bun:",notnull" json:"-"`
-		Number    string      `bun:",unique,notnull" json:"number"`
-		Status    OrderStatus `bun:",notnull" json:"status"`
-		Accrual   float32     `bun:",nullzero" json:"accrual"`
-		CreatedAt time.Time   `bun:",notnull,default:current_timestamp" json:"uploaded_at"`
-		UpdatedAt time.Time   `bun:",notnull,default:current_timestamp" json:"-"`
+		ID        string        `bun:",pk,type:uuid" json:"-"`
+		AccountID string        `bun:",notnull" json:"-"`
+		Number    string        `bun:",unique,notnull" json:"number"`
+		Status    OrderStatus   `bun:",notnull" json:"status"`
+		Accrual   points.Points `bun:",nullzero" json:"accrual"`
+		CreatedAt time.Time     `bun:",notnull,default:current_timestamp" json:"uploaded_at"`
+		UpdatedAt time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+		// Attempts is the number of polls of accrual system since the status has changed,
+		// the order is not polled again until NextCheckAt.
+		Attempts    int       `bun:",notnull,default:0" json:"-"`
+		NextCheckAt time.Time `bun:",nullzero,notnull,default:current_timestamp" json:"-"`
 
 		Account Account `bun:"rel:belongs-to,join:account_id=id" json:"-"`
 	}
@@ -25,4 +31,17 @@ const (
 	OrderStatusProcessing OrderStatus = "PROCESSING"
 	OrderStatusInvalid    OrderStatus = "INVALID"
 	OrderStatusProcessed  OrderStatus = "PROCESSED"
+	// OrderStatusReturned is set when goods of a processed order are returned to the shop
+	// and its accrual is clawed back.
+	OrderStatusReturned OrderStatus = "RETURNED"
 )
+
+// Valid returns true if the status is known.
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case OrderStatusNew, OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed, OrderStatusReturned:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// PromoCode credits points to users who redeem it. Every user can redeem the code once,
+	// the code can be redeemed MaxRedemptions times in total.
+	PromoCode struct {
+		ID             string        `bun:",pk,type:uuid" json:"-"`
+		Code           string        `bun:",unique,notnull" json:"code"`
+		Amount         points.Points `bun:",notnull" json:"sum"`
+		MaxRedemptions int           `bun:",notnull" json:"max_redemptions"`
+		Redemptions    int           `bun:",notnull,default:0" json:"redemptions"`
+		StartsAt       *time.Time    `bun:",nullzero" json:"starts_at,omitempty"`
+		EndsAt         *time.Time    `bun:",nullzero" json:"ends_at,omitempty"`
+		DisabledAt     *time.Time    `bun:",nullzero" json:"disabled_at,omitempty"`
+		CreatedAt      time.Time     `bun:",notnull,default:current_timestamp" json:"created_at"`
+		UpdatedAt      time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
+	}
+
+	// PromoRedemption is a promo code redeemed by the user.
+	PromoRedemption struct {
+		ID            string        `bun:",pk,type:uuid" json:"-"`
+		PromoCodeID   string        `bun:",notnull,type:uuid" json:"-"`
+		AccountID     string        `bun:",notnull,type:uuid" json:"-"`
+		TransactionID string        `bun:",notnull,type:uuid" json:"-"`
+		Code          string        `bun:"-" json:"code"`
+		Amount        points.Points `bun:",notnull" json:"sum"`
+		CreatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"processed_at"`
+	}
+)
+
+// Validate checks that the promo code credits points and can be redeemed at least once.
+func (p PromoCode) Validate() error {
+	switch {
+	case p.Code == "":
+		return errors.New("promo code must not be empty")
+	case p.Amount <= 0:
+		return errors.New("promo code sum must be positive")
+	case p.MaxRedemptions < 1:
+		return errors.New("promo code must be redeemable at least once")
+	case p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt):
+		return errors.New("promo code must end after it starts")
+	default:
+		return nil
+	}
+}
+
+// Active returns true if the promo code has not been disabled and can be redeemed at the moment.
+func (p PromoCode) Active(now time.Time) bool {
+	return p.DisabledAt == nil &&
+		(p.StartsAt == nil || !now.Before(*p.StartsAt)) &&
+		(p.EndsAt == nil || now.Before(*p.EndsAt))
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// Referral links the user registered with a referral code (referee) to the owner of the code (referrer).
+	// Both of them get a bonus when the first order of the referee is processed.
+	Referral struct {
+		ID            string         `bun:",pk,type:uuid" json:"-"`
+		ReferrerID    string         `bun:",notnull,type:uuid" json:"-"`
+		RefereeID     string         `bun:",unique,notnull,type:uuid" json:"-"`
+		Status        ReferralStatus `bun:",notnull" json:"status"`
+		OrderNumber   string         `bun:",nullzero" json:"-"`
+		ReferrerBonus points.Points  `bun:",notnull,default:0" json:"bonus"`
+		RefereeBonus  points.Points  `bun:",notnull,default:0" json:"-"`
+		CreatedAt     time.Time      `bun:",notnull,default:current_timestamp" json:"registered_at"`
+		RewardedAt    *time.Time     `bun:",nullzero" json:"rewarded_at,omitempty"`
+
+		// Login is the login of the referee.
+		Login   string `bun:"-" json:"login"`
+		Referee *User  `bun:"rel:belongs-to,join:referee_id=id" json:"-"`
+	}
+
+	// ReferralSummary is the referral code of the user with referrals and bonuses earned for them.
+	ReferralSummary struct {
+		Code      string        `json:"code"`
+		Earned    points.Points `json:"earned"`
+		Referrals []Referral    `json:"referrals"`
+	}
+
+	// ReferralBonuses are points credited for the first processed order of the referee.
+	ReferralBonuses struct {
+		Referrer points.Points
+		Referee  points.Points
+	}
+
+	ReferralStatus string
+)
+
+const (
+	// ReferralStatusPending waits for the first processed order of the referee.
+	ReferralStatusPending ReferralStatus = "pending"
+	// ReferralStatusRewarded means bonuses have been credited.
+	ReferralStatusRewarded ReferralStatus = "rewarded"
+)
@@ -1,8 +1,11 @@
 package models
 
+import "github.com/madatsci/gophermart/pkg/points"
+
 type UserReristerRequest struct {
-	Login    string `json:"login"`
-	Password string `json:"password"`
+	Login        string `json:"login"`
+	Password     string `json:"password"`
+	ReferralCode string `json:"referral_code"`
 }
 
 type UserLoginRequest struct {
@@ -11,6 +14,30 @@ type UserLoginRequest struct {
 }
 
 type BalanceWithdrawRequest struct {
-	Order string  `json:"order"`
-	Sum   float32 `json:"sum"`
+	Order string        `json:"order"`
+	Sum   points.Points `json:"sum"`
+	// OrderTotal is required when the share of the order payable with points is limited.
+	OrderTotal points.Points `json:"order_total"`
+}
+
+type HoldRequest struct {
+	Order string        `json:"order"`
+	Sum   points.Points `json:"sum"`
+	// OrderTotal is required when the share of the order payable with points is limited.
+	OrderTotal points.Points `json:"order_total"`
+}
+
+type TransferRequest struct {
+	Recipient string        `json:"recipient"`
+	Sum       points.Points `json:"sum"`
+}
+
+type AdjustmentRequest struct {
+	Sum     points.Points    `json:"sum"`
+	Reason  AdjustmentReason `json:"reason"`
+	Comment string           `json:"comment"`
+}
+
+type PromoRequest struct {
+	Code string `json:"code"`
 }
@@ -0,0 +1,147 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+type (
+	// Tier is a membership tier of the account, it defines the multiplier of credited accruals.
+	Tier string
+
+	// TierRule defines points the account must accrue to reach the tier and the multiplier
+	// of accruals in the tier.
+	TierRule struct {
+		Tier      Tier          `json:"tier"`
+		Threshold points.Points `json:"threshold"`
+		// Multiplier has two decimal places, e.g. 1.25 credits 125% of the order accrual.
+		Multiplier points.Points `json:"multiplier"`
+	}
+
+	// TierPolicy defines how tiers are reached. Qualifying points are accruals of processed orders
+	// within the last WindowMonths months, zero window means the whole account history.
+	TierPolicy struct {
+		Rules        []TierRule
+		WindowMonths int
+	}
+
+	// TierStatus is the current tier of the account and its progress to the next one.
+	TierStatus struct {
+		Tier          Tier          `json:"tier"`
+		Multiplier    points.Points `json:"multiplier"`
+		Qualifying    points.Points `json:"qualifying"`
+		WindowMonths  int           `json:"window_months,omitempty"`
+		NextTier      Tier          `json:"next_tier,omitempty"`
+		NextThreshold points.Points `json:"next_threshold,omitempty"`
+		Remaining     points.Points `json:"remaining,omitempty"`
+		// Progress is the percentage of the way from the current tier threshold to the next one.
+		Progress int `json:"progress"`
+	}
+
+	// TierChange records the account moving from one tier to another.
+	TierChange struct {
+		ID         string        `bun:",pk,type:uuid" json:"-"`
+		AccountID  string        `bun:",notnull" json:"-"`
+		FromTier   Tier          `bun:",notnull" json:"from"`
+		ToTier     Tier          `bun:",notnull" json:"to"`
+		Qualifying points.Points `bun:",notnull" json:"qualifying"`
+		CreatedAt  time.Time     `bun:",notnull,default:current_timestamp" json:"changed_at"`
+	}
+)
+
+const (
+	TierBronze Tier = "bronze"
+	TierSilver Tier = "silver"
+	TierGold   Tier = "gold"
+)
+
+// Valid returns true if the tier is known.
+func (t Tier) Valid() bool {
+	switch t {
+	case TierBronze, TierSilver, TierGold:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rank orders tiers from bronze to gold, unknown tier has the lowest rank.
+func (t Tier) Rank() int {
+	switch t {
+	case TierBronze:
+		return 1
+	case TierSilver:
+		return 2
+	case TierGold:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// DefaultTierRules returns tier rules used unless they are configured: every account is bronze
+// and accruals are credited as is, higher tiers are enabled by configuring them.
+func DefaultTierRules() []TierRule {
+	return []TierRule{
+		{Tier: TierBronze, Threshold: 0, Multiplier: points.FromInt(1)},
+	}
+}
+
+// ParseTierRules parses rules in the form of "bronze:0:1,silver:1000:1.25,gold:5000:1.5"
+// where each rule is tier, threshold and multiplier.
+func ParseTierRules(s string) ([]TierRule, error) {
+	var rules []TierRule
+
+	for _, spec := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(spec), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid tier rule: %s", spec)
+		}
+
+		threshold, err := points.Parse(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold of tier %s: %s", parts[0], parts[1])
+		}
+		multiplier, err := points.Parse(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiplier of tier %s: %s", parts[0], parts[2])
+		}
+
+		rules = append(rules, TierRule{Tier: Tier(parts[0]), Threshold: threshold, Multiplier: multiplier})
+	}
+
+	if err := ValidateTierRules(rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// ValidateTierRules checks that rules start from bronze tier with zero threshold and go in the order
+// of increasing thresholds, each tier is listed once and multipliers are positive.
+func ValidateTierRules(rules []TierRule) error {
+	if len(rules) == 0 || rules[0].Tier != TierBronze || rules[0].Threshold != 0 {
+		return errors.New("tier rules must start with bronze tier with zero threshold")
+	}
+
+	seen := make(map[Tier]bool, len(rules))
+	for i, rule := range rules {
+		switch {
+		case !rule.Tier.Valid():
+			return fmt.Errorf("invalid tier: %s", rule.Tier)
+		case seen[rule.Tier]:
+			return fmt.Errorf("tier %s is listed twice", rule.Tier)
+		case rule.Multiplier <= 0:
+			return fmt.Errorf("multiplier of tier %s must be positive", rule.Tier)
+		case i > 0 && rule.Threshold <= rules[i-1].Threshold:
+			return fmt.Errorf("threshold of tier %s must be greater than threshold of tier %s", rule.Tier, rules[i-1].Tier)
+		}
+		seen[rule.Tier] = true
+	}
+
+	return nil
+}
@@ -2,25 +2,76 @@ package models
 
 import (
 	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
 )
 
 type (
 	Transaction struct {
-		ID          string      `bun:",pk,type:uuid" json:"-"`
-		AccountID   string      `bun:",notnull" json:"-"`
-		Amount      float32     `bun:",notnull" json:"sum"`
-		OrderNumber string      `bun:",notnull" json:"order"`
-		Direction   TxDirection `bun:",notnull" json:"-"`
-		CreatedAt   time.Time   `bun:",notnull,default:current_timestamp" json:"processed_at"`
-		UpdatedAt   time.Time   `bun:",notnull,default:current_timestamp" json:"-"`
+		ID          string           `bun:",pk,type:uuid" json:"-"`
+		AccountID   string           `bun:",notnull" json:"-"`
+		Amount      points.Points    `bun:",notnull" json:"sum"`
+		OrderNumber string           `bun:",notnull" json:"order"`
+		Direction   TxDirection      `bun:",notnull" json:"-"`
+		ParentID    string           `bun:",nullzero,type:uuid" json:"-"`
+		Reason      AdjustmentReason `bun:",nullzero" json:"reason,omitempty"`
+		Comment     string           `bun:",nullzero" json:"comment,omitempty"`
+		Operator    string           `bun:",nullzero" json:"-"`
+		// Tier is the tier which multiplier has been applied to the accrual.
+		Tier Tier `bun:",nullzero" json:"-"`
+		// CampaignID is the campaign applied to the accrual and CampaignBonus is the points it has added.
+		CampaignID    string        `bun:",nullzero,type:uuid" json:"-"`
+		CampaignBonus points.Points `bun:",nullzero" json:"-"`
+		CreatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"processed_at"`
+		UpdatedAt     time.Time     `bun:",notnull,default:current_timestamp" json:"-"`
 
 		Account Account `bun:"rel:belongs-to,join:account_id=id" json:"-"`
 	}
 
+	// StatementEntry is a transaction with the wallet balance right after it.
+	StatementEntry struct {
+		ID           string           `bun:"id" json:"-"`
+		Direction    TxDirection      `bun:"direction" json:"direction"`
+		OrderNumber  string           `bun:"order_number" json:"order"`
+		Amount       points.Points    `bun:"amount" json:"sum"`
+		BalanceAfter points.Points    `bun:"balance_after" json:"balance_after"`
+		Reason       AdjustmentReason `bun:"reason" json:"reason,omitempty"`
+		Comment      string           `bun:"comment" json:"comment,omitempty"`
+		CreatedAt    time.Time        `bun:"created_at" json:"processed_at"`
+	}
+
 	TxDirection string
 )
 
 const (
 	TxDirectionAccrual    TxDirection = "accrual"
 	TxDirectionWithdrawal TxDirection = "withdrawal"
+	// TxDirectionAdjustment is a manual credit or debit made by an operator, its amount may be negative.
+	TxDirectionAdjustment TxDirection = "adjustment"
+	TxDirectionExpiry     TxDirection = "expiry"
+	// TxDirectionRefund returns withdrawn points back, it is linked to the withdrawal by ParentID.
+	TxDirectionRefund TxDirection = "refund"
+	// TxDirectionClawback takes back accrual of a returned order, it is linked to the accrual by ParentID.
+	// Clawbacks settling a debt are not linked to any accrual.
+	TxDirectionClawback TxDirection = "clawback"
+	// TxDirectionTransferOut sends points to another user.
+	TxDirectionTransferOut TxDirection = "transfer_out"
+	// TxDirectionTransferIn receives points from another user, it is linked to the transfer_out by ParentID.
+	TxDirectionTransferIn TxDirection = "transfer_in"
+	// TxDirectionReferral is a bonus credited to the referrer and the referee for the first processed order of the referee.
+	TxDirectionReferral TxDirection = "referral"
+	// TxDirectionPromo credits points of a redeemed promo code, the code is kept in the comment.
+	TxDirectionPromo TxDirection = "promo"
 )
+
+// Valid returns true if the direction is known.
+func (d TxDirection) Valid() bool {
+	switch d {
+	case TxDirectionAccrual, TxDirectionWithdrawal, TxDirectionAdjustment, TxDirectionExpiry, TxDirectionRefund, TxDirectionClawback,
+		TxDirectionTransferOut, TxDirectionTransferIn, TxDirectionReferral,
+		TxDirectionPromo:
+		return true
+	default:
+		return false
+	}
+}
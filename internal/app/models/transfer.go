@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+// Transfer is points sent by the user to another user. ID is the ID of the sender's transaction.
+type Transfer struct {
+	ID        string        `json:"id"`
+	Recipient string        `json:"recipient"`
+	Amount    points.Points `json:"sum"`
+	CreatedAt time.Time     `json:"processed_at"`
+}
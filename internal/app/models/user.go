@@ -2,12 +2,46 @@ package models
 
 import "time"
 
-type User struct {
-	ID        string    `bun:",pk,type:uuid"`
-	Login     string    `bun:",unique,notnull"`
-	Password  string    `bun:",notnull"`
-	CreatedAt time.Time `bun:",notnull,default:current_timestamp"`
-	UpdatedAt time.Time `bun:",notnull,default:current_timestamp"`
-
-	Account *Account `bun:"rel:has-one,join:id=user_id"`
+type (
+	User struct {
+		ID       string `bun:",pk,type:uuid" json:"id"`
+		Login    string `bun:",unique,notnull" json:"login"`
+		Password string `bun:",notnull" json:"-"`
+		Role     Role   `bun:",notnull,default:'user'" json:"role"`
+		// ReferralCode is shared by the user to invite other users.
+		ReferralCode string    `bun:",unique,nullzero" json:"referral_code,omitempty"`
+		CreatedAt    time.Time `bun:",notnull,default:current_timestamp" json:"created_at"`
+		UpdatedAt    time.Time `bun:",notnull,default:current_timestamp" json:"-"`
+
+		Account *Account `bun:"rel:has-one,join:id=user_id" json:"-"`
+	}
+
+	// UserInfo is the user with the state of the account shown in admin API.
+	UserInfo struct {
+		User
+		AccountID string     `json:"account_id"`
+		FrozenAt  *time.Time `json:"frozen_at,omitempty"`
+	}
+
+	// Role defines which API the user has access to.
+	Role string
+)
+
+const (
+	// RoleUser has access to the private API of own account only.
+	RoleUser Role = "user"
+	// RoleSupport has access to admin API to look up and freeze accounts.
+	RoleSupport Role = "support"
+	// RoleAdmin has full access to admin API.
+	RoleAdmin Role = "admin"
+)
+
+// Valid returns true if the role is known.
+func (r Role) Valid() bool {
+	switch r {
+	case RoleUser, RoleSupport, RoleAdmin:
+		return true
+	default:
+		return false
+	}
 }
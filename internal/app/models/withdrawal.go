@@ -0,0 +1,55 @@
+package models
+
+import "github.com/madatsci/gophermart/pkg/points"
+
+type (
+	// WithdrawalRules limit withdrawals of points, zero value of a rule means it is not applied.
+	WithdrawalRules struct {
+		MinSum points.Points
+		MaxSum points.Points
+		// DailyLimit and MonthlyLimit cap points withdrawn by the user within the last 24 hours and the last month.
+		DailyLimit   points.Points
+		MonthlyLimit points.Points
+		// MaxOrderShare is the maximum percentage of the order total which can be paid with points.
+		MaxOrderShare int
+		// Granularity requires sums to be its multiples, e.g. 1 allows only whole points.
+		Granularity points.Points
+	}
+
+	// WithdrawalViolation is a machine-readable code of the withdrawal rule which has been violated.
+	WithdrawalViolation string
+)
+
+const (
+	WithdrawalViolationMinSum       WithdrawalViolation = "sum_below_minimum"
+	WithdrawalViolationMaxSum       WithdrawalViolation = "sum_above_maximum"
+	WithdrawalViolationGranularity  WithdrawalViolation = "invalid_sum_granularity"
+	WithdrawalViolationOrderTotal   WithdrawalViolation = "order_total_required"
+	WithdrawalViolationOrderShare   WithdrawalViolation = "order_share_exceeded"
+	WithdrawalViolationDailyLimit   WithdrawalViolation = "daily_limit_exceeded"
+	WithdrawalViolationMonthlyLimit WithdrawalViolation = "monthly_limit_exceeded"
+)
+
+// Check applies rules which do not depend on previous withdrawals of the user. It returns the violated
+// rule and its limit, or an empty violation if the withdrawal is allowed. Order total is required only
+// when the maximum order share is set.
+func (r WithdrawalRules) Check(sum points.Points, orderTotal points.Points) (WithdrawalViolation, points.Points) {
+	switch {
+	case r.MinSum > 0 && sum < r.MinSum:
+		return WithdrawalViolationMinSum, r.MinSum
+	case r.MaxSum > 0 && sum > r.MaxSum:
+		return WithdrawalViolationMaxSum, r.MaxSum
+	case r.Granularity > 0 && sum.Minor()%r.Granularity.Minor() != 0:
+		return WithdrawalViolationGranularity, r.Granularity
+	case r.MaxOrderShare > 0 && orderTotal <= 0:
+		return WithdrawalViolationOrderTotal, 0
+	}
+
+	if r.MaxOrderShare > 0 {
+		if limit := points.FromMinor(orderTotal.Minor() * int64(r.MaxOrderShare) / 100); sum > limit {
+			return WithdrawalViolationOrderShare, limit
+		}
+	}
+
+	return "", 0
+}
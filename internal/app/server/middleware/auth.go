@@ -2,28 +2,38 @@ package middleware
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"net/http"
+	"slices"
 
 	"github.com/madatsci/gophermart/internal/app/config"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
 	"github.com/madatsci/gophermart/pkg/jwt"
 	"go.uber.org/zap"
 )
 
-// AuthenticatedUserKey should be used to read userID from context.
-const AuthenticatedUserKey ctxKey = 0
+const (
+	// AuthenticatedUserKey should be used to read userID from context.
+	AuthenticatedUserKey ctxKey = iota
+	// AuthenticatedRoleKey should be used to read role of the authenticated user from context,
+	// it is set by RequireRole from the stored user.
+	AuthenticatedRoleKey
+)
 
 type (
 	Auth struct {
 		cookieName string
 		jwt        *jwt.JWT
+		store      store.Store
 		log        *zap.SugaredLogger
-		userID     string
 	}
 
 	Options struct {
 		Config *config.Config
 		JWT    *jwt.JWT
+		Store  store.Store
 		Log    *zap.SugaredLogger
 	}
 
@@ -35,6 +45,7 @@ func NewAuth(opts Options) *Auth {
 	return &Auth{
 		cookieName: opts.Config.AuthCookieName,
 		jwt:        opts.JWT,
+		store:      opts.Store,
 		log:        opts.Log,
 	}
 }
@@ -53,28 +64,60 @@ func (a *Auth) PrivateAPIAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		userID, err := a.jwt.GetUserID(cookie.Value)
+		claims, err := a.jwt.GetClaims(cookie.Value)
 		if err != nil {
 			a.handleUnauthorized(w, err)
 			return
 		}
-		if userID == "" {
+		if claims.UserID == "" {
 			a.handleUnauthorized(w, errors.New("token does not contain user ID"))
 			return
 		}
 
-		a.userID = userID
-		a.continueWithUser(w, r, next)
+		a.continueWithUser(w, r, next, claims)
 	})
 }
 
+// RequireRole ensures that the authenticated user has one of the roles. It must be used after PrivateAPIAuth.
+// The role is read from the store rather than the token, so that a role change applies to tokens
+// which have already been issued.
+func (a *Auth) RequireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(AuthenticatedUserKey).(string)
+
+			user, err := a.store.GetUserByID(r.Context(), userID)
+			if err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					a.handleUnauthorized(w, errors.New("user does not exist"))
+					return
+				}
+
+				a.log.With("userID", userID, "err", err).Errorln("could not get role of the user")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if !slices.Contains(roles, user.Role) {
+				a.log.With("userID", userID, "role", user.Role).
+					Debug("forbidden attempt to access admin API")
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), AuthenticatedRoleKey, user.Role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
 func (a *Auth) handleUnauthorized(w http.ResponseWriter, err error) {
 	a.log.Debugf("unauthorized attempt to access private API: %s", err)
 	w.WriteHeader(http.StatusUnauthorized)
 }
 
-func (a *Auth) continueWithUser(w http.ResponseWriter, r *http.Request, next http.Handler) {
-	a.log.With("userID", a.userID).Debug("request authorized with user")
-	ctx := context.WithValue(r.Context(), AuthenticatedUserKey, a.userID)
+func (a *Auth) continueWithUser(w http.ResponseWriter, r *http.Request, next http.Handler, claims jwt.Claims) {
+	a.log.With("userID", claims.UserID).Debug("request authorized with user")
+	ctx := context.WithValue(r.Context(), AuthenticatedUserKey, claims.UserID)
 	next.ServeHTTP(w, r.WithContext(ctx))
 }
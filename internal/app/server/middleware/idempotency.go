@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"go.uber.org/zap"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header with client-generated idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored result.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+type Idempotency struct {
+	store store.Store
+	ttl   time.Duration
+	log   *zap.SugaredLogger
+}
+
+// NewIdempotency creates new idempotency middleware. Stored responses expire after ttl.
+func NewIdempotency(store store.Store, ttl time.Duration, log *zap.SugaredLogger) *Idempotency {
+	return &Idempotency{store: store, ttl: ttl, log: log}
+}
+
+// Idempotent replays the stored response if the request with the same Idempotency-Key
+// has already been handled for the authenticated user. Must be used after PrivateAPIAuth.
+func (i *Idempotency) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		userID, ok := r.Context().Value(AuthenticatedUserKey).(string)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: requestHash(r, body),
+			CreatedAt:   time.Now(),
+			ExpiresAt:   time.Now().Add(i.ttl),
+		}
+
+		existing, reserved, err := i.reserve(r, record)
+		if err != nil {
+			i.log.With("key", key, "err", err).Errorln("could not reserve idempotency key")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !reserved {
+			i.replay(w, record, existing)
+			return
+		}
+
+		// the request context is canceled when the client goes away, which is exactly when
+		// the response must be saved for the retry
+		ctx := context.WithoutCancel(r.Context())
+		defer func() {
+			if p := recover(); p != nil {
+				i.release(ctx, userID, key)
+				panic(p)
+			}
+		}()
+
+		rec := &recordingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= http.StatusInternalServerError {
+			i.release(ctx, userID, key)
+			return
+		}
+
+		record.StatusCode = rec.status
+		record.ContentType = w.Header().Get("Content-Type")
+		record.ResponseBody = rec.body.Bytes()
+		if err := i.store.CompleteIdempotencyKey(ctx, record); err != nil {
+			i.log.With("key", key, "err", err).Errorln("could not save idempotent response")
+		}
+	})
+}
+
+// release deletes the key of the failed request so that it can be retried.
+func (i *Idempotency) release(ctx context.Context, userID, key string) {
+	if err := i.store.DeleteIdempotencyKey(ctx, userID, key); err != nil {
+		i.log.With("key", key, "err", err).Errorln("could not release idempotency key")
+	}
+}
+
+// reserve saves the key unless it already exists. An expired key is replaced.
+func (i *Idempotency) reserve(r *http.Request, record models.IdempotencyKey) (models.IdempotencyKey, bool, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		err := i.store.CreateIdempotencyKey(r.Context(), record)
+		if err == nil {
+			return record, true, nil
+		}
+
+		var sErr store.StoreError
+		if !errors.As(err, &sErr) || !sErr.IntegrityViolation() {
+			return record, false, err
+		}
+
+		existing, err := i.store.GetIdempotencyKey(r.Context(), record.UserID, record.Key)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return record, false, err
+		}
+		if existing.ExpiresAt.After(time.Now()) {
+			return existing, false, nil
+		}
+
+		if err = i.store.DeleteIdempotencyKey(r.Context(), record.UserID, record.Key); err != nil {
+			return record, false, err
+		}
+	}
+
+	return record, false, errors.New("idempotency key is concurrently modified")
+}
+
+func (i *Idempotency) replay(w http.ResponseWriter, record, existing models.IdempotencyKey) {
+	if existing.RequestHash != record.RequestHash {
+		i.log.With("key", record.Key).Debug("idempotency key reused with different request")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	if !existing.Completed() {
+		i.log.With("key", record.Key).Debug("request with idempotency key is in progress")
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	if existing.ContentType != "" {
+		w.Header().Set("Content-Type", existing.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(existing.StatusCode)
+	w.Write(existing.ResponseBody) //nolint:errcheck
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+	return hex.EncodeToString(hash[:])
+}
+
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recordingResponseWriter) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recordingResponseWriter) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,217 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIdempotent(t *testing.T) {
+	s := memory.New()
+	userID := uuid.NewString()
+
+	calls := 0
+	status := http.StatusOK
+	handler := NewIdempotency(s, time.Hour, zap.NewNop().Sugar()).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"current":400,"withdrawn":100}`)) //nolint:errcheck
+	}))
+
+	send := func(key, body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), AuthenticatedUserKey, userID))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+
+		r := httptest.NewRecorder()
+		handler.ServeHTTP(r, req)
+
+		return r.Result()
+	}
+
+	body := `{"order":"1234567890003","sum":100}`
+
+	t.Run("repeated request is replayed", func(t *testing.T) {
+		calls = 0
+
+		resp := send("key-1", body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp = send("key-1", body)
+		defer resp.Body.Close()
+
+		assert.Equal(t, 1, calls, "handler must be called once")
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get(IdempotentReplayedHeader))
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+		respBody, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"current":400,"withdrawn":100}`, string(respBody))
+	})
+
+	t.Run("key reused with different request", func(t *testing.T) {
+		resp := send("key-1", `{"order":"1234567890003","sum":200}`)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	})
+
+	t.Run("request in progress", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", http.NoBody)
+		err := s.CreateIdempotencyKey(context.Background(), models.IdempotencyKey{
+			UserID:      userID,
+			Key:         "key-2",
+			RequestHash: requestHash(req, []byte(body)),
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		require.NoError(t, err)
+
+		resp := send("key-2", body)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("failed request can be retried", func(t *testing.T) {
+		calls = 0
+		status = http.StatusInternalServerError
+
+		resp := send("key-3", body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+
+		status = http.StatusOK
+		resp = send("key-3", body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("expired key is replaced", func(t *testing.T) {
+		calls = 0
+		err := s.CreateIdempotencyKey(context.Background(), models.IdempotencyKey{
+			UserID:      userID,
+			Key:         "key-4",
+			RequestHash: "hash",
+			StatusCode:  http.StatusPaymentRequired,
+			ExpiresAt:   time.Now().Add(-time.Minute),
+		})
+		require.NoError(t, err)
+
+		resp := send("key-4", body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		calls = 0
+
+		for i := 0; i < 2; i++ {
+			resp := send("", body)
+			resp.Body.Close()
+		}
+
+		assert.Equal(t, 2, calls)
+	})
+}
+
+// contextStore fails on canceled context like the database store does.
+type contextStore struct {
+	*memory.Store
+}
+
+func (s contextStore) CompleteIdempotencyKey(ctx context.Context, record models.IdempotencyKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.CompleteIdempotencyKey(ctx, record)
+}
+
+func (s contextStore) DeleteIdempotencyKey(ctx context.Context, userID, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Store.DeleteIdempotencyKey(ctx, userID, key)
+}
+
+func TestIdempotentInterruptedRequest(t *testing.T) {
+	s := contextStore{memory.New()}
+	userID := uuid.NewString()
+
+	var (
+		calls   int
+		handler http.HandlerFunc
+	)
+	idempotent := NewIdempotency(s, time.Hour, zap.NewNop().Sugar()).Idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		handler(w, r)
+	}))
+
+	send := func(ctx context.Context, key string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(`{"order":"1234567890003","sum":100}`))
+		req = req.WithContext(context.WithValue(ctx, AuthenticatedUserKey, userID))
+		req.Header.Set(IdempotencyKeyHeader, key)
+
+		r := httptest.NewRecorder()
+		idempotent.ServeHTTP(r, req)
+
+		return r.Result()
+	}
+
+	t.Run("response is saved when client goes away", func(t *testing.T) {
+		calls = 0
+		ctx, cancel := context.WithCancel(context.Background())
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			w.WriteHeader(http.StatusOK)
+		}
+
+		resp := send(ctx, "key-1")
+		resp.Body.Close()
+
+		resp = send(context.Background(), "key-1")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "true", resp.Header.Get(IdempotentReplayedHeader))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("panicked request can be retried", func(t *testing.T) {
+		calls = 0
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}
+
+		assert.Panics(t, func() {
+			send(context.Background(), "key-2").Body.Close()
+		})
+
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		resp := send(context.Background(), "key-2")
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get(IdempotentReplayedHeader))
+		assert.Equal(t, 2, calls)
+	})
+}
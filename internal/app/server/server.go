@@ -7,6 +7,7 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/madatsci/gophermart/internal/app/config"
 	"github.com/madatsci/gophermart/internal/app/handlers"
+	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/store"
 	"github.com/madatsci/gophermart/pkg/jwt"
 	"go.uber.org/zap"
@@ -15,10 +16,11 @@ import (
 )
 
 type Server struct {
-	mux    http.Handler
-	config *config.Config
-	h      *handlers.Handlers
-	log    *zap.SugaredLogger
+	mux      http.Handler
+	adminMux http.Handler
+	config   *config.Config
+	h        *handlers.Handlers
+	log      *zap.SugaredLogger
 }
 
 func New(config *config.Config, store store.Store, logger *zap.SugaredLogger) *Server {
@@ -43,9 +45,43 @@ func New(config *config.Config, store store.Store, logger *zap.SugaredLogger) *S
 	authMiddleware := mw.NewAuth(mw.Options{
 		Config: config,
 		JWT:    jwt,
+		Store:  store,
 		Log:    logger,
 	})
 
+	idempotencyMiddleware := mw.NewIdempotency(store, config.IdempotencyKeyTTL, logger)
+
+	// Admin API for support staff, the user is chosen by login
+	adminAPI := func(r chi.Router) {
+		r.Use(authMiddleware.PrivateAPIAuth)
+		r.Use(authMiddleware.RequireRole(models.RoleSupport, models.RoleAdmin))
+		r.Route("/users/{login}", func(r chi.Router) {
+			r.Use(h.SubjectUser)
+			r.Get("/", h.GetUser)
+			r.Get("/balance", h.GetBalance)
+			r.Get("/orders", h.GetOrders)
+			r.Get("/withdrawals", h.GetWithdrawals)
+			r.Get("/transactions", h.GetTransactions)
+			r.Get("/tier", h.GetTier)
+			r.Get("/tier/history", h.GetTierHistory)
+			r.Get("/referrals", h.GetReferrals)
+			r.Post("/freeze", h.FreezeAccount)
+			r.Post("/unfreeze", h.UnfreezeAccount)
+			r.With(idempotencyMiddleware.Idempotent).Post("/adjustments", h.AdjustBalance)
+		})
+	}
+
+	var adminMux http.Handler
+	if config.AdminRunAddress != "" {
+		ar := chi.NewRouter()
+		ar.Use(loggerMiddleware.Logger)
+		ar.Use(middleware.Recoverer)
+		ar.Route("/api/admin", adminAPI)
+		adminMux = ar
+	} else {
+		r.Route("/api/admin", adminAPI)
+	}
+
 	r.Route("/", func(r chi.Router) {
 		// Public API
 		r.Post("/api/user/register", h.RegisterUser)
@@ -62,28 +98,67 @@ func New(config *config.Config, store store.Store, logger *zap.SugaredLogger) *S
 		r.Route("/api/user/balance", func(r chi.Router) {
 			r.Use(authMiddleware.PrivateAPIAuth)
 			r.Get("/", h.GetBalance)
-			r.Post("/withdraw", h.WithdrawPoints)
+			r.With(idempotencyMiddleware.Idempotent).Post("/withdraw", h.WithdrawPoints)
+			r.With(idempotencyMiddleware.Idempotent).Post("/transfer", h.TransferPoints)
+			r.With(idempotencyMiddleware.Idempotent).Post("/holds", h.CreateHold)
+			r.Post("/holds/{id}/capture", h.CaptureHold)
+			r.Post("/holds/{id}/void", h.VoidHold)
 		})
 		// Withdrawals
 		r.Route("/api/user/withdrawals", func(r chi.Router) {
 			r.Use(authMiddleware.PrivateAPIAuth)
 			r.Get("/", h.GetWithdrawals)
 		})
+		// Transactions
+		r.Route("/api/user/transactions", func(r chi.Router) {
+			r.Use(authMiddleware.PrivateAPIAuth)
+			r.Get("/", h.GetTransactions)
+		})
+		// Promo codes
+		r.Route("/api/user/promo", func(r chi.Router) {
+			r.Use(authMiddleware.PrivateAPIAuth)
+			r.With(idempotencyMiddleware.Idempotent).Post("/", h.RedeemPromoCode)
+		})
+		// Referrals
+		r.Route("/api/user/referrals", func(r chi.Router) {
+			r.Use(authMiddleware.PrivateAPIAuth)
+			r.Get("/", h.GetReferrals)
+		})
+		// Tiers
+		r.Route("/api/user/tier", func(r chi.Router) {
+			r.Use(authMiddleware.PrivateAPIAuth)
+			r.Get("/", h.GetTier)
+			r.Get("/history", h.GetTierHistory)
+		})
 	})
 
 	server := &Server{
-		mux:    r,
-		config: config,
-		h:      h,
-		log:    logger,
+		mux:      r,
+		adminMux: adminMux,
+		config:   config,
+		h:        h,
+		log:      logger,
 	}
 
 	return server
 }
 
-// Start starts the server under the specified address.
+// Start starts the server under the specified address. If admin API has its own address,
+// it is served by a separate listener and Start returns when either of them fails.
 func (s *Server) Start() error {
 	s.log.Infof("starting server with config: %+v", s.config)
 
-	return http.ListenAndServe(s.config.RunAddress, s.mux)
+	if s.adminMux == nil {
+		return http.ListenAndServe(s.config.RunAddress, s.mux)
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- http.ListenAndServe(s.config.AdminRunAddress, s.adminMux)
+	}()
+	go func() {
+		errc <- http.ListenAndServe(s.config.RunAddress, s.mux)
+	}()
+
+	return <-errc
 }
@@ -1,15 +1,21 @@
 package server
 
 import (
+	"context"
+	"database/sql"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
 	"github.com/madatsci/gophermart/internal/app/config"
 	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/store/database/mocks"
+	"github.com/madatsci/gophermart/pkg/jwt"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -90,8 +96,113 @@ func TestLoginUserHandler(t *testing.T) {
 	// TODO implement.
 }
 
+func TestAdminAPIAccess(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockStore(ctrl)
+	s := testServer(m)
+	defer s.Close()
+
+	user := models.User{ID: uuid.NewString(), Login: "john_doe", Role: models.RoleUser}
+	acc := models.Account{ID: uuid.NewString(), UserID: user.ID}
+
+	operatorID := uuid.NewString()
+
+	send := func(method, path string) *http.Response {
+		token, err := testJWT().GetString(operatorID)
+		require.NoError(t, err)
+
+		req, err := http.NewRequest(method, s.URL+path, strings.NewReader(`{"sum":10,"reason":"goodwill","comment":"late delivery"}`))
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: token})
+
+		return sendRequest(t, req)
+	}
+	operatorRole := func(role models.Role) *gomock.Call {
+		return m.EXPECT().GetUserByID(gomock.Any(), operatorID).Return(models.User{ID: operatorID, Role: role}, nil)
+	}
+
+	t.Run("user is forbidden", func(t *testing.T) {
+		operatorRole(models.RoleUser)
+
+		resp := send(http.MethodGet, "/api/admin/users/john_doe")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "Unexpected response code")
+	})
+
+	t.Run("role change applies to issued token", func(t *testing.T) {
+		operatorRole(models.RoleUser)
+
+		resp := send(http.MethodGet, "/api/admin/users/john_doe")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, "Unexpected response code")
+
+		operatorRole(models.RoleSupport)
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+
+		resp = send(http.MethodGet, "/api/admin/users/john_doe")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected response code")
+	})
+
+	t.Run("unknown operator is unauthorized", func(t *testing.T) {
+		m.EXPECT().GetUserByID(gomock.Any(), operatorID).Return(models.User{}, sql.ErrNoRows)
+
+		resp := send(http.MethodGet, "/api/admin/users/john_doe")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Unexpected response code")
+	})
+
+	t.Run("support looks up user", func(t *testing.T) {
+		operatorRole(models.RoleSupport)
+		m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+		m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+
+		resp := send(http.MethodGet, "/api/admin/users/john_doe")
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected response code")
+	})
+
+	for _, role := range []models.Role{models.RoleSupport, models.RoleAdmin} {
+		t.Run(string(role)+" adjusts balance", func(t *testing.T) {
+			operatorRole(role)
+			m.EXPECT().GetUserByLogin(gomock.Any(), "john_doe").Return(user, nil)
+			m.EXPECT().GetAccountByUserID(gomock.Any(), user.ID).Return(acc, nil)
+			m.EXPECT().AdjustBalance(gomock.Any(), gomock.Any()).DoAndReturn(
+				func(_ context.Context, adjustment models.Adjustment) (models.Transaction, error) {
+					assert.Equal(t, acc.ID, adjustment.AccountID)
+					assert.Equal(t, points.FromInt(10), adjustment.Amount)
+					assert.Equal(t, models.AdjustmentReasonGoodwill, adjustment.Reason)
+					assert.Equal(t, operatorID, adjustment.Operator)
+					return models.Transaction{}, nil
+				})
+
+			resp := send(http.MethodPost, "/api/admin/users/john_doe/adjustments")
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusCreated, resp.StatusCode, "Unexpected response code")
+		})
+	}
+}
+
+func testJWT() *jwt.JWT {
+	return jwt.New(jwt.Options{
+		Secret:   []byte("secret_key"),
+		Duration: time.Hour,
+	})
+}
+
 func testServer(m *mocks.MockStore) *httptest.Server {
-	config := &config.Config{}
+	config := &config.Config{
+		TokenSecret:    []byte("secret_key"),
+		TokenDuration:  time.Hour,
+		AuthCookieName: "auth_token",
+	}
 	logger := zap.NewNop().Sugar()
 	s := New(config, m, logger)
 
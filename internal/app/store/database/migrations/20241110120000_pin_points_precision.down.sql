@@ -0,0 +1,15 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE transactions ALTER COLUMN amount TYPE decimal;
+
+--bun:split
+
+ALTER TABLE orders ALTER COLUMN accrual TYPE decimal;
+
+--bun:split
+
+ALTER TABLE accounts
+ALTER COLUMN current_points_total TYPE decimal,
+ALTER COLUMN withdrawn_total TYPE decimal;
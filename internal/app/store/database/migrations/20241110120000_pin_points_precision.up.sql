@@ -0,0 +1,15 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE accounts
+ALTER COLUMN current_points_total TYPE numeric(19,2),
+ALTER COLUMN withdrawn_total TYPE numeric(19,2);
+
+--bun:split
+
+ALTER TABLE orders ALTER COLUMN accrual TYPE numeric(19,2);
+
+--bun:split
+
+ALTER TABLE transactions ALTER COLUMN amount TYPE numeric(19,2);
@@ -0,0 +1,10 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+-- zero accruals were written for intermediate status changes and carry no points
+DELETE FROM transactions WHERE direction = 'accrual' AND amount = 0;
+
+--bun:split
+
+CREATE UNIQUE INDEX transactions_accrual_order_number_idx ON transactions(order_number) WHERE direction = 'accrual';
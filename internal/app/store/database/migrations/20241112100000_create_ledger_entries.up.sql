@@ -0,0 +1,49 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE ledger_entries (
+    id uuid PRIMARY KEY,
+    transaction_id uuid NOT NULL,
+    ledger_account character varying(255) NOT NULL,
+    account_id uuid,
+    amount numeric(19,2) NOT NULL,
+    created_at timestamp without time zone NOT NULL
+);
+
+--bun:split
+
+ALTER TABLE ledger_entries ADD CONSTRAINT transaction_id_constraint FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+--bun:split
+
+ALTER TABLE ledger_entries ADD CONSTRAINT account_id_constraint FOREIGN KEY (account_id) REFERENCES accounts(id);
+
+--bun:split
+
+ALTER TABLE ledger_entries ADD CONSTRAINT wallet_account_id_check CHECK ((ledger_account = 'wallet') = (account_id IS NOT NULL));
+
+--bun:split
+
+CREATE INDEX ledger_entries_transaction_id_idx ON ledger_entries(transaction_id);
+
+--bun:split
+
+CREATE INDEX ledger_entries_account_created_at_idx ON ledger_entries(account_id, created_at) WHERE ledger_account = 'wallet';
+
+--bun:split
+
+-- backfill postings for transactions recorded before the ledger existed
+INSERT INTO ledger_entries (id, transaction_id, ledger_account, account_id, amount, created_at)
+SELECT gen_random_uuid(), t.id, e.ledger_account, e.account_id, e.amount, t.created_at
+FROM transactions t
+CROSS JOIN LATERAL (
+    VALUES
+        (CASE t.direction WHEN 'accrual' THEN 'program' ELSE 'wallet' END,
+         CASE t.direction WHEN 'accrual' THEN NULL ELSE t.account_id END,
+         -t.amount),
+        (CASE t.direction WHEN 'accrual' THEN 'wallet' ELSE 'redemption' END,
+         CASE t.direction WHEN 'accrual' THEN t.account_id ELSE NULL END,
+         t.amount)
+) AS e(ledger_account, account_id, amount)
+WHERE t.direction IN ('accrual', 'withdrawal');
@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX transactions_withdrawal_order_number_idx;
+
+--bun:split
+
+DROP TABLE idempotency_keys;
@@ -0,0 +1,50 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE idempotency_keys (
+    user_id uuid NOT NULL,
+    key character varying(255) NOT NULL,
+    request_hash character varying(64) NOT NULL,
+    status_code int,
+    content_type character varying(255),
+    response_body bytea,
+    created_at timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    PRIMARY KEY (user_id, key)
+);
+
+--bun:split
+
+ALTER TABLE idempotency_keys ADD CONSTRAINT user_id_constraint FOREIGN KEY (user_id) REFERENCES users(id);
+
+--bun:split
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys(expires_at);
+
+--bun:split
+
+-- concurrent retries could pay the same order more than once from one account, such withdrawals
+-- have to be resolved by an operator before the unique index can be created
+DO $$
+DECLARE
+    conflicts text;
+BEGIN
+    SELECT string_agg(format('account %s, order %s: withdrawals %s', account_id, order_number, ids), E'\n')
+    INTO conflicts
+    FROM (
+        SELECT account_id, order_number, string_agg(id::text, ', ' ORDER BY created_at, id) AS ids
+        FROM transactions
+        WHERE direction = 'withdrawal'
+        GROUP BY account_id, order_number
+        HAVING count(*) > 1
+    ) d;
+
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'repeated withdrawals of the same order must be resolved before migrating: %', E'\n' || conflicts;
+    END IF;
+END $$;
+
+--bun:split
+
+CREATE UNIQUE INDEX transactions_withdrawal_order_number_idx ON transactions(account_id, order_number) WHERE direction = 'withdrawal';
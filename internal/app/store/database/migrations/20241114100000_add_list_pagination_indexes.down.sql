@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX transactions_account_direction_created_at_id_idx;
+
+--bun:split
+
+DROP INDEX orders_account_created_at_id_idx;
@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE INDEX orders_account_created_at_id_idx ON orders(account_id, created_at, id);
+
+--bun:split
+
+CREATE INDEX transactions_account_direction_created_at_id_idx ON transactions(account_id, direction, created_at, id);
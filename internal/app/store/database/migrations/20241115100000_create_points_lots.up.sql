@@ -0,0 +1,42 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE points_lots (
+    id uuid PRIMARY KEY,
+    account_id uuid NOT NULL,
+    transaction_id uuid,
+    amount numeric(19,2) NOT NULL,
+    remaining numeric(19,2) NOT NULL,
+    expires_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+--bun:split
+
+ALTER TABLE points_lots ADD CONSTRAINT account_id_constraint FOREIGN KEY (account_id) REFERENCES accounts(id);
+
+--bun:split
+
+ALTER TABLE points_lots ADD CONSTRAINT transaction_id_constraint FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+--bun:split
+
+ALTER TABLE points_lots ADD CONSTRAINT remaining_check CHECK (remaining >= 0 AND remaining <= amount);
+
+--bun:split
+
+CREATE INDEX points_lots_account_expires_at_idx ON points_lots(account_id, expires_at) WHERE remaining > 0;
+
+--bun:split
+
+CREATE INDEX points_lots_expires_at_idx ON points_lots(expires_at) WHERE remaining > 0;
+
+--bun:split
+
+-- points credited before lots existed are kept as a single lot per account which never expires
+INSERT INTO points_lots (id, account_id, amount, remaining, created_at, updated_at)
+SELECT gen_random_uuid(), id, current_points_total, current_points_total, now(), now()
+FROM accounts
+WHERE current_points_total > 0;
@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP TABLE holds;
+
+--bun:split
+
+ALTER TABLE accounts DROP COLUMN held_total;
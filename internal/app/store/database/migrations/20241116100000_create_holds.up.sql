@@ -0,0 +1,40 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE accounts ADD COLUMN held_total numeric(19,2) NOT NULL DEFAULT 0;
+
+--bun:split
+
+CREATE TABLE holds (
+    id uuid PRIMARY KEY,
+    account_id uuid NOT NULL,
+    order_number character varying(255) NOT NULL,
+    amount numeric(19,2) NOT NULL,
+    status character varying(255) NOT NULL,
+    transaction_id uuid,
+    expires_at timestamp without time zone NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+--bun:split
+
+ALTER TABLE holds ADD CONSTRAINT account_id_constraint FOREIGN KEY (account_id) REFERENCES accounts(id);
+
+--bun:split
+
+ALTER TABLE holds ADD CONSTRAINT transaction_id_constraint FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+--bun:split
+
+ALTER TABLE holds ADD CONSTRAINT amount_check CHECK (amount > 0);
+
+--bun:split
+
+-- an order can be reserved only once unless the previous hold has been released
+CREATE UNIQUE INDEX holds_order_number_idx ON holds(order_number) WHERE status IN ('active', 'captured');
+
+--bun:split
+
+CREATE INDEX holds_expires_at_idx ON holds(expires_at) WHERE status = 'active';
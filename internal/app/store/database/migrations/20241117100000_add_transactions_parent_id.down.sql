@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX transactions_parent_id_idx;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN parent_id;
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN parent_id uuid;
+
+--bun:split
+
+ALTER TABLE transactions ADD CONSTRAINT parent_id_constraint FOREIGN KEY (parent_id) REFERENCES transactions(id);
+
+--bun:split
+
+CREATE INDEX transactions_parent_id_idx ON transactions(parent_id) WHERE parent_id IS NOT NULL;
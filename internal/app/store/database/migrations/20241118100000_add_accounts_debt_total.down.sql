@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX transactions_clawback_parent_id_idx;
+
+--bun:split
+
+ALTER TABLE accounts DROP COLUMN debt_total;
@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE accounts ADD COLUMN debt_total numeric(19,2) NOT NULL DEFAULT 0;
+
+--bun:split
+
+CREATE UNIQUE INDEX transactions_clawback_parent_id_idx ON transactions(parent_id) WHERE direction = 'clawback';
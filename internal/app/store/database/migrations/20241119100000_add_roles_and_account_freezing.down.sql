@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN comment;
+
+--bun:split
+
+ALTER TABLE accounts DROP COLUMN frozen_at;
+
+--bun:split
+
+ALTER TABLE users DROP COLUMN role;
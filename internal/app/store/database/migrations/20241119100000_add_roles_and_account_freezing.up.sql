@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE users ADD COLUMN role character varying(32) NOT NULL DEFAULT 'user';
+
+--bun:split
+
+ALTER TABLE accounts ADD COLUMN frozen_at timestamp without time zone;
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN comment text;
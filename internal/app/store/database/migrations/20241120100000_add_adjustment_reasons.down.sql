@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE transactions DROP CONSTRAINT adjustment_reason_check;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN operator;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN reason;
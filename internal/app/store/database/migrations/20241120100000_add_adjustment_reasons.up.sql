@@ -0,0 +1,17 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN reason character varying(32);
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN operator character varying(255);
+
+--bun:split
+
+UPDATE transactions SET reason = 'other' WHERE direction = 'adjustment' AND reason IS NULL;
+
+--bun:split
+
+ALTER TABLE transactions ADD CONSTRAINT adjustment_reason_check CHECK ((direction = 'adjustment') = (reason IS NOT NULL));
@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX transactions_transfer_in_parent_id_idx;
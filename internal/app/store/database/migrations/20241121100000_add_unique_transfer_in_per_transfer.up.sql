@@ -0,0 +1,5 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE UNIQUE INDEX transactions_transfer_in_parent_id_idx ON transactions(parent_id) WHERE direction = 'transfer_in';
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP TABLE tier_changes;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN tier;
+
+--bun:split
+
+ALTER TABLE accounts DROP COLUMN tier;
@@ -0,0 +1,28 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE accounts ADD COLUMN tier character varying(32) NOT NULL DEFAULT 'bronze';
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN tier character varying(32);
+
+--bun:split
+
+CREATE TABLE tier_changes (
+    id uuid PRIMARY KEY,
+    account_id uuid NOT NULL,
+    from_tier character varying(32) NOT NULL,
+    to_tier character varying(32) NOT NULL,
+    qualifying numeric(19,2) NOT NULL,
+    created_at timestamp without time zone NOT NULL
+);
+
+--bun:split
+
+ALTER TABLE tier_changes ADD CONSTRAINT account_id_constraint FOREIGN KEY (account_id) REFERENCES accounts(id);
+
+--bun:split
+
+CREATE INDEX tier_changes_account_id_created_at_idx ON tier_changes(account_id, created_at);
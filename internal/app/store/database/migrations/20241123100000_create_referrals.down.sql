@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP TABLE referrals;
+
+--bun:split
+
+ALTER TABLE users DROP COLUMN referral_code;
@@ -0,0 +1,44 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE users ADD COLUMN referral_code character varying(32);
+
+--bun:split
+
+UPDATE users SET referral_code = upper(substr(md5(random()::text || id::text), 1, 8));
+
+--bun:split
+
+CREATE UNIQUE INDEX users_referral_code_idx ON users(referral_code);
+
+--bun:split
+
+CREATE TABLE referrals (
+    id uuid PRIMARY KEY,
+    referrer_id uuid NOT NULL,
+    referee_id uuid NOT NULL,
+    status character varying(32) NOT NULL,
+    order_number character varying(255),
+    referrer_bonus numeric(19,2) NOT NULL DEFAULT 0,
+    referee_bonus numeric(19,2) NOT NULL DEFAULT 0,
+    created_at timestamp without time zone NOT NULL,
+    rewarded_at timestamp without time zone,
+    CHECK (referrer_id <> referee_id)
+);
+
+--bun:split
+
+ALTER TABLE referrals ADD CONSTRAINT referrer_id_constraint FOREIGN KEY (referrer_id) REFERENCES users(id);
+
+--bun:split
+
+ALTER TABLE referrals ADD CONSTRAINT referee_id_constraint FOREIGN KEY (referee_id) REFERENCES users(id);
+
+--bun:split
+
+CREATE UNIQUE INDEX referrals_referee_id_idx ON referrals(referee_id);
+
+--bun:split
+
+CREATE INDEX referrals_referrer_id_idx ON referrals(referrer_id);
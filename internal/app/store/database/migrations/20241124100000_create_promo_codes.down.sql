@@ -0,0 +1,9 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP TABLE promo_redemptions;
+
+--bun:split
+
+DROP TABLE promo_codes;
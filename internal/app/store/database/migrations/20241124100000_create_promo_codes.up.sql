@@ -0,0 +1,49 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE promo_codes (
+    id uuid PRIMARY KEY,
+    code character varying(64) NOT NULL,
+    amount numeric(19,2) NOT NULL,
+    max_redemptions integer NOT NULL,
+    redemptions integer NOT NULL DEFAULT 0,
+    starts_at timestamp without time zone,
+    ends_at timestamp without time zone,
+    disabled_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    CHECK (amount > 0),
+    CHECK (redemptions <= max_redemptions)
+);
+
+--bun:split
+
+CREATE UNIQUE INDEX promo_codes_code_idx ON promo_codes(code);
+
+--bun:split
+
+CREATE TABLE promo_redemptions (
+    id uuid PRIMARY KEY,
+    promo_code_id uuid NOT NULL,
+    account_id uuid NOT NULL,
+    transaction_id uuid NOT NULL,
+    amount numeric(19,2) NOT NULL,
+    created_at timestamp without time zone NOT NULL
+);
+
+--bun:split
+
+ALTER TABLE promo_redemptions ADD CONSTRAINT promo_code_id_constraint FOREIGN KEY (promo_code_id) REFERENCES promo_codes(id);
+
+--bun:split
+
+ALTER TABLE promo_redemptions ADD CONSTRAINT account_id_constraint FOREIGN KEY (account_id) REFERENCES accounts(id);
+
+--bun:split
+
+ALTER TABLE promo_redemptions ADD CONSTRAINT transaction_id_constraint FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+--bun:split
+
+CREATE UNIQUE INDEX promo_redemptions_promo_code_id_account_id_idx ON promo_redemptions(promo_code_id, account_id);
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN campaign_bonus;
+
+--bun:split
+
+ALTER TABLE transactions DROP COLUMN campaign_id;
+
+--bun:split
+
+DROP TABLE campaigns;
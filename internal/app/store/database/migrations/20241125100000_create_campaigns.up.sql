@@ -0,0 +1,40 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE campaigns (
+    id uuid PRIMARY KEY,
+    name character varying(255) NOT NULL,
+    starts_at timestamp without time zone NOT NULL,
+    ends_at timestamp without time zone NOT NULL,
+    eligibility character varying(32) NOT NULL,
+    tier character varying(32),
+    new_user_days integer,
+    multiplier numeric(19,2),
+    fixed_bonus numeric(19,2),
+    disabled_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    CHECK (ends_at > starts_at),
+    CHECK ((multiplier IS NULL) <> (fixed_bonus IS NULL))
+);
+
+--bun:split
+
+CREATE INDEX campaigns_starts_at_ends_at_idx ON campaigns(starts_at, ends_at);
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN campaign_id uuid;
+
+--bun:split
+
+ALTER TABLE transactions ADD COLUMN campaign_bonus numeric(19,2);
+
+--bun:split
+
+ALTER TABLE transactions ADD CONSTRAINT campaign_id_constraint FOREIGN KEY (campaign_id) REFERENCES campaigns(id);
+
+--bun:split
+
+CREATE INDEX transactions_campaign_id_idx ON transactions(campaign_id) WHERE campaign_id IS NOT NULL;
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+DROP INDEX orders_next_check_at_idx;
+
+--bun:split
+
+ALTER TABLE orders DROP COLUMN next_check_at;
+
+--bun:split
+
+ALTER TABLE orders DROP COLUMN attempts;
@@ -0,0 +1,13 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+ALTER TABLE orders ADD COLUMN attempts integer NOT NULL DEFAULT 0;
+
+--bun:split
+
+ALTER TABLE orders ADD COLUMN next_check_at timestamp without time zone NOT NULL DEFAULT (now() at time zone 'utc');
+
+--bun:split
+
+CREATE INDEX orders_next_check_at_idx ON orders(next_check_at) WHERE status IN ('NEW', 'PROCESSING');
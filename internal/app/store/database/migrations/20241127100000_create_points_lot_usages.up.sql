@@ -0,0 +1,30 @@
+SET statement_timeout = 0;
+
+--bun:split
+
+CREATE TABLE points_lot_usages (
+    id uuid PRIMARY KEY,
+    lot_id uuid NOT NULL,
+    transaction_id uuid NOT NULL,
+    amount numeric(19,2) NOT NULL,
+    carried numeric(19,2) NOT NULL DEFAULT 0,
+    expires_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+--bun:split
+
+ALTER TABLE points_lot_usages ADD CONSTRAINT lot_id_constraint FOREIGN KEY (lot_id) REFERENCES points_lots(id);
+
+--bun:split
+
+ALTER TABLE points_lot_usages ADD CONSTRAINT transaction_id_constraint FOREIGN KEY (transaction_id) REFERENCES transactions(id);
+
+--bun:split
+
+ALTER TABLE points_lot_usages ADD CONSTRAINT carried_check CHECK (carried >= 0 AND carried <= amount);
+
+--bun:split
+
+CREATE INDEX points_lot_usages_transaction_id_idx ON points_lot_usages(transaction_id);
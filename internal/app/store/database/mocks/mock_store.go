@@ -7,9 +7,11 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	models "github.com/madatsci/gophermart/internal/app/models"
+	points "github.com/madatsci/gophermart/pkg/points"
 )
 
 // MockStore is a mock of Store interface.
@@ -50,6 +52,65 @@ func (mr *MockStoreMockRecorder) AddBalance(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBalance", reflect.TypeOf((*MockStore)(nil).AddBalance), arg0, arg1)
 }
 
+// AdjustBalance mocks base method.
+func (m *MockStore) AdjustBalance(arg0 context.Context, arg1 models.Adjustment) (models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustBalance", arg0, arg1)
+	ret0, _ := ret[0].(models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustBalance indicates an expected call of AdjustBalance.
+func (mr *MockStoreMockRecorder) AdjustBalance(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustBalance", reflect.TypeOf((*MockStore)(nil).AdjustBalance), arg0, arg1)
+}
+
+// CaptureHold mocks base method.
+func (m *MockStore) CaptureHold(arg0 context.Context, arg1, arg2 string) (models.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHold", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CaptureHold indicates an expected call of CaptureHold.
+func (mr *MockStoreMockRecorder) CaptureHold(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHold", reflect.TypeOf((*MockStore)(nil).CaptureHold), arg0, arg1, arg2)
+}
+
+// ClaimDueOrders mocks base method.
+func (m *MockStore) ClaimDueOrders(arg0 context.Context, arg1 []models.OrderStatus, arg2 time.Time, arg3 time.Duration, arg4 int) ([]models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueOrders", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].([]models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueOrders indicates an expected call of ClaimDueOrders.
+func (mr *MockStoreMockRecorder) ClaimDueOrders(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueOrders", reflect.TypeOf((*MockStore)(nil).ClaimDueOrders), arg0, arg1, arg2, arg3, arg4)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockStore) CompleteIdempotencyKey(arg0 context.Context, arg1 models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockStoreMockRecorder) CompleteIdempotencyKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockStore)(nil).CompleteIdempotencyKey), arg0, arg1)
+}
+
 // CreateAccount mocks base method.
 func (m *MockStore) CreateAccount(arg0 context.Context, arg1 models.Account) (models.Account, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +126,50 @@ func (mr *MockStoreMockRecorder) CreateAccount(arg0, arg1 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockStore)(nil).CreateAccount), arg0, arg1)
 }
 
+// CreateCampaign mocks base method.
+func (m *MockStore) CreateCampaign(arg0 context.Context, arg1 models.Campaign) (models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCampaign", arg0, arg1)
+	ret0, _ := ret[0].(models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCampaign indicates an expected call of CreateCampaign.
+func (mr *MockStoreMockRecorder) CreateCampaign(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCampaign", reflect.TypeOf((*MockStore)(nil).CreateCampaign), arg0, arg1)
+}
+
+// CreateHold mocks base method.
+func (m *MockStore) CreateHold(arg0 context.Context, arg1, arg2 string, arg3 points.Points, arg4 time.Time, arg5 models.WithdrawalRules) (models.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateHold", arg0, arg1, arg2, arg3, arg4, arg5)
+	ret0, _ := ret[0].(models.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateHold indicates an expected call of CreateHold.
+func (mr *MockStoreMockRecorder) CreateHold(arg0, arg1, arg2, arg3, arg4, arg5 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateHold", reflect.TypeOf((*MockStore)(nil).CreateHold), arg0, arg1, arg2, arg3, arg4, arg5)
+}
+
+// CreateIdempotencyKey mocks base method.
+func (m *MockStore) CreateIdempotencyKey(arg0 context.Context, arg1 models.IdempotencyKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIdempotencyKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateIdempotencyKey indicates an expected call of CreateIdempotencyKey.
+func (mr *MockStoreMockRecorder) CreateIdempotencyKey(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIdempotencyKey", reflect.TypeOf((*MockStore)(nil).CreateIdempotencyKey), arg0, arg1)
+}
+
 // CreateOrder mocks base method.
 func (m *MockStore) CreateOrder(arg0 context.Context, arg1 *models.Order) error {
 	m.ctrl.T.Helper()
@@ -79,6 +184,36 @@ func (mr *MockStoreMockRecorder) CreateOrder(arg0, arg1 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOrder", reflect.TypeOf((*MockStore)(nil).CreateOrder), arg0, arg1)
 }
 
+// CreatePromoCode mocks base method.
+func (m *MockStore) CreatePromoCode(arg0 context.Context, arg1 models.PromoCode) (models.PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePromoCode", arg0, arg1)
+	ret0, _ := ret[0].(models.PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePromoCode indicates an expected call of CreatePromoCode.
+func (mr *MockStoreMockRecorder) CreatePromoCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePromoCode", reflect.TypeOf((*MockStore)(nil).CreatePromoCode), arg0, arg1)
+}
+
+// CreateReferral mocks base method.
+func (m *MockStore) CreateReferral(arg0 context.Context, arg1 models.Referral, arg2 int) (models.Referral, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateReferral", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Referral)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateReferral indicates an expected call of CreateReferral.
+func (mr *MockStoreMockRecorder) CreateReferral(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateReferral", reflect.TypeOf((*MockStore)(nil).CreateReferral), arg0, arg1, arg2)
+}
+
 // CreateUser mocks base method.
 func (m *MockStore) CreateUser(arg0 context.Context, arg1 models.User) (models.User, error) {
 	m.ctrl.T.Helper()
@@ -94,6 +229,95 @@ func (mr *MockStoreMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), arg0, arg1)
 }
 
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockStore) DeleteExpiredIdempotencyKeys(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockStoreMockRecorder) DeleteExpiredIdempotencyKeys(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockStore)(nil).DeleteExpiredIdempotencyKeys), arg0, arg1)
+}
+
+// DeleteIdempotencyKey mocks base method.
+func (m *MockStore) DeleteIdempotencyKey(arg0 context.Context, arg1, arg2 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIdempotencyKey indicates an expected call of DeleteIdempotencyKey.
+func (mr *MockStoreMockRecorder) DeleteIdempotencyKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIdempotencyKey", reflect.TypeOf((*MockStore)(nil).DeleteIdempotencyKey), arg0, arg1, arg2)
+}
+
+// DisableCampaign mocks base method.
+func (m *MockStore) DisableCampaign(arg0 context.Context, arg1 string) (models.Campaign, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableCampaign", arg0, arg1)
+	ret0, _ := ret[0].(models.Campaign)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DisableCampaign indicates an expected call of DisableCampaign.
+func (mr *MockStoreMockRecorder) DisableCampaign(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableCampaign", reflect.TypeOf((*MockStore)(nil).DisableCampaign), arg0, arg1)
+}
+
+// DisablePromoCode mocks base method.
+func (m *MockStore) DisablePromoCode(arg0 context.Context, arg1 string) (models.PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisablePromoCode", arg0, arg1)
+	ret0, _ := ret[0].(models.PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DisablePromoCode indicates an expected call of DisablePromoCode.
+func (mr *MockStoreMockRecorder) DisablePromoCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisablePromoCode", reflect.TypeOf((*MockStore)(nil).DisablePromoCode), arg0, arg1)
+}
+
+// ExpireHolds mocks base method.
+func (m *MockStore) ExpireHolds(arg0 context.Context, arg1 time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockStoreMockRecorder) ExpireHolds(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockStore)(nil).ExpireHolds), arg0, arg1)
+}
+
+// ExpirePoints mocks base method.
+func (m *MockStore) ExpirePoints(arg0 context.Context, arg1 time.Time) ([]models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePoints", arg0, arg1)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePoints indicates an expected call of ExpirePoints.
+func (mr *MockStoreMockRecorder) ExpirePoints(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePoints", reflect.TypeOf((*MockStore)(nil).ExpirePoints), arg0, arg1)
+}
+
 // GetAccountByUserID mocks base method.
 func (m *MockStore) GetAccountByUserID(arg0 context.Context, arg1 string) (models.Account, error) {
 	m.ctrl.T.Helper()
@@ -109,6 +333,36 @@ func (mr *MockStoreMockRecorder) GetAccountByUserID(arg0, arg1 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountByUserID", reflect.TypeOf((*MockStore)(nil).GetAccountByUserID), arg0, arg1)
 }
 
+// GetIdempotencyKey mocks base method.
+func (m *MockStore) GetIdempotencyKey(arg0 context.Context, arg1, arg2 string) (models.IdempotencyKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIdempotencyKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.IdempotencyKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIdempotencyKey indicates an expected call of GetIdempotencyKey.
+func (mr *MockStoreMockRecorder) GetIdempotencyKey(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIdempotencyKey", reflect.TypeOf((*MockStore)(nil).GetIdempotencyKey), arg0, arg1, arg2)
+}
+
+// GetLedgerBalances mocks base method.
+func (m *MockStore) GetLedgerBalances(arg0 context.Context) ([]models.LedgerBalance, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLedgerBalances", arg0)
+	ret0, _ := ret[0].([]models.LedgerBalance)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLedgerBalances indicates an expected call of GetLedgerBalances.
+func (mr *MockStoreMockRecorder) GetLedgerBalances(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLedgerBalances", reflect.TypeOf((*MockStore)(nil).GetLedgerBalances), arg0)
+}
+
 // GetOrderByNumber mocks base method.
 func (m *MockStore) GetOrderByNumber(arg0 context.Context, arg1 string) (models.Order, error) {
 	m.ctrl.T.Helper()
@@ -124,6 +378,36 @@ func (mr *MockStoreMockRecorder) GetOrderByNumber(arg0, arg1 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOrderByNumber", reflect.TypeOf((*MockStore)(nil).GetOrderByNumber), arg0, arg1)
 }
 
+// GetReferralSummary mocks base method.
+func (m *MockStore) GetReferralSummary(arg0 context.Context, arg1 string) (models.ReferralSummary, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReferralSummary", arg0, arg1)
+	ret0, _ := ret[0].(models.ReferralSummary)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReferralSummary indicates an expected call of GetReferralSummary.
+func (mr *MockStoreMockRecorder) GetReferralSummary(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReferralSummary", reflect.TypeOf((*MockStore)(nil).GetReferralSummary), arg0, arg1)
+}
+
+// GetUserByID mocks base method.
+func (m *MockStore) GetUserByID(arg0 context.Context, arg1 string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", arg0, arg1)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockStoreMockRecorder) GetUserByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockStore)(nil).GetUserByID), arg0, arg1)
+}
+
 // GetUserByLogin mocks base method.
 func (m *MockStore) GetUserByLogin(arg0 context.Context, arg1 string) (models.User, error) {
 	m.ctrl.T.Helper()
@@ -139,34 +423,79 @@ func (mr *MockStoreMockRecorder) GetUserByLogin(arg0, arg1 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByLogin", reflect.TypeOf((*MockStore)(nil).GetUserByLogin), arg0, arg1)
 }
 
-// GetWithdrawals mocks base method.
-func (m *MockStore) GetWithdrawals(arg0 context.Context, arg1 string, arg2 models.TxDirection, arg3 int) ([]models.Transaction, error) {
+// GetUserByReferralCode mocks base method.
+func (m *MockStore) GetUserByReferralCode(arg0 context.Context, arg1 string) (models.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWithdrawals", arg0, arg1, arg2, arg3)
-	ret0, _ := ret[0].([]models.Transaction)
+	ret := m.ctrl.Call(m, "GetUserByReferralCode", arg0, arg1)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByReferralCode indicates an expected call of GetUserByReferralCode.
+func (mr *MockStoreMockRecorder) GetUserByReferralCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByReferralCode", reflect.TypeOf((*MockStore)(nil).GetUserByReferralCode), arg0, arg1)
+}
+
+// ListCampaigns mocks base method.
+func (m *MockStore) ListCampaigns(arg0 context.Context) ([]models.CampaignStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCampaigns", arg0)
+	ret0, _ := ret[0].([]models.CampaignStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCampaigns indicates an expected call of ListCampaigns.
+func (mr *MockStoreMockRecorder) ListCampaigns(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCampaigns", reflect.TypeOf((*MockStore)(nil).ListCampaigns), arg0)
+}
+
+// ListExpiringLots mocks base method.
+func (m *MockStore) ListExpiringLots(arg0 context.Context, arg1 string, arg2 time.Time) ([]models.PointsLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListExpiringLots", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.PointsLot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListExpiringLots indicates an expected call of ListExpiringLots.
+func (mr *MockStoreMockRecorder) ListExpiringLots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExpiringLots", reflect.TypeOf((*MockStore)(nil).ListExpiringLots), arg0, arg1, arg2)
+}
+
+// ListLedgerEntries mocks base method.
+func (m *MockStore) ListLedgerEntries(arg0 context.Context, arg1 string, arg2 int) ([]models.LedgerEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLedgerEntries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.LedgerEntry)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetWithdrawals indicates an expected call of GetWithdrawals.
-func (mr *MockStoreMockRecorder) GetWithdrawals(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+// ListLedgerEntries indicates an expected call of ListLedgerEntries.
+func (mr *MockStoreMockRecorder) ListLedgerEntries(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWithdrawals", reflect.TypeOf((*MockStore)(nil).GetWithdrawals), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLedgerEntries", reflect.TypeOf((*MockStore)(nil).ListLedgerEntries), arg0, arg1, arg2)
 }
 
-// ListOrdersByAccountID mocks base method.
-func (m *MockStore) ListOrdersByAccountID(arg0 context.Context, arg1 string, arg2 int) ([]models.Order, error) {
+// ListOrders mocks base method.
+func (m *MockStore) ListOrders(arg0 context.Context, arg1 string, arg2 models.OrderFilter) ([]models.Order, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListOrdersByAccountID", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "ListOrders", arg0, arg1, arg2)
 	ret0, _ := ret[0].([]models.Order)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListOrdersByAccountID indicates an expected call of ListOrdersByAccountID.
-func (mr *MockStoreMockRecorder) ListOrdersByAccountID(arg0, arg1, arg2 interface{}) *gomock.Call {
+// ListOrders indicates an expected call of ListOrders.
+func (mr *MockStoreMockRecorder) ListOrders(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrdersByAccountID", reflect.TypeOf((*MockStore)(nil).ListOrdersByAccountID), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrders", reflect.TypeOf((*MockStore)(nil).ListOrders), arg0, arg1, arg2)
 }
 
 // ListOrdersByStatus mocks base method.
@@ -184,6 +513,215 @@ func (mr *MockStoreMockRecorder) ListOrdersByStatus(arg0, arg1, arg2 interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOrdersByStatus", reflect.TypeOf((*MockStore)(nil).ListOrdersByStatus), arg0, arg1, arg2)
 }
 
+// ListPromoCodes mocks base method.
+func (m *MockStore) ListPromoCodes(arg0 context.Context) ([]models.PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPromoCodes", arg0)
+	ret0, _ := ret[0].([]models.PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPromoCodes indicates an expected call of ListPromoCodes.
+func (mr *MockStoreMockRecorder) ListPromoCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPromoCodes", reflect.TypeOf((*MockStore)(nil).ListPromoCodes), arg0)
+}
+
+// ListStatement mocks base method.
+func (m *MockStore) ListStatement(arg0 context.Context, arg1 string, arg2 models.TransactionFilter) ([]models.StatementEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListStatement", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.StatementEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListStatement indicates an expected call of ListStatement.
+func (mr *MockStoreMockRecorder) ListStatement(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListStatement", reflect.TypeOf((*MockStore)(nil).ListStatement), arg0, arg1, arg2)
+}
+
+// ListTierChanges mocks base method.
+func (m *MockStore) ListTierChanges(arg0 context.Context, arg1 string) ([]models.TierChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTierChanges", arg0, arg1)
+	ret0, _ := ret[0].([]models.TierChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTierChanges indicates an expected call of ListTierChanges.
+func (mr *MockStoreMockRecorder) ListTierChanges(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTierChanges", reflect.TypeOf((*MockStore)(nil).ListTierChanges), arg0, arg1)
+}
+
+// ListTransactions mocks base method.
+func (m *MockStore) ListTransactions(arg0 context.Context, arg1 string, arg2 models.TransactionFilter) ([]models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransactions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransactions indicates an expected call of ListTransactions.
+func (mr *MockStoreMockRecorder) ListTransactions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransactions", reflect.TypeOf((*MockStore)(nil).ListTransactions), arg0, arg1, arg2)
+}
+
+// ProcessOrder mocks base method.
+func (m *MockStore) ProcessOrder(arg0 context.Context, arg1 models.Order, arg2 models.OrderStatus) (models.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProcessOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProcessOrder indicates an expected call of ProcessOrder.
+func (mr *MockStoreMockRecorder) ProcessOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProcessOrder", reflect.TypeOf((*MockStore)(nil).ProcessOrder), arg0, arg1, arg2)
+}
+
+// ReconcileAccounts mocks base method.
+func (m *MockStore) ReconcileAccounts(arg0 context.Context, arg1 bool) ([]models.AccountDrift, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReconcileAccounts", arg0, arg1)
+	ret0, _ := ret[0].([]models.AccountDrift)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReconcileAccounts indicates an expected call of ReconcileAccounts.
+func (mr *MockStoreMockRecorder) ReconcileAccounts(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileAccounts", reflect.TypeOf((*MockStore)(nil).ReconcileAccounts), arg0, arg1)
+}
+
+// RedeemPromoCode mocks base method.
+func (m *MockStore) RedeemPromoCode(arg0 context.Context, arg1, arg2 string) (models.PromoRedemption, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeemPromoCode", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.PromoRedemption)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RedeemPromoCode indicates an expected call of RedeemPromoCode.
+func (mr *MockStoreMockRecorder) RedeemPromoCode(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeemPromoCode", reflect.TypeOf((*MockStore)(nil).RedeemPromoCode), arg0, arg1, arg2)
+}
+
+// RefreshTier mocks base method.
+func (m *MockStore) RefreshTier(arg0 context.Context, arg1 string) (models.TierStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTier", arg0, arg1)
+	ret0, _ := ret[0].(models.TierStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTier indicates an expected call of RefreshTier.
+func (mr *MockStoreMockRecorder) RefreshTier(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTier", reflect.TypeOf((*MockStore)(nil).RefreshTier), arg0, arg1)
+}
+
+// RefundWithdrawal mocks base method.
+func (m *MockStore) RefundWithdrawal(arg0 context.Context, arg1 string, arg2 points.Points) (models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefundWithdrawal", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefundWithdrawal indicates an expected call of RefundWithdrawal.
+func (mr *MockStoreMockRecorder) RefundWithdrawal(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefundWithdrawal", reflect.TypeOf((*MockStore)(nil).RefundWithdrawal), arg0, arg1, arg2)
+}
+
+// ReturnOrder mocks base method.
+func (m *MockStore) ReturnOrder(arg0 context.Context, arg1 string, arg2 models.ClawbackPolicy) (models.Clawback, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReturnOrder", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Clawback)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReturnOrder indicates an expected call of ReturnOrder.
+func (mr *MockStoreMockRecorder) ReturnOrder(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReturnOrder", reflect.TypeOf((*MockStore)(nil).ReturnOrder), arg0, arg1, arg2)
+}
+
+// ScheduleOrderCheck mocks base method.
+func (m *MockStore) ScheduleOrderCheck(arg0 context.Context, arg1 models.Order) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleOrderCheck", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleOrderCheck indicates an expected call of ScheduleOrderCheck.
+func (mr *MockStoreMockRecorder) ScheduleOrderCheck(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleOrderCheck", reflect.TypeOf((*MockStore)(nil).ScheduleOrderCheck), arg0, arg1)
+}
+
+// SetAccountFrozen mocks base method.
+func (m *MockStore) SetAccountFrozen(arg0 context.Context, arg1 string, arg2 bool) (models.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAccountFrozen", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetAccountFrozen indicates an expected call of SetAccountFrozen.
+func (mr *MockStoreMockRecorder) SetAccountFrozen(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAccountFrozen", reflect.TypeOf((*MockStore)(nil).SetAccountFrozen), arg0, arg1, arg2)
+}
+
+// SetUserRole mocks base method.
+func (m *MockStore) SetUserRole(arg0 context.Context, arg1 string, arg2 models.Role) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserRole", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserRole indicates an expected call of SetUserRole.
+func (mr *MockStoreMockRecorder) SetUserRole(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserRole", reflect.TypeOf((*MockStore)(nil).SetUserRole), arg0, arg1, arg2)
+}
+
+// TransferPoints mocks base method.
+func (m *MockStore) TransferPoints(arg0 context.Context, arg1, arg2 string, arg3, arg4 points.Points) (models.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferPoints", arg0, arg1, arg2, arg3, arg4)
+	ret0, _ := ret[0].(models.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferPoints indicates an expected call of TransferPoints.
+func (mr *MockStoreMockRecorder) TransferPoints(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferPoints", reflect.TypeOf((*MockStore)(nil).TransferPoints), arg0, arg1, arg2, arg3, arg4)
+}
+
 // UpdateOrder mocks base method.
 func (m *MockStore) UpdateOrder(arg0 context.Context, arg1 models.Order, arg2 models.OrderStatus) (models.Order, error) {
 	m.ctrl.T.Helper()
@@ -199,17 +737,32 @@ func (mr *MockStoreMockRecorder) UpdateOrder(arg0, arg1, arg2 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOrder", reflect.TypeOf((*MockStore)(nil).UpdateOrder), arg0, arg1, arg2)
 }
 
+// VoidHold mocks base method.
+func (m *MockStore) VoidHold(arg0 context.Context, arg1, arg2 string) (models.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VoidHold", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VoidHold indicates an expected call of VoidHold.
+func (mr *MockStoreMockRecorder) VoidHold(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VoidHold", reflect.TypeOf((*MockStore)(nil).VoidHold), arg0, arg1, arg2)
+}
+
 // WithdrawBalance mocks base method.
-func (m *MockStore) WithdrawBalance(arg0 context.Context, arg1, arg2 string, arg3 float32) (models.Account, error) {
+func (m *MockStore) WithdrawBalance(arg0 context.Context, arg1, arg2 string, arg3 points.Points, arg4 models.WithdrawalRules) (models.Account, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "WithdrawBalance", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "WithdrawBalance", arg0, arg1, arg2, arg3, arg4)
 	ret0, _ := ret[0].(models.Account)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // WithdrawBalance indicates an expected call of WithdrawBalance.
-func (mr *MockStoreMockRecorder) WithdrawBalance(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockStoreMockRecorder) WithdrawBalance(arg0, arg1, arg2, arg3, arg4 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawBalance", reflect.TypeOf((*MockStore)(nil).WithdrawBalance), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawBalance", reflect.TypeOf((*MockStore)(nil).WithdrawBalance), arg0, arg1, arg2, arg3, arg4)
 }
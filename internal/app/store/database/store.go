@@ -4,21 +4,68 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/ledger"
 	"github.com/madatsci/gophermart/internal/app/models"
 	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
 )
 
-type Store struct {
-	conn *bun.DB
+const (
+	// expirePointsBatchSize limits the number of lots expired at once.
+	expirePointsBatchSize = 1000
+	// expireHoldsBatchSize limits the number of holds expired at once.
+	expireHoldsBatchSize = 1000
+)
+
+type (
+	Store struct {
+		conn *bun.DB
+
+		pointsLifetime  int
+		tierPolicy      models.TierPolicy
+		referralBonuses models.ReferralBonuses
+	}
+
+	// Option configures the storage.
+	Option func(*Store)
+)
+
+// WithPointsLifetime sets the number of months after which credited points expire.
+// By default points never expire.
+func WithPointsLifetime(months int) Option {
+	return func(s *Store) {
+		s.pointsLifetime = months
+	}
+}
+
+// WithTierPolicy sets rules of membership tiers. By default every account is bronze
+// and accruals are credited as is.
+func WithTierPolicy(policy models.TierPolicy) Option {
+	return func(s *Store) {
+		s.tierPolicy = policy
+	}
+}
+
+// WithReferralBonuses sets points credited to the referrer and the referee for the first
+// processed order of the referee. By default no bonuses are credited.
+func WithReferralBonuses(bonuses models.ReferralBonuses) Option {
+	return func(s *Store) {
+		s.referralBonuses = bonuses
+	}
 }
 
 // New creates a new database-driven storage.
-func New(ctx context.Context, conn *bun.DB) (*Store, error) {
+func New(ctx context.Context, conn *bun.DB, opts ...Option) (*Store, error) {
 	store := &Store{conn: conn}
+	for _, opt := range opts {
+		opt(store)
+	}
 	if err := store.bootstrap(ctx); err != nil {
 		return nil, err
 	}
@@ -32,6 +79,10 @@ func (s *Store) CreateUser(ctx context.Context, user models.User) (models.User,
 
 	err := s.conn.NewInsert().Model(&user).Returning("*").Scan(ctx, &result)
 	if err != nil {
+		var pgErr pgdriver.Error
+		if errors.As(err, &pgErr) && pgErr.Field('n') == "users_referral_code_idx" {
+			return result, fmt.Errorf("%w: %s", store.ErrReferralCodeTaken, user.ReferralCode)
+		}
 		return result, &store.InsertError{Err: err}
 	}
 
@@ -47,6 +98,114 @@ func (s *Store) GetUserByLogin(ctx context.Context, login string) (models.User,
 	return result, err
 }
 
+// GetUserByID fetches user from database by ID.
+func (s *Store) GetUserByID(ctx context.Context, id string) (models.User, error) {
+	var result models.User
+
+	err := s.conn.NewSelect().Model(&result).Where("id = ?", id).Scan(ctx)
+
+	return result, err
+}
+
+// GetUserByReferralCode fetches user from database by referral code.
+func (s *Store) GetUserByReferralCode(ctx context.Context, code string) (models.User, error) {
+	var result models.User
+
+	err := s.conn.NewSelect().Model(&result).Where("referral_code = ?", code).Scan(ctx)
+
+	return result, err
+}
+
+// CreateReferral links the referee to the referrer unless the referrer has already invited limit users,
+// zero limit means no limit. The referee can be referred only once.
+func (s *Store) CreateReferral(ctx context.Context, referral models.Referral, limit int) (models.Referral, error) {
+	var referrer models.User
+
+	if referral.ReferrerID == referral.RefereeID {
+		return referral, store.ErrSelfReferral
+	}
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return referral, err
+	}
+
+	// referrals are counted while the referrer is locked, so concurrent registrations can not exceed the limit
+	err = tx.NewSelect().
+		Model(&referrer).
+		Where("id = ?", referral.ReferrerID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return referral, err
+	}
+
+	if limit > 0 {
+		count, err := tx.NewSelect().
+			Model((*models.Referral)(nil)).
+			Where("referrer_id = ?", referrer.ID).
+			Count(ctx)
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+			return referral, err
+		}
+		if count >= limit {
+			tx.Rollback() //nolint:errcheck
+			return referral, store.ErrReferralLimitReached
+		}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&referral).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return referral, &store.InsertError{Err: err}
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return referral, err
+	}
+
+	return referral, nil
+}
+
+// GetReferralSummary fetches referral code of the user with the users they have invited, the latest first.
+func (s *Store) GetReferralSummary(ctx context.Context, userID string) (models.ReferralSummary, error) {
+	var (
+		user    models.User
+		summary models.ReferralSummary
+	)
+
+	err := s.conn.NewSelect().Model(&user).Where("id = ?", userID).Scan(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	summary.Code = user.ReferralCode
+	err = s.conn.NewSelect().
+		Model(&summary.Referrals).
+		Relation("Referee").
+		Where("referral.referrer_id = ?", userID).
+		Order("referral.created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		return summary, err
+	}
+
+	for i, r := range summary.Referrals {
+		summary.Referrals[i].Login = r.Referee.Login
+		summary.Earned += r.ReferrerBonus
+	}
+	if summary.Referrals == nil {
+		summary.Referrals = []models.Referral{}
+	}
+
+	return summary, nil
+}
+
 // CreateAccount creates new account.
 func (s *Store) CreateAccount(ctx context.Context, account models.Account) (models.Account, error) {
 	var result models.Account
@@ -65,229 +224,695 @@ func (s *Store) GetAccountByUserID(ctx context.Context, userID string) (models.A
 	return result, err
 }
 
-// CreateOrder saves new order in database.
-func (s *Store) CreateOrder(ctx context.Context, order *models.Order) error {
-	err := s.conn.NewInsert().Model(order).Returning("*").Scan(ctx, order)
+// SetUserRole changes role of the user.
+func (s *Store) SetUserRole(ctx context.Context, login string, role models.Role) (models.User, error) {
+	var result models.User
+
+	err := s.conn.NewUpdate().
+		Model(&result).
+		Set("role = ?", role).
+		Set("updated_at = ?", time.Now()).
+		Where("login = ?", login).
+		Returning("*").
+		Scan(ctx)
+
+	return result, err
+}
+
+// SetAccountFrozen freezes or unfreezes the account. Freezing an already frozen account
+// keeps the time it was frozen at.
+func (s *Store) SetAccountFrozen(ctx context.Context, accountID string, frozen bool) (models.Account, error) {
+	var result models.Account
+
+	q := s.conn.NewUpdate().
+		Model(&result).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", accountID).
+		Returning("*")
+	if frozen {
+		q = q.Set("frozen_at = COALESCE(frozen_at, ?)", time.Now())
+	} else {
+		q = q.Set("frozen_at = NULL")
+	}
+
+	err := q.Scan(ctx)
+
+	return result, err
+}
+
+// CreatePromoCode saves new promo code.
+func (s *Store) CreatePromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error) {
+	var result models.PromoCode
+
+	if err := promo.Validate(); err != nil {
+		return result, err
+	}
+
+	err := s.conn.NewInsert().Model(&promo).Returning("*").Scan(ctx, &result)
 	if err != nil {
-		return &store.InsertError{Err: err}
+		return result, &store.InsertError{Err: err}
 	}
-	return nil
+
+	return result, nil
 }
 
-// GetOrderByNumber fetches order by its number.
-func (s *Store) GetOrderByNumber(ctx context.Context, orderNumber string) (models.Order, error) {
-	var result models.Order
+// ListPromoCodes fetches all promo codes, the latest first.
+func (s *Store) ListPromoCodes(ctx context.Context) ([]models.PromoCode, error) {
+	var result []models.PromoCode
 
 	err := s.conn.NewSelect().
 		Model(&result).
-		Where("number = ?", orderNumber).
-		Relation("Account").
+		Order("created_at DESC").
 		Scan(ctx)
 
 	return result, err
 }
 
-// ListOrdersByAccountID fetches orders linked to the account.
-func (s *Store) ListOrdersByAccountID(ctx context.Context, accountID string, limit int) ([]models.Order, error) {
-	var result []models.Order
+// DisablePromoCode forbids redeeming the promo code. Disabling an already disabled code
+// keeps the time it was disabled at.
+func (s *Store) DisablePromoCode(ctx context.Context, code string) (models.PromoCode, error) {
+	var result models.PromoCode
 
-	err := s.conn.NewSelect().
+	err := s.conn.NewUpdate().
 		Model(&result).
-		Where("account_id = ?", accountID).
-		Order("created_at DESC").
-		Limit(limit).
+		Set("disabled_at = COALESCE(disabled_at, ?)", time.Now()).
+		Set("updated_at = ?", time.Now()).
+		Where("code = ?", code).
+		Returning("*").
 		Scan(ctx)
 
 	return result, err
 }
 
-// ListOrdersByStatus fetches orders in specified statuses.
-func (s *Store) ListOrdersByStatus(ctx context.Context, statuses []models.OrderStatus, limit int) ([]models.Order, error) {
-	var result []models.Order
+// CreateCampaign saves new accrual campaign.
+func (s *Store) CreateCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error) {
+	var result models.Campaign
+
+	if err := campaign.Validate(); err != nil {
+		return result, err
+	}
+
+	err := s.conn.NewInsert().Model(&campaign).Returning("*").Scan(ctx, &result)
+	if err != nil {
+		return result, &store.InsertError{Err: err}
+	}
+
+	return result, nil
+}
+
+// ListCampaigns fetches all campaigns with the number of accruals they have been applied to
+// and the points they have added, the latest first.
+func (s *Store) ListCampaigns(ctx context.Context) ([]models.CampaignStats, error) {
+	var result []models.CampaignStats
 
 	err := s.conn.NewSelect().
 		Model(&result).
-		Where("status IN (?)", bun.In(statuses)).
-		Order("created_at ASC").
-		Limit(limit).
+		ColumnExpr("campaign.*").
+		ColumnExpr("COUNT(t.id) AS orders").
+		ColumnExpr("COALESCE(SUM(t.campaign_bonus), 0) AS credited").
+		Join("LEFT JOIN transactions AS t ON t.campaign_id = campaign.id").
+		Group("campaign.id").
+		Order("campaign.created_at DESC").
 		Scan(ctx)
 
 	return result, err
 }
 
-// UpdateOrder updates order in database.
-func (s *Store) UpdateOrder(ctx context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
-	var checkOrder models.Order
+// DisableCampaign stops applying the campaign to accruals. Disabling an already disabled campaign
+// keeps the time it was disabled at.
+func (s *Store) DisableCampaign(ctx context.Context, id string) (models.Campaign, error) {
+	var result models.Campaign
+
+	err := s.conn.NewUpdate().
+		Model(&result).
+		Set("disabled_at = COALESCE(disabled_at, ?)", time.Now()).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Returning("*").
+		Scan(ctx)
+
+	return result, err
+}
+
+// RedeemPromoCode credits points of the promo code to the user account. The promo code is locked
+// while it is redeemed, so it is never redeemed more than the maximum number of times.
+func (s *Store) RedeemPromoCode(ctx context.Context, userID string, code string) (models.PromoRedemption, error) {
+	var (
+		promo      models.PromoCode
+		acc        models.Account
+		redemption models.PromoRedemption
+	)
 
 	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return checkOrder, err
+		return redemption, err
 	}
 
 	err = tx.NewSelect().
-		Model(&checkOrder).
-		Where("id = ?", order.ID).
+		Model(&promo).
+		Where("code = ?", code).
 		For("UPDATE").
 		Scan(ctx)
 	if err != nil {
 		tx.Rollback() //nolint:errcheck
-		return checkOrder, err
+		return redemption, err
 	}
-	if checkOrder.Status != prevStatus {
+
+	now := time.Now()
+	if !promo.Active(now) {
 		tx.Rollback() //nolint:errcheck
-		return checkOrder, errors.New("sql: update conflict")
+		return redemption, fmt.Errorf("%w: %s", store.ErrPromoCodeInactive, code)
+	}
+	if promo.Redemptions >= promo.MaxRedemptions {
+		tx.Rollback() //nolint:errcheck
+		return redemption, fmt.Errorf("%w: %s", store.ErrPromoCodeExhausted, code)
+	}
+
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("user_id = ?", userID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return redemption, err
+	}
+
+	exists, err := tx.NewSelect().
+		Model((*models.PromoRedemption)(nil)).
+		Where("promo_code_id = ?", promo.ID).
+		Where("account_id = ?", acc.ID).
+		Exists(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return redemption, err
+	}
+	if exists {
+		tx.Rollback() //nolint:errcheck
+		return redemption, fmt.Errorf("%w: %s", store.ErrPromoCodeRedeemed, code)
+	}
+
+	transaction := models.Transaction{
+		ID:        uuid.NewString(),
+		AccountID: acc.ID,
+		Amount:    promo.Amount,
+		Direction: models.TxDirectionPromo,
+		Comment:   promo.Code,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err = s.post(ctx, tx, acc, transaction); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return redemption, err
+	}
+
+	redemption = models.PromoRedemption{
+		ID:            uuid.NewString(),
+		PromoCodeID:   promo.ID,
+		AccountID:     acc.ID,
+		TransactionID: transaction.ID,
+		Code:          promo.Code,
+		Amount:        promo.Amount,
+		CreatedAt:     now,
+	}
+	_, err = tx.NewInsert().
+		Model(&redemption).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return redemption, &store.InsertError{Err: err}
 	}
 
 	_, err = tx.NewUpdate().
-		Model(&order).
+		Model(&promo).
+		Set("redemptions = redemptions + 1").
+		Set("updated_at = ?", now).
 		WherePK().
-		Column("status", "accrual", "updated_at").
-		Returning("*").
 		Exec(ctx)
 	if err != nil {
 		tx.Rollback() //nolint:errcheck
-		return checkOrder, err
+		return redemption, err
 	}
 
 	if err = tx.Commit(); err != nil {
 		tx.Rollback() //nolint:errcheck
-		return checkOrder, err
+		return redemption, err
 	}
 
-	return order, nil
+	return redemption, nil
 }
 
-// WithdrawBalance withdraws points from balance if there are enough points.
-func (s *Store) WithdrawBalance(ctx context.Context, userID string, orderNumber string, sum float32) (models.Account, error) {
+// RefreshTier evaluates the tier of the account, so the tier drops once accruals leave
+// the qualifying window, and returns the progress to the next tier.
+func (s *Store) RefreshTier(ctx context.Context, accountID string) (models.TierStatus, error) {
 	var acc models.Account
 
 	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return acc, err
+		return models.TierStatus{}, err
 	}
 
 	err = tx.NewSelect().
 		Model(&acc).
-		Where("user_id = ?", userID).
+		Where("id = ?", accountID).
 		For("UPDATE").
 		Scan(ctx)
 	if err != nil {
 		tx.Rollback() //nolint:errcheck
-		return acc, err
-	}
-
-	if acc.CurrentPointsTotal < sum {
-		tx.Rollback() //nolint:errcheck
-
-		return acc, &store.NotEnoughBalanceError{
-			Err:               errors.New("not enough balance"),
-			Balance:           acc.CurrentPointsTotal,
-			WithdrawRequested: sum,
-		}
-	}
-
-	acc.CurrentPointsTotal = acc.CurrentPointsTotal - sum
-	acc.WithdrawnTotal = acc.WithdrawnTotal + sum
-	acc.UpdatedAt = time.Now()
-
-	_, err = tx.NewUpdate().
-		Model(&acc).
-		WherePK().
-		Column("current_points_total", "withdrawn_total", "updated_at").
-		Returning("*").
-		Exec(ctx)
-	if err != nil {
-		tx.Rollback() //nolint:errcheck
-		return acc, err
-	}
-
-	transaction := models.Transaction{
-		ID:          uuid.NewString(),
-		AccountID:   acc.ID,
-		Amount:      sum,
-		OrderNumber: orderNumber,
-		Direction:   models.TxDirectionWithdrawal,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		return models.TierStatus{}, err
 	}
 
-	_, err = tx.NewInsert().
-		Model(&transaction).
-		Exec(ctx)
+	_, status, err := s.updateTier(ctx, tx, acc)
 	if err != nil {
 		tx.Rollback() //nolint:errcheck
-		return acc, err
+		return status, err
 	}
 
 	if err = tx.Commit(); err != nil {
 		tx.Rollback() //nolint:errcheck
-		return acc, err
+		return status, err
 	}
 
-	return acc, nil
+	return status, nil
 }
 
-// GetWithdrawals fetches all transactions of specified direction
-func (s *Store) GetWithdrawals(ctx context.Context, accountID string, direction models.TxDirection, limit int) ([]models.Transaction, error) {
-	var result []models.Transaction
+// ListTierChanges fetches tier changes of the account, the latest first.
+func (s *Store) ListTierChanges(ctx context.Context, accountID string) ([]models.TierChange, error) {
+	var result []models.TierChange
 
 	err := s.conn.NewSelect().
 		Model(&result).
 		Where("account_id = ?", accountID).
-		Where("direction = ?", direction).
 		Order("created_at DESC").
-		Limit(limit).
 		Scan(ctx)
 
 	return result, err
 }
 
-// AddBalance adds accrued points for order to account balance.
-func (s *Store) AddBalance(ctx context.Context, order models.Order) (models.Account, error) {
+// AdjustBalance credits or debits the account on behalf of an operator. Debits never exceed available balance.
+func (s *Store) AdjustBalance(ctx context.Context, adjustment models.Adjustment) (models.Transaction, error) {
 	var acc models.Account
 
+	transaction := models.Transaction{
+		ID:        uuid.NewString(),
+		AccountID: adjustment.AccountID,
+		Amount:    adjustment.Amount,
+		Direction: models.TxDirectionAdjustment,
+		Reason:    adjustment.Reason,
+		Comment:   adjustment.Comment,
+		Operator:  adjustment.Operator,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := adjustment.Validate(); err != nil {
+		return transaction, err
+	}
+
 	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
 	if err != nil {
-		return acc, err
+		return transaction, err
 	}
 
 	err = tx.NewSelect().
 		Model(&acc).
-		Where("id = ?", order.AccountID).
+		Where("id = ?", adjustment.AccountID).
 		For("UPDATE").
 		Scan(ctx)
 	if err != nil {
 		tx.Rollback() //nolint:errcheck
-		return acc, err
+		return transaction, err
 	}
 
-	acc.CurrentPointsTotal = acc.CurrentPointsTotal + order.Accrual
-	acc.UpdatedAt = time.Now()
+	if adjustment.Amount < 0 && acc.Available() < -adjustment.Amount {
+		tx.Rollback() //nolint:errcheck
 
-	_, err = tx.NewUpdate().
-		Model(&acc).
-		WherePK().
-		Column("current_points_total", "updated_at").
-		Returning("*").
-		Exec(ctx)
-	if err != nil {
+		return transaction, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.Available(),
+			WithdrawRequested: -adjustment.Amount,
+		}
+	}
+
+	if _, err = s.post(ctx, tx, acc, transaction); err != nil {
 		tx.Rollback() //nolint:errcheck
-		return acc, err
+		return transaction, err
 	}
 
-	transaction := models.Transaction{
-		ID:          uuid.NewString(),
-		AccountID:   acc.ID,
-		Amount:      order.Accrual,
-		OrderNumber: order.Number,
-		Direction:   models.TxDirectionAccrual,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transaction, err
 	}
 
-	_, err = tx.NewInsert().
-		Model(&transaction).
+	return transaction, nil
+}
+
+// CreateOrder saves new order in database.
+func (s *Store) CreateOrder(ctx context.Context, order *models.Order) error {
+	err := s.conn.NewInsert().Model(order).Returning("*").Scan(ctx, order)
+	if err != nil {
+		return &store.InsertError{Err: err}
+	}
+	return nil
+}
+
+// GetOrderByNumber fetches order by its number.
+func (s *Store) GetOrderByNumber(ctx context.Context, orderNumber string) (models.Order, error) {
+	var result models.Order
+
+	err := s.conn.NewSelect().
+		Model(&result).
+		Where("number = ?", orderNumber).
+		Relation("Account").
+		Scan(ctx)
+
+	return result, err
+}
+
+// ListOrders fetches a page of orders linked to the account.
+func (s *Store) ListOrders(ctx context.Context, accountID string, filter models.OrderFilter) ([]models.Order, error) {
+	var result []models.Order
+
+	q := s.conn.NewSelect().
+		Model(&result).
+		Where("account_id = ?", accountID)
+	if len(filter.Statuses) > 0 {
+		q = q.Where("status IN (?)", bun.In(filter.Statuses))
+	}
+	q = applyTimeRange(q, filter.From, filter.To)
+	q = applyPage(q, filter.Page)
+
+	err := q.Scan(ctx)
+
+	return result, err
+}
+
+// ListOrdersByStatus fetches orders in specified statuses.
+func (s *Store) ListOrdersByStatus(ctx context.Context, statuses []models.OrderStatus, limit int) ([]models.Order, error) {
+	var result []models.Order
+
+	err := s.conn.NewSelect().
+		Model(&result).
+		Where("status IN (?)", bun.In(statuses)).
+		Order("created_at ASC").
+		Limit(limit).
+		Scan(ctx)
+
+	return result, err
+}
+
+// ClaimDueOrders takes a lease on orders in specified statuses which are due to be checked at the moment,
+// the longest waiting first. The lease moves the next check of the orders by leaseTTL, so that other
+// instances skip them until the orders are rescheduled or the lease expires. Rows locked by claims
+// of other instances are skipped rather than waited for.
+func (s *Store) ClaimDueOrders(ctx context.Context, statuses []models.OrderStatus, now time.Time, leaseTTL time.Duration, limit int) ([]models.Order, error) {
+	var result []models.Order
+
+	due := s.conn.NewSelect().
+		Model((*models.Order)(nil)).
+		Column("id").
+		Where("status IN (?)", bun.In(statuses)).
+		Where("next_check_at <= ?", now).
+		Order("next_check_at ASC", "created_at ASC").
+		Limit(limit).
+		For("UPDATE SKIP LOCKED")
+
+	err := s.conn.NewUpdate().
+		Model(&result).
+		Set("next_check_at = ?", now.Add(leaseTTL)).
+		Where("id IN (?)", due).
+		Returning("*").
+		Scan(ctx)
+
+	return result, err
+}
+
+// ScheduleOrderCheck saves the number of attempts and the time of the next check of the order
+// unless its status has changed meanwhile.
+func (s *Store) ScheduleOrderCheck(ctx context.Context, order models.Order) error {
+	_, err := s.conn.NewUpdate().
+		Model(&order).
+		Column("attempts", "next_check_at").
+		Where("id = ?", order.ID).
+		Where("status = ?", order.Status).
+		Exec(ctx)
+
+	return err
+}
+
+// UpdateOrder updates order in database.
+func (s *Store) UpdateOrder(ctx context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return order, err
+	}
+
+	if checkOrder, err := s.updateOrder(ctx, tx, order, prevStatus); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return checkOrder, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return order, err
+	}
+
+	return order, nil
+}
+
+// ProcessOrder updates order in database. If the order reaches PROCESSED status, its accrual
+// is credited to the account balance within the same database transaction.
+func (s *Store) ProcessOrder(ctx context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return order, err
+	}
+
+	if checkOrder, err := s.updateOrder(ctx, tx, order, prevStatus); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return checkOrder, err
+	}
+
+	var referral models.Referral
+	if order.Status == models.OrderStatusProcessed {
+		if referral, err = s.lockPendingReferral(ctx, tx, order); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return order, err
+		}
+	}
+
+	if order.Status == models.OrderStatusProcessed && order.Accrual > 0 {
+		if _, err = s.addBalance(ctx, tx, order); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return order, err
+		}
+	}
+
+	if referral.ID != "" {
+		if err = s.rewardReferral(ctx, tx, order, referral); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return order, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return order, err
+	}
+
+	return order, nil
+}
+
+// ReturnOrder takes back accrual of the processed order which goods have been returned to the shop
+// and moves the order to RETURNED status. If the accrual has already been spent, the policy defines
+// whether the balance becomes negative, the rest is recorded as a debt or forgiven.
+func (s *Store) ReturnOrder(ctx context.Context, orderNumber string, policy models.ClawbackPolicy) (models.Clawback, error) {
+	var (
+		order    models.Order
+		acc      models.Account
+		accrual  models.Transaction
+		clawback = models.Clawback{OrderNumber: orderNumber}
+	)
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return clawback, err
+	}
+
+	err = tx.NewSelect().
+		Model(&order).
+		Where("number = ?", orderNumber).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return clawback, err
+	}
+	if order.Status != models.OrderStatusProcessed {
+		tx.Rollback() //nolint:errcheck
+		return clawback, fmt.Errorf("%w: order %s is %s", store.ErrOrderNotProcessed, orderNumber, order.Status)
+	}
+
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("id = ?", order.AccountID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return clawback, err
+	}
+
+	err = tx.NewSelect().
+		Model(&accrual).
+		Where("order_number = ?", orderNumber).
+		Where("direction = ?", models.TxDirectionAccrual).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback() //nolint:errcheck
+		return clawback, err
+	}
+
+	if err == nil {
+		clawback = ledger.Clawback(acc, orderNumber, accrual.Amount, policy)
+		acc.DebtTotal += clawback.Debt
+
+		if clawback.Clawed > 0 {
+			_, err = s.post(ctx, tx, acc, models.Transaction{
+				ID:          uuid.NewString(),
+				AccountID:   acc.ID,
+				Amount:      clawback.Clawed,
+				OrderNumber: orderNumber,
+				Direction:   models.TxDirectionClawback,
+				ParentID:    accrual.ID,
+				CreatedAt:   time.Now(),
+				UpdatedAt:   time.Now(),
+			})
+		} else if clawback.Debt > 0 {
+			acc.UpdatedAt = time.Now()
+			_, err = tx.NewUpdate().
+				Model(&acc).
+				WherePK().
+				Column("debt_total", "updated_at").
+				Exec(ctx)
+		}
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+			return clawback, err
+		}
+	}
+
+	order.Status = models.OrderStatusReturned
+	order.UpdatedAt = time.Now()
+
+	_, err = tx.NewUpdate().
+		Model(&order).
+		WherePK().
+		Column("status", "updated_at").
 		Exec(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return clawback, err
+	}
+
+	// accrual of the returned order no longer qualifies for the tier
+	if _, _, err = s.updateTier(ctx, tx, acc); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return clawback, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return clawback, err
+	}
+
+	return clawback, nil
+}
+
+// WithdrawBalance withdraws points from balance if there are enough points and daily and monthly caps
+// of rules are not exceeded. Repeated withdrawal for the same order and sum returns the account unchanged.
+func (s *Store) WithdrawBalance(ctx context.Context, userID string, orderNumber string, sum points.Points, rules models.WithdrawalRules) (models.Account, error) {
+	var acc models.Account
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return acc, err
+	}
+
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("user_id = ?", userID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return acc, err
+	}
+
+	var withdrawal models.Transaction
+	err = tx.NewSelect().
+		Model(&withdrawal).
+		Where("order_number = ?", orderNumber).
+		Where("direction = ?", models.TxDirectionWithdrawal).
+		Scan(ctx)
+	if err == nil {
+		tx.Rollback() //nolint:errcheck
+
+		if withdrawal.AccountID == acc.ID && withdrawal.Amount == sum {
+			return acc, nil
+		}
+		return acc, &store.InsertError{Err: fmt.Errorf("%w: order %s has already been paid with points", store.ErrIntegrityViolation, orderNumber)}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback() //nolint:errcheck
+		return acc, err
+	}
+
+	held, err := tx.NewSelect().
+		Model((*models.Hold)(nil)).
+		Where("order_number = ?", orderNumber).
+		Where("status = ?", models.HoldStatusActive).
+		Exists(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return acc, err
+	}
+	if held {
+		tx.Rollback() //nolint:errcheck
+		return acc, &store.InsertError{Err: fmt.Errorf("%w: order %s is reserved by a hold", store.ErrIntegrityViolation, orderNumber)}
+	}
+
+	if acc.Frozen() {
+		tx.Rollback() //nolint:errcheck
+		return acc, fmt.Errorf("%w: account %s", store.ErrAccountFrozen, acc.ID)
+	}
+
+	if err = s.checkWithdrawalCaps(ctx, tx, acc.ID, sum, rules); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return acc, err
+	}
+
+	if acc.Available() < sum {
+		tx.Rollback() //nolint:errcheck
+
+		return acc, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.Available(),
+			WithdrawRequested: sum,
+		}
+	}
+
+	transaction := models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      sum,
+		OrderNumber: orderNumber,
+		Direction:   models.TxDirectionWithdrawal,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	acc, err = s.post(ctx, tx, acc, transaction)
 	if err != nil {
 		tx.Rollback() //nolint:errcheck
 		return acc, err
@@ -301,6 +926,1491 @@ func (s *Store) AddBalance(ctx context.Context, order models.Order) (models.Acco
 	return acc, nil
 }
 
+// checkWithdrawalCaps ensures that the withdrawal together with withdrawals and active holds made
+// within 24 hours and a month does not exceed daily and monthly caps of rules. Captured holds
+// are counted as withdrawals.
+func (s *Store) checkWithdrawalCaps(ctx context.Context, tx bun.Tx, accountID string, sum points.Points, rules models.WithdrawalRules) error {
+	now := time.Now()
+	caps := []struct {
+		violation models.WithdrawalViolation
+		limit     points.Points
+		since     time.Time
+	}{
+		{models.WithdrawalViolationDailyLimit, rules.DailyLimit, now.Add(-24 * time.Hour)},
+		{models.WithdrawalViolationMonthlyLimit, rules.MonthlyLimit, now.AddDate(0, -1, 0)},
+	}
+	for _, c := range caps {
+		if c.limit == 0 {
+			continue
+		}
+
+		var withdrawn, held points.Points
+		err := tx.NewSelect().
+			Model((*models.Transaction)(nil)).
+			ColumnExpr("COALESCE(SUM(amount), 0)").
+			Where("account_id = ?", accountID).
+			Where("direction = ?", models.TxDirectionWithdrawal).
+			Where("created_at > ?", c.since).
+			Scan(ctx, &withdrawn)
+		if err != nil {
+			return err
+		}
+		err = tx.NewSelect().
+			Model((*models.Hold)(nil)).
+			ColumnExpr("COALESCE(SUM(amount), 0)").
+			Where("account_id = ?", accountID).
+			Where("status = ?", models.HoldStatusActive).
+			Where("expires_at > ?", now).
+			Where("created_at > ?", c.since).
+			Scan(ctx, &held)
+		if err != nil {
+			return err
+		}
+
+		withdrawn += held
+		if withdrawn+sum > c.limit {
+			return &store.WithdrawalLimitError{
+				Err:       fmt.Errorf("withdrawal of %s exceeds %s of %s", sum, c.violation, c.limit),
+				Violation: c.violation,
+				Limit:     c.limit,
+				Withdrawn: withdrawn,
+				Requested: sum,
+			}
+		}
+	}
+
+	return nil
+}
+
+// RefundWithdrawal returns points withdrawn for the order back to the account. Refunds never exceed
+// the withdrawn amount in total, zero sum refunds the whole part which has not been refunded yet.
+func (s *Store) RefundWithdrawal(ctx context.Context, orderNumber string, sum points.Points) (models.Transaction, error) {
+	var (
+		acc        models.Account
+		withdrawal models.Transaction
+		refund     models.Transaction
+	)
+
+	err := s.conn.NewSelect().
+		Model(&withdrawal).
+		Where("order_number = ?", orderNumber).
+		Where("direction = ?", models.TxDirectionWithdrawal).
+		Scan(ctx)
+	if err != nil {
+		return refund, err
+	}
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return refund, err
+	}
+
+	// refunds of the withdrawal are serialized by the account lock
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("id = ?", withdrawal.AccountID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return refund, err
+	}
+
+	var refunded points.Points
+	err = tx.NewSelect().
+		Model((*models.Transaction)(nil)).
+		ColumnExpr("COALESCE(SUM(amount), 0)").
+		Where("parent_id = ?", withdrawal.ID).
+		Where("direction = ?", models.TxDirectionRefund).
+		Scan(ctx, &refunded)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return refund, err
+	}
+
+	sum, err = ledger.RefundAmount(withdrawal, refunded, sum)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return refund, err
+	}
+
+	refund = models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      sum,
+		OrderNumber: orderNumber,
+		Direction:   models.TxDirectionRefund,
+		ParentID:    withdrawal.ID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if _, err = s.post(ctx, tx, acc, refund); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return refund, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return refund, err
+	}
+
+	return refund, nil
+}
+
+// TransferPoints moves points from the user account to the account of the recipient. Transfers of the user
+// within the last 24 hours never exceed dailyLimit unless it is zero.
+func (s *Store) TransferPoints(ctx context.Context, userID string, recipientLogin string, sum points.Points, dailyLimit points.Points) (models.Transfer, error) {
+	var (
+		recipient models.Account
+		accounts  []models.Account
+		transfer  = models.Transfer{Recipient: recipientLogin, Amount: sum}
+	)
+
+	err := s.conn.NewSelect().
+		Model(&recipient).
+		Join("JOIN users AS u ON u.id = account.user_id").
+		Where("u.login = ?", recipientLogin).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return transfer, fmt.Errorf("%w: %s", store.ErrRecipientNotFound, recipientLogin)
+		}
+		return transfer, err
+	}
+	if recipient.UserID == userID {
+		return transfer, store.ErrSelfTransfer
+	}
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return transfer, err
+	}
+
+	// both accounts are locked in the same order to avoid deadlocks with a transfer in the opposite direction
+	err = tx.NewSelect().
+		Model(&accounts).
+		Where("user_id = ?", userID).
+		WhereOr("id = ?", recipient.ID).
+		Order("id").
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transfer, err
+	}
+
+	var sender models.Account
+	for _, acc := range accounts {
+		if acc.ID == recipient.ID {
+			recipient = acc
+		} else {
+			sender = acc
+		}
+	}
+	if sender.ID == "" {
+		tx.Rollback() //nolint:errcheck
+		return transfer, sql.ErrNoRows
+	}
+
+	if sender.Frozen() {
+		tx.Rollback() //nolint:errcheck
+		return transfer, fmt.Errorf("%w: account %s", store.ErrAccountFrozen, sender.ID)
+	}
+
+	if dailyLimit > 0 {
+		var transferred points.Points
+		err = tx.NewSelect().
+			Model((*models.Transaction)(nil)).
+			ColumnExpr("COALESCE(SUM(amount), 0)").
+			Where("account_id = ?", sender.ID).
+			Where("direction = ?", models.TxDirectionTransferOut).
+			Where("created_at > ?", time.Now().Add(-24*time.Hour)).
+			Scan(ctx, &transferred)
+		if err != nil {
+			tx.Rollback() //nolint:errcheck
+			return transfer, err
+		}
+
+		if err = ledger.CheckTransferLimit(transferred, sum, dailyLimit); err != nil {
+			tx.Rollback() //nolint:errcheck
+			return transfer, err
+		}
+	}
+
+	if sender.Available() < sum {
+		tx.Rollback() //nolint:errcheck
+
+		return transfer, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           sender.Available(),
+			WithdrawRequested: sum,
+		}
+	}
+
+	out := models.Transaction{
+		ID:        uuid.NewString(),
+		AccountID: sender.ID,
+		Amount:    sum,
+		Direction: models.TxDirectionTransferOut,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	in := out
+	in.ID = uuid.NewString()
+	in.AccountID = recipient.ID
+	in.Direction = models.TxDirectionTransferIn
+	in.ParentID = out.ID
+
+	if _, err = s.post(ctx, tx, sender, out); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transfer, err
+	}
+	if _, err = s.post(ctx, tx, recipient, in); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transfer, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transfer, err
+	}
+
+	transfer.ID = out.ID
+	transfer.CreatedAt = out.CreatedAt
+
+	return transfer, nil
+}
+
+// CreateHold reserves points of the user account for the order until expiresAt if daily and monthly caps
+// of rules are not exceeded. Repeated request for the same order and sum returns the existing hold.
+func (s *Store) CreateHold(ctx context.Context, userID string, orderNumber string, sum points.Points, expiresAt time.Time, rules models.WithdrawalRules) (models.Hold, error) {
+	var (
+		acc  models.Account
+		hold models.Hold
+	)
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return hold, err
+	}
+
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("user_id = ?", userID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	err = tx.NewSelect().
+		Model(&hold).
+		Where("order_number = ?", orderNumber).
+		Where("status IN (?)", bun.In([]models.HoldStatus{models.HoldStatusActive, models.HoldStatusCaptured})).
+		Scan(ctx)
+	if err == nil {
+		tx.Rollback() //nolint:errcheck
+
+		if hold.AccountID == acc.ID && hold.Amount == sum {
+			return hold, nil
+		}
+		return hold, &store.InsertError{Err: fmt.Errorf("%w: order %s is already reserved", store.ErrIntegrityViolation, orderNumber)}
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	paid, err := tx.NewSelect().
+		Model((*models.Transaction)(nil)).
+		Where("order_number = ?", orderNumber).
+		Where("direction = ?", models.TxDirectionWithdrawal).
+		Exists(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+	if paid {
+		tx.Rollback() //nolint:errcheck
+		return hold, &store.InsertError{Err: fmt.Errorf("%w: order %s has already been paid with points", store.ErrIntegrityViolation, orderNumber)}
+	}
+
+	if acc.Frozen() {
+		tx.Rollback() //nolint:errcheck
+		return hold, fmt.Errorf("%w: account %s", store.ErrAccountFrozen, acc.ID)
+	}
+
+	if err = s.checkWithdrawalCaps(ctx, tx, acc.ID, sum, rules); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	if acc.Available() < sum {
+		tx.Rollback() //nolint:errcheck
+
+		return hold, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.Available(),
+			WithdrawRequested: sum,
+		}
+	}
+
+	hold = models.Hold{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		OrderNumber: orderNumber,
+		Amount:      sum,
+		Status:      models.HoldStatusActive,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	_, err = tx.NewInsert().
+		Model(&hold).
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, &store.InsertError{Err: err}
+	}
+
+	acc.HeldTotal += sum
+	acc.UpdatedAt = time.Now()
+
+	_, err = tx.NewUpdate().
+		Model(&acc).
+		WherePK().
+		Column("held_total", "updated_at").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	return hold, nil
+}
+
+// CaptureHold withdraws points reserved by the active hold. Capturing a captured hold is a no-op.
+func (s *Store) CaptureHold(ctx context.Context, userID string, holdID string) (models.Hold, error) {
+	var hold models.Hold
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return hold, err
+	}
+
+	acc, hold, err := s.lockHold(ctx, tx, userID, holdID)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+	if hold.Status == models.HoldStatusCaptured {
+		tx.Rollback() //nolint:errcheck
+		return hold, nil
+	}
+	if !hold.Active(time.Now()) {
+		tx.Rollback() //nolint:errcheck
+		return hold, store.ErrHoldNotActive
+	}
+
+	// reserved points could have expired while the hold was active
+	if acc.CurrentPointsTotal < hold.Amount {
+		tx.Rollback() //nolint:errcheck
+
+		return hold, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.CurrentPointsTotal,
+			WithdrawRequested: hold.Amount,
+		}
+	}
+
+	transaction := models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      hold.Amount,
+		OrderNumber: hold.OrderNumber,
+		Direction:   models.TxDirectionWithdrawal,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	acc.HeldTotal -= hold.Amount
+	if _, err = s.post(ctx, tx, acc, transaction); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	hold.Status = models.HoldStatusCaptured
+	hold.TransactionID = transaction.ID
+	hold.UpdatedAt = time.Now()
+
+	_, err = tx.NewUpdate().
+		Model(&hold).
+		WherePK().
+		Column("status", "transaction_id", "updated_at").
+		Exec(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	return hold, nil
+}
+
+// VoidHold releases points reserved by the active hold. Voiding a voided hold is a no-op.
+func (s *Store) VoidHold(ctx context.Context, userID string, holdID string) (models.Hold, error) {
+	var hold models.Hold
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return hold, err
+	}
+
+	acc, hold, err := s.lockHold(ctx, tx, userID, holdID)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+	if hold.Status == models.HoldStatusVoided {
+		tx.Rollback() //nolint:errcheck
+		return hold, nil
+	}
+	if !hold.Active(time.Now()) {
+		tx.Rollback() //nolint:errcheck
+		return hold, store.ErrHoldNotActive
+	}
+
+	if hold, err = s.releaseHold(ctx, tx, acc, hold, models.HoldStatusVoided); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return hold, err
+	}
+
+	return hold, nil
+}
+
+// ExpireHolds releases points reserved by active holds which have expired.
+func (s *Store) ExpireHolds(ctx context.Context, now time.Time) (int64, error) {
+	var holds []models.Hold
+
+	err := s.conn.NewSelect().
+		Model(&holds).
+		Where("status = ?", models.HoldStatusActive).
+		Where("expires_at <= ?", now).
+		Order("expires_at ASC").
+		Limit(expireHoldsBatchSize).
+		Scan(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired int64
+	for _, hold := range holds {
+		ok, err := s.expireHold(ctx, hold, now)
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+
+	return expired, nil
+}
+
+// ListTransactions fetches a page of account transactions.
+func (s *Store) ListTransactions(ctx context.Context, accountID string, filter models.TransactionFilter) ([]models.Transaction, error) {
+	var result []models.Transaction
+
+	q := s.conn.NewSelect().
+		Model(&result).
+		Where("account_id = ?", accountID)
+	if len(filter.Directions) > 0 {
+		q = q.Where("direction IN (?)", bun.In(filter.Directions))
+	}
+	q = applyTimeRange(q, filter.From, filter.To)
+	q = applyPage(q, filter.Page)
+
+	err := q.Scan(ctx)
+
+	return result, err
+}
+
+// ListStatement fetches transactions of the account with wallet balance after each of them.
+// Running balance is derived from wallet ledger entries over the whole account history,
+// filters and pagination are applied afterwards.
+func (s *Store) ListStatement(ctx context.Context, accountID string, filter models.TransactionFilter) ([]models.StatementEntry, error) {
+	var result []models.StatementEntry
+
+	history := s.conn.NewSelect().
+		TableExpr("transactions AS t").
+		ColumnExpr("t.id, t.direction, t.order_number, t.amount, t.reason, t.comment, t.created_at").
+		ColumnExpr("SUM(le.amount) OVER (ORDER BY t.created_at, t.id) AS balance_after").
+		Join("JOIN ledger_entries AS le ON le.transaction_id = t.id").
+		Where("t.account_id = ?", accountID).
+		Where("le.ledger_account = ?", models.LedgerAccountWallet)
+
+	q := s.conn.NewSelect().
+		Model(&result).
+		ModelTableExpr("(?) AS statement_entry", history)
+	if len(filter.Directions) > 0 {
+		q = q.Where("direction IN (?)", bun.In(filter.Directions))
+	}
+	q = applyTimeRange(q, filter.From, filter.To)
+	q = applyPage(q, filter.Page)
+
+	err := q.Scan(ctx)
+
+	return result, err
+}
+
+// ListExpiringLots fetches lots of the account with remaining points which expire before the specified time.
+func (s *Store) ListExpiringLots(ctx context.Context, accountID string, before time.Time) ([]models.PointsLot, error) {
+	var result []models.PointsLot
+
+	err := s.conn.NewSelect().
+		Model(&result).
+		Where("account_id = ?", accountID).
+		Where("remaining > 0").
+		Where("expires_at < ?", before).
+		OrderExpr("expires_at ASC, created_at ASC, id ASC").
+		Scan(ctx)
+
+	return result, err
+}
+
+// ExpirePoints posts expiry transactions for remaining points of due lots. Each lot is expired
+// in its own database transaction, so a failure does not roll back lots expired before it.
+func (s *Store) ExpirePoints(ctx context.Context, now time.Time) ([]models.Transaction, error) {
+	var lots []models.PointsLot
+
+	err := s.conn.NewSelect().
+		Model(&lots).
+		Where("remaining > 0").
+		Where("expires_at <= ?", now).
+		OrderExpr("expires_at ASC, created_at ASC, id ASC").
+		Limit(expirePointsBatchSize).
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.Transaction, 0, len(lots))
+	for _, lot := range lots {
+		transaction, expired, err := s.expireLot(ctx, lot, now)
+		if err != nil {
+			return result, err
+		}
+		if expired {
+			result = append(result, transaction)
+		}
+	}
+
+	return result, nil
+}
+
+// CreateIdempotencyKey reserves idempotency key for the user.
+func (s *Store) CreateIdempotencyKey(ctx context.Context, key models.IdempotencyKey) error {
+	_, err := s.conn.NewInsert().Model(&key).Exec(ctx)
+	if err != nil {
+		return &store.InsertError{Err: err}
+	}
+
+	return nil
+}
+
+// GetIdempotencyKey fetches idempotency key of the user.
+func (s *Store) GetIdempotencyKey(ctx context.Context, userID string, key string) (models.IdempotencyKey, error) {
+	var result models.IdempotencyKey
+
+	err := s.conn.NewSelect().
+		Model(&result).
+		Where("user_id = ?", userID).
+		Where("key = ?", key).
+		Scan(ctx)
+
+	return result, err
+}
+
+// CompleteIdempotencyKey saves response for the reserved idempotency key.
+func (s *Store) CompleteIdempotencyKey(ctx context.Context, key models.IdempotencyKey) error {
+	_, err := s.conn.NewUpdate().
+		Model(&key).
+		WherePK().
+		Column("status_code", "content_type", "response_body").
+		Exec(ctx)
+
+	return err
+}
+
+// DeleteIdempotencyKey releases idempotency key so that the request can be retried.
+func (s *Store) DeleteIdempotencyKey(ctx context.Context, userID string, key string) error {
+	_, err := s.conn.NewDelete().
+		Model((*models.IdempotencyKey)(nil)).
+		Where("user_id = ?", userID).
+		Where("key = ?", key).
+		Exec(ctx)
+
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys deletes idempotency keys expired by now.
+func (s *Store) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.conn.NewDelete().
+		Model((*models.IdempotencyKey)(nil)).
+		Where("expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// ListLedgerEntries fetches wallet ledger entries of the account, newest first.
+func (s *Store) ListLedgerEntries(ctx context.Context, accountID string, limit int) ([]models.LedgerEntry, error) {
+	var result []models.LedgerEntry
+
+	err := s.conn.NewSelect().
+		Model(&result).
+		Where("ledger_account = ?", models.LedgerAccountWallet).
+		Where("account_id = ?", accountID).
+		Order("created_at DESC").
+		Limit(limit).
+		Scan(ctx)
+
+	return result, err
+}
+
+// GetLedgerBalances derives balances of all ledger accounts from their entries.
+func (s *Store) GetLedgerBalances(ctx context.Context) ([]models.LedgerBalance, error) {
+	var result []models.LedgerBalance
+
+	err := s.conn.NewSelect().
+		TableExpr("ledger_entries").
+		ColumnExpr("ledger_account").
+		ColumnExpr("COALESCE(account_id::text, '') AS account_id").
+		ColumnExpr("SUM(amount) AS balance").
+		Group("ledger_account", "account_id").
+		Order("ledger_account", "account_id").
+		Scan(ctx, &result)
+
+	return result, err
+}
+
+// ReconcileAccounts compares cached totals of every account with balances derived from its ledger entries,
+// active holds and processed orders. If repair is set, missing accruals are credited like processed orders
+// and cached totals are reset to derived values within a single database transaction.
+func (s *Store) ReconcileAccounts(ctx context.Context, repair bool) ([]models.AccountDrift, error) {
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, err
+	}
+
+	drifts, err := s.reconcileAccounts(ctx, tx, repair)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+
+	if !repair {
+		tx.Rollback() //nolint:errcheck
+		return drifts, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return nil, err
+	}
+
+	return drifts, nil
+}
+
+// AddBalance adds accrued points for order to account balance.
+func (s *Store) AddBalance(ctx context.Context, order models.Order) (models.Account, error) {
+	var acc models.Account
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return acc, err
+	}
+
+	acc, err = s.addBalance(ctx, tx, order)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return acc, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return acc, err
+	}
+
+	return acc, nil
+}
+
+func (s *Store) updateOrder(ctx context.Context, tx bun.Tx, order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
+	var checkOrder models.Order
+
+	err := tx.NewSelect().
+		Model(&checkOrder).
+		Where("id = ?", order.ID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return checkOrder, err
+	}
+	if checkOrder.Status != prevStatus {
+		return checkOrder, errors.New("sql: update conflict")
+	}
+
+	columns := []string{"status", "accrual", "updated_at"}
+	if !order.NextCheckAt.IsZero() {
+		columns = append(columns, "attempts", "next_check_at")
+	}
+
+	_, err = tx.NewUpdate().
+		Model(&order).
+		WherePK().
+		Column(columns...).
+		Returning("*").
+		Exec(ctx)
+
+	return checkOrder, err
+}
+
+// addBalance credits order accrual to account balance. Only one accrual transaction
+// per order is allowed by a unique index, so an order can never be credited twice.
+// The accrual is multiplied by the multiplier of the tier reached with this order and raised
+// by the best campaign active at the moment.
+func (s *Store) addBalance(ctx context.Context, tx bun.Tx, order models.Order) (models.Account, error) {
+	var (
+		acc       models.Account
+		campaigns []models.Campaign
+	)
+
+	err := tx.NewSelect().
+		Model(&acc).
+		Where("id = ?", order.AccountID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return acc, err
+	}
+
+	acc, status, err := s.updateTier(ctx, tx, acc)
+	if err != nil {
+		return acc, err
+	}
+
+	now := time.Now()
+	err = tx.NewSelect().
+		Model(&campaigns).
+		Where("starts_at <= ?", now).
+		Where("ends_at > ?", now).
+		Where("disabled_at IS NULL").
+		Order("created_at").
+		Scan(ctx)
+	if err != nil {
+		return acc, err
+	}
+
+	amount := ledger.Multiply(order.Accrual, status.Multiplier)
+	campaign, bonus := ledger.ApplyCampaign(campaigns, acc, amount, now)
+
+	transaction := models.Transaction{
+		ID:            uuid.NewString(),
+		AccountID:     acc.ID,
+		Amount:        amount + bonus,
+		OrderNumber:   order.Number,
+		Direction:     models.TxDirectionAccrual,
+		Tier:          status.Tier,
+		CampaignID:    campaign.ID,
+		CampaignBonus: bonus,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	acc, err = s.post(ctx, tx, acc, transaction)
+	if err != nil || acc.DebtTotal == 0 {
+		return acc, err
+	}
+
+	return s.settleDebt(ctx, tx, acc, transaction)
+}
+
+// settleDebt takes back a debt left by clawbacks from the accrual.
+func (s *Store) settleDebt(ctx context.Context, tx bun.Tx, acc models.Account, accrual models.Transaction) (models.Account, error) {
+	amount := min(acc.DebtTotal, accrual.Amount)
+	acc.DebtTotal -= amount
+
+	return s.post(ctx, tx, acc, models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      amount,
+		OrderNumber: accrual.OrderNumber,
+		Direction:   models.TxDirectionClawback,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+}
+
+// lockPendingReferral fetches the pending referral of the user who placed the order and locks accounts
+// of the referee and the referrer ordered by ID, the same way transfers lock them, so that the accrual
+// and referral bonuses never wait for a transfer between the same users in the opposite order.
+// Empty referral means the user has not been referred or has already been rewarded.
+func (s *Store) lockPendingReferral(ctx context.Context, tx bun.Tx, order models.Order) (models.Referral, error) {
+	var (
+		referral models.Referral
+		accounts []models.Account
+	)
+
+	err := tx.NewSelect().
+		Model(&referral).
+		Where("referee_id = (SELECT user_id FROM accounts WHERE id = ?)", order.AccountID).
+		Where("status = ?", models.ReferralStatusPending).
+		For("UPDATE").
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Referral{}, nil
+	}
+	if err != nil {
+		return referral, err
+	}
+
+	err = tx.NewSelect().
+		Model(&accounts).
+		Where("user_id IN (?)", bun.In([]string{referral.RefereeID, referral.ReferrerID})).
+		Order("id").
+		For("UPDATE").
+		Scan(ctx)
+
+	return referral, err
+}
+
+// rewardReferral credits referral bonuses for the first processed order of the referee.
+// The referral and both accounts must be locked by lockPendingReferral.
+func (s *Store) rewardReferral(ctx context.Context, tx bun.Tx, order models.Order, referral models.Referral) error {
+	var err error
+
+	if err = s.creditReferralBonus(ctx, tx, referral.RefereeID, order.Number, s.referralBonuses.Referee); err != nil {
+		return err
+	}
+	// the order of the referee is not shown to the referrer
+	if err = s.creditReferralBonus(ctx, tx, referral.ReferrerID, "", s.referralBonuses.Referrer); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	referral.Status = models.ReferralStatusRewarded
+	referral.OrderNumber = order.Number
+	referral.ReferrerBonus = s.referralBonuses.Referrer
+	referral.RefereeBonus = s.referralBonuses.Referee
+	referral.RewardedAt = &now
+
+	_, err = tx.NewUpdate().
+		Model(&referral).
+		WherePK().
+		Column("status", "order_number", "referrer_bonus", "referee_bonus", "rewarded_at").
+		Exec(ctx)
+
+	return err
+}
+
+// creditReferralBonus posts referral transaction to the account of the user.
+func (s *Store) creditReferralBonus(ctx context.Context, tx bun.Tx, userID string, orderNumber string, amount points.Points) error {
+	var acc models.Account
+
+	if amount == 0 {
+		return nil
+	}
+
+	err := tx.NewSelect().
+		Model(&acc).
+		Where("user_id = ?", userID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.post(ctx, tx, acc, models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      amount,
+		OrderNumber: orderNumber,
+		Direction:   models.TxDirectionReferral,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+
+	return err
+}
+
+// updateTier evaluates the tier of the locked account from accruals of its processed orders
+// and records the change if the account has moved to another tier.
+func (s *Store) updateTier(ctx context.Context, tx bun.Tx, acc models.Account) (models.Account, models.TierStatus, error) {
+	var qualifying points.Points
+
+	now := time.Now()
+	q := tx.NewSelect().
+		Model((*models.Order)(nil)).
+		ColumnExpr("COALESCE(SUM(accrual), 0)").
+		Where("account_id = ?", acc.ID).
+		Where("status = ?", models.OrderStatusProcessed)
+	if since := ledger.QualifyingSince(s.tierPolicy, now); !since.IsZero() {
+		q = q.Where("updated_at >= ?", since)
+	}
+	if err := q.Scan(ctx, &qualifying); err != nil {
+		return acc, models.TierStatus{}, err
+	}
+
+	status := ledger.EvaluateTier(s.tierPolicy, qualifying)
+	if status.Tier == acc.Tier {
+		return acc, status, nil
+	}
+
+	change := models.TierChange{
+		ID:         uuid.NewString(),
+		AccountID:  acc.ID,
+		FromTier:   acc.Tier,
+		ToTier:     status.Tier,
+		Qualifying: qualifying,
+		CreatedAt:  now,
+	}
+	_, err := tx.NewInsert().
+		Model(&change).
+		Exec(ctx)
+	if err != nil {
+		return acc, status, err
+	}
+
+	acc.Tier = status.Tier
+	acc.UpdatedAt = now
+	_, err = tx.NewUpdate().
+		Model(&acc).
+		WherePK().
+		Column("tier", "updated_at").
+		Exec(ctx)
+
+	return acc, status, err
+}
+
+// expireLot posts expiry transaction for remaining points of the lot if it is still due.
+func (s *Store) expireLot(ctx context.Context, lot models.PointsLot, now time.Time) (models.Transaction, bool, error) {
+	var (
+		acc         models.Account
+		transaction models.Transaction
+	)
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return transaction, false, err
+	}
+
+	// lots are changed only while the account is locked
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("id = ?", lot.AccountID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transaction, false, err
+	}
+
+	err = tx.NewSelect().
+		Model(&lot).
+		Relation("Transaction").
+		Where("?TableAlias.id = ?", lot.ID).
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transaction, false, err
+	}
+	if !lot.Due(now) {
+		tx.Rollback() //nolint:errcheck
+		return transaction, false, nil
+	}
+
+	transaction = models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      lot.Remaining,
+		OrderNumber: lotOrderNumber(lot),
+		Direction:   models.TxDirectionExpiry,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	if _, err = s.post(ctx, tx, acc, transaction); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transaction, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return transaction, false, err
+	}
+
+	return transaction, true, nil
+}
+
+func (s *Store) expireHold(ctx context.Context, hold models.Hold, now time.Time) (bool, error) {
+	var acc models.Account
+
+	tx, err := s.conn.BeginTx(ctx, &sql.TxOptions{})
+	if err != nil {
+		return false, err
+	}
+
+	err = tx.NewSelect().
+		Model(&acc).
+		Where("id = ?", hold.AccountID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return false, err
+	}
+
+	err = tx.NewSelect().
+		Model(&hold).
+		WherePK().
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		tx.Rollback() //nolint:errcheck
+		return false, err
+	}
+	if hold.Status != models.HoldStatusActive || hold.Active(now) {
+		tx.Rollback() //nolint:errcheck
+		return false, nil
+	}
+
+	if _, err = s.releaseHold(ctx, tx, acc, hold, models.HoldStatusExpired); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		tx.Rollback() //nolint:errcheck
+		return false, err
+	}
+
+	return true, nil
+}
+
+// lockHold locks the user account and the hold which belongs to it.
+func (s *Store) lockHold(ctx context.Context, tx bun.Tx, userID, holdID string) (models.Account, models.Hold, error) {
+	var (
+		acc  models.Account
+		hold models.Hold
+	)
+
+	err := tx.NewSelect().
+		Model(&acc).
+		Where("user_id = ?", userID).
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return acc, hold, err
+	}
+
+	err = tx.NewSelect().
+		Model(&hold).
+		Where("id = ?", holdID).
+		Where("account_id = ?", acc.ID).
+		For("UPDATE").
+		Scan(ctx)
+
+	return acc, hold, err
+}
+
+// releaseHold returns points reserved by the hold to available balance. The account row must be locked by the caller.
+func (s *Store) releaseHold(ctx context.Context, tx bun.Tx, acc models.Account, hold models.Hold, status models.HoldStatus) (models.Hold, error) {
+	acc.HeldTotal -= hold.Amount
+	acc.UpdatedAt = time.Now()
+
+	_, err := tx.NewUpdate().
+		Model(&acc).
+		WherePK().
+		Column("held_total", "updated_at").
+		Exec(ctx)
+	if err != nil {
+		return hold, err
+	}
+
+	hold.Status = status
+	hold.UpdatedAt = time.Now()
+
+	_, err = tx.NewUpdate().
+		Model(&hold).
+		WherePK().
+		Column("status", "updated_at").
+		Exec(ctx)
+
+	return hold, err
+}
+
+func (s *Store) reconcileAccounts(ctx context.Context, tx bun.Tx, repair bool) ([]models.AccountDrift, error) {
+	var accounts []models.Account
+
+	q := tx.NewSelect().Model(&accounts).Order("created_at ASC")
+	if repair {
+		q = q.For("UPDATE")
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	balances, held, err := s.accountBalances(ctx, tx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []models.Order
+	err = tx.NewSelect().
+		Model(&orders).
+		Where("status = ?", models.OrderStatusProcessed).
+		Where("accrual > 0").
+		Where("NOT EXISTS (SELECT 1 FROM transactions t WHERE t.order_number = ?TableAlias.number AND t.direction = ?)", models.TxDirectionAccrual).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(map[string][]models.Order)
+	for _, o := range orders {
+		missing[o.AccountID] = append(missing[o.AccountID], o)
+	}
+
+	drifts := make([]models.AccountDrift, 0)
+	for _, acc := range accounts {
+		d := ledger.Reconcile(acc, balances[acc.ID], held[acc.ID], missing[acc.ID])
+		if !d.HasDrift() {
+			continue
+		}
+
+		if repair {
+			if _, d, err = s.repairAccount(ctx, tx, acc, d); err != nil {
+				return nil, err
+			}
+			d.Repaired = true
+		}
+
+		drifts = append(drifts, d)
+	}
+
+	return drifts, nil
+}
+
+// repairAccount credits missing accruals of the account, resets cached totals to the values derived
+// from the ledger and posts the difference of the balance as a correction adjustment.
+func (s *Store) repairAccount(ctx context.Context, tx bun.Tx, acc models.Account, d models.AccountDrift) (models.Account, models.AccountDrift, error) {
+	var err error
+
+	if len(d.MissingAccruals) > 0 {
+		for _, o := range d.MissingAccruals {
+			if acc, err = s.creditMissingAccrual(ctx, tx, acc, o); err != nil {
+				return acc, d, err
+			}
+		}
+
+		balances, held, err := s.accountBalances(ctx, tx, acc.ID)
+		if err != nil {
+			return acc, d, err
+		}
+		repaired := ledger.Reconcile(acc, balances[acc.ID], held[acc.ID], nil)
+		d.ExpectedPointsTotal = repaired.ExpectedPointsTotal
+		d.ExpectedWithdrawnTotal = repaired.ExpectedWithdrawnTotal
+		d.DebtTotal = repaired.DebtTotal
+	}
+
+	acc, adjustment := ledger.Correction(acc, d)
+	d.Adjustment = adjustment.Amount
+
+	if adjustment.Amount != 0 {
+		acc, err = s.post(ctx, tx, acc, models.Transaction{
+			ID:        uuid.NewString(),
+			AccountID: acc.ID,
+			Amount:    adjustment.Amount,
+			Direction: models.TxDirectionAdjustment,
+			Reason:    adjustment.Reason,
+			Comment:   adjustment.Comment,
+			Operator:  adjustment.Operator,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		return acc, d, err
+	}
+
+	acc.UpdatedAt = time.Now()
+	_, err = tx.NewUpdate().
+		Model(&acc).
+		WherePK().
+		Column("current_points_total", "withdrawn_total", "held_total", "updated_at").
+		Exec(ctx)
+
+	return acc, d, err
+}
+
+// creditMissingAccrual credits the accrual of the processed order as is, because the tier and campaigns
+// in effect when the order was processed are not known anymore. The accrual settles the debt of the account
+// like accruals of processed orders do.
+func (s *Store) creditMissingAccrual(ctx context.Context, tx bun.Tx, acc models.Account, order models.Order) (models.Account, error) {
+	transaction := models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		Amount:      order.Accrual,
+		OrderNumber: order.Number,
+		Direction:   models.TxDirectionAccrual,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	acc, err := s.post(ctx, tx, acc, transaction)
+	if err != nil || acc.DebtTotal == 0 {
+		return acc, err
+	}
+
+	return s.settleDebt(ctx, tx, acc, transaction)
+}
+
+// accountBalances sums ledger entries of wallet and redemption ledger accounts and active holds by user account.
+// Empty accountID means all accounts.
+func (s *Store) accountBalances(ctx context.Context, tx bun.Tx, accountID string) (map[string]map[models.LedgerAccount]points.Points, map[string]points.Points, error) {
+	var sums []struct {
+		AccountID     string               `bun:"account_id"`
+		LedgerAccount models.LedgerAccount `bun:"ledger_account"`
+		Total         points.Points        `bun:"total"`
+	}
+	q := tx.NewSelect().
+		TableExpr("ledger_entries AS e").
+		Join("JOIN transactions AS t ON t.id = e.transaction_id").
+		ColumnExpr("t.account_id, e.ledger_account, SUM(e.amount) AS total").
+		Where("e.ledger_account IN (?)", bun.In([]models.LedgerAccount{models.LedgerAccountWallet, models.LedgerAccountRedemption})).
+		Group("t.account_id", "e.ledger_account")
+	if accountID != "" {
+		q = q.Where("t.account_id = ?", accountID)
+	}
+	if err := q.Scan(ctx, &sums); err != nil {
+		return nil, nil, err
+	}
+
+	balances := make(map[string]map[models.LedgerAccount]points.Points)
+	for _, sum := range sums {
+		if balances[sum.AccountID] == nil {
+			balances[sum.AccountID] = make(map[models.LedgerAccount]points.Points)
+		}
+		balances[sum.AccountID][sum.LedgerAccount] = sum.Total
+	}
+
+	var holds []struct {
+		AccountID string        `bun:"account_id"`
+		Total     points.Points `bun:"total"`
+	}
+	q = tx.NewSelect().
+		Model((*models.Hold)(nil)).
+		ColumnExpr("account_id, SUM(amount) AS total").
+		Where("status = ?", models.HoldStatusActive).
+		Group("account_id")
+	if accountID != "" {
+		q = q.Where("account_id = ?", accountID)
+	}
+	if err := q.Scan(ctx, &holds); err != nil {
+		return nil, nil, err
+	}
+
+	held := make(map[string]points.Points)
+	for _, h := range holds {
+		held[h.AccountID] = h.Total
+	}
+
+	return balances, held, nil
+}
+
+// post saves the transaction with its balanced ledger entries and updates cached totals
+// of the account. The account row must be locked by the caller.
+func (s *Store) post(ctx context.Context, tx bun.Tx, acc models.Account, transaction models.Transaction) (models.Account, error) {
+	entries, err := ledger.Entries(transaction)
+	if err != nil {
+		return acc, err
+	}
+
+	_, err = tx.NewInsert().
+		Model(&transaction).
+		Exec(ctx)
+	if err != nil {
+		return acc, &store.InsertError{Err: err}
+	}
+
+	_, err = tx.NewInsert().
+		Model(&entries).
+		Exec(ctx)
+	if err != nil {
+		return acc, err
+	}
+
+	acc = ledger.Apply(acc, transaction, entries)
+
+	if err = s.updateLots(ctx, tx, transaction, acc.CurrentPointsTotal); err != nil {
+		return acc, err
+	}
+	acc.UpdatedAt = time.Now()
+
+	_, err = tx.NewUpdate().
+		Model(&acc).
+		WherePK().
+		Column("current_points_total", "withdrawn_total", "held_total", "debt_total", "updated_at").
+		Returning("*").
+		Exec(ctx)
+
+	return acc, err
+}
+
+// updateLots creates a lot for points credited by the transaction or consumes lots
+// of the account in FIFO order for debited points. Transferred and refunded points keep
+// expiry dates of the lots the parent transaction consumed.
+func (s *Store) updateLots(ctx context.Context, tx bun.Tx, transaction models.Transaction, balance points.Points) error {
+	delta := ledger.Delta(transaction)
+	if delta > 0 && ledger.CarriesLots(transaction) {
+		return s.carryLots(ctx, tx, transaction, balance)
+	}
+	if delta > 0 {
+		lot := ledger.NewLot(transaction, balance, s.pointsLifetime)
+		_, err := tx.NewInsert().
+			Model(&lot).
+			Exec(ctx)
+		return err
+	}
+
+	var lots []models.PointsLot
+	err := tx.NewSelect().
+		Model(&lots).
+		Where("account_id = ?", transaction.AccountID).
+		Where("remaining > 0").
+		OrderExpr("expires_at ASC NULLS LAST, created_at ASC, id ASC").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed, _ := ledger.Consume(lots, -delta)
+	for _, lot := range changed {
+		lot.UpdatedAt = time.Now()
+		_, err = tx.NewUpdate().
+			Model(&lot).
+			WherePK().
+			Column("remaining", "updated_at").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	usages := ledger.NewUsages(transaction, lots, changed)
+	if len(usages) == 0 {
+		return nil
+	}
+	_, err = tx.NewInsert().
+		Model(&usages).
+		Exec(ctx)
+
+	return err
+}
+
+// carryLots creates lots for points credited by the transaction with expiry dates of the lots
+// consumed by its parent transaction.
+func (s *Store) carryLots(ctx context.Context, tx bun.Tx, transaction models.Transaction, balance points.Points) error {
+	var usages []models.PointsLotUsage
+	err := tx.NewSelect().
+		Model(&usages).
+		Where("transaction_id = ?", transaction.ParentID).
+		Where("carried < amount").
+		OrderExpr("expires_at ASC NULLS LAST, id ASC").
+		For("UPDATE").
+		Scan(ctx)
+	if err != nil {
+		return err
+	}
+
+	lots, changed := ledger.CarryLots(transaction, balance, s.pointsLifetime, usages)
+	_, err = tx.NewInsert().
+		Model(&lots).
+		Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range changed {
+		_, err = tx.NewUpdate().
+			Model(&usage).
+			WherePK().
+			Column("carried", "updated_at").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lotOrderNumber returns the number of the order which accrual created the lot.
+func lotOrderNumber(lot models.PointsLot) string {
+	if lot.Transaction == nil {
+		return ""
+	}
+
+	return lot.Transaction.OrderNumber
+}
+
+func applyTimeRange(q *bun.SelectQuery, from, to time.Time) *bun.SelectQuery {
+	if !from.IsZero() {
+		q = q.Where("?TableAlias.created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		q = q.Where("?TableAlias.created_at < ?", to)
+	}
+
+	return q
+}
+
+// applyPage adds keyset pagination by (created_at, id).
+func applyPage(q *bun.SelectQuery, page models.Page) *bun.SelectQuery {
+	if page.Desc() {
+		if page.After != nil {
+			q = q.Where("(?TableAlias.created_at, ?TableAlias.id) < (?, ?)", page.After.CreatedAt, page.After.ID)
+		}
+		q = q.Order("created_at DESC", "id DESC")
+	} else {
+		if page.After != nil {
+			q = q.Where("(?TableAlias.created_at, ?TableAlias.id) > (?, ?)", page.After.CreatedAt, page.After.ID)
+		}
+		q = q.Order("created_at ASC", "id ASC")
+	}
+
+	return q.Limit(page.Limit)
+}
+
 func (s *Store) bootstrap(ctx context.Context) error {
 	migrations, err := NewMigrations(s.conn)
 	if err != nil {
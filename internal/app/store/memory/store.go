@@ -0,0 +1,1835 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/ledger"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
+// Store is a thread-safe in-memory storage. It is intended for local runs and tests
+// where PostgreSQL is not available, data is lost when the process exits.
+type Store struct {
+	mu sync.RWMutex
+
+	users        map[string]models.User
+	accounts     map[string]models.Account
+	orders       map[string]models.Order
+	transactions []models.Transaction
+	entries      []models.LedgerEntry
+	lots         map[string]models.PointsLot
+	usages       map[string]models.PointsLotUsage
+	holds        map[string]models.Hold
+	tierChanges  []models.TierChange
+	referrals    map[string]models.Referral
+
+	promoCodes map[string]models.PromoCode
+	campaigns  map[string]models.Campaign
+	// promoRedemptions mirrors the unique redemption index of the database store.
+	promoRedemptions []models.PromoRedemption
+
+	// credited holds numbers of orders which accrual has been credited,
+	// it mirrors the unique accrual transaction index of the database store.
+	credited map[string]struct{}
+
+	idempotencyKeys map[string]models.IdempotencyKey
+
+	pointsLifetime  int
+	tierPolicy      models.TierPolicy
+	referralBonuses models.ReferralBonuses
+}
+
+// Option configures the storage.
+type Option func(*Store)
+
+// WithPointsLifetime sets the number of months after which credited points expire.
+// By default points never expire.
+func WithPointsLifetime(months int) Option {
+	return func(s *Store) {
+		s.pointsLifetime = months
+	}
+}
+
+// WithTierPolicy sets rules of membership tiers. By default every account is bronze
+// and accruals are credited as is.
+func WithTierPolicy(policy models.TierPolicy) Option {
+	return func(s *Store) {
+		s.tierPolicy = policy
+	}
+}
+
+// WithReferralBonuses sets points credited to the referrer and the referee for the first
+// processed order of the referee. By default no bonuses are credited.
+func WithReferralBonuses(bonuses models.ReferralBonuses) Option {
+	return func(s *Store) {
+		s.referralBonuses = bonuses
+	}
+}
+
+// New creates a new in-memory storage.
+func New(opts ...Option) *Store {
+	s := &Store{
+		users:        make(map[string]models.User),
+		accounts:     make(map[string]models.Account),
+		orders:       make(map[string]models.Order),
+		transactions: make([]models.Transaction, 0),
+		entries:      make([]models.LedgerEntry, 0),
+		lots:         make(map[string]models.PointsLot),
+		usages:       make(map[string]models.PointsLotUsage),
+		holds:        make(map[string]models.Hold),
+		referrals:    make(map[string]models.Referral),
+		promoCodes:   make(map[string]models.PromoCode),
+		campaigns:    make(map[string]models.Campaign),
+		credited:     make(map[string]struct{}),
+
+		idempotencyKeys: make(map[string]models.IdempotencyKey),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// CreateUser saves new user.
+func (s *Store) CreateUser(_ context.Context, user models.User) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; ok {
+		return models.User{}, integrityViolation("user with ID %s already exists", user.ID)
+	}
+	for _, u := range s.users {
+		if u.Login == user.Login {
+			return models.User{}, integrityViolation("user with login %s already exists", user.Login)
+		}
+		if user.ReferralCode != "" && u.ReferralCode == user.ReferralCode {
+			return models.User{}, fmt.Errorf("%w: %s", store.ErrReferralCodeTaken, user.ReferralCode)
+		}
+	}
+
+	s.users[user.ID] = user
+
+	return user, nil
+}
+
+// GetUserByLogin fetches user by login.
+func (s *Store) GetUserByLogin(_ context.Context, login string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+
+	return models.User{}, sql.ErrNoRows
+}
+
+// GetUserByID fetches user by ID.
+func (s *Store) GetUserByID(_ context.Context, id string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, sql.ErrNoRows
+	}
+
+	return u, nil
+}
+
+// GetUserByReferralCode fetches user by referral code.
+func (s *Store) GetUserByReferralCode(_ context.Context, code string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if code != "" && u.ReferralCode == code {
+			return u, nil
+		}
+	}
+
+	return models.User{}, sql.ErrNoRows
+}
+
+// CreateReferral links the referee to the referrer unless the referrer has already invited limit users,
+// zero limit means no limit. The referee can be referred only once.
+func (s *Store) CreateReferral(_ context.Context, referral models.Referral, limit int) (models.Referral, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if referral.ReferrerID == referral.RefereeID {
+		return referral, store.ErrSelfReferral
+	}
+	if _, ok := s.users[referral.ReferrerID]; !ok {
+		return referral, sql.ErrNoRows
+	}
+	if _, ok := s.users[referral.RefereeID]; !ok {
+		return referral, integrityViolation("user with ID %s does not exist", referral.RefereeID)
+	}
+
+	count := 0
+	for _, r := range s.referrals {
+		if r.RefereeID == referral.RefereeID {
+			return referral, integrityViolation("user %s has already been referred", referral.RefereeID)
+		}
+		if r.ReferrerID == referral.ReferrerID {
+			count++
+		}
+	}
+	if limit > 0 && count >= limit {
+		return referral, store.ErrReferralLimitReached
+	}
+
+	s.referrals[referral.ID] = referral
+
+	return referral, nil
+}
+
+// GetReferralSummary fetches referral code of the user with the users they have invited, the latest first.
+func (s *Store) GetReferralSummary(_ context.Context, userID string) (models.ReferralSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[userID]
+	if !ok {
+		return models.ReferralSummary{}, sql.ErrNoRows
+	}
+
+	summary := models.ReferralSummary{Code: user.ReferralCode, Referrals: []models.Referral{}}
+	for _, r := range s.referrals {
+		if r.ReferrerID != userID {
+			continue
+		}
+		r.Login = s.users[r.RefereeID].Login
+		summary.Referrals = append(summary.Referrals, r)
+		summary.Earned += r.ReferrerBonus
+	}
+	sort.Slice(summary.Referrals, func(i, j int) bool {
+		return summary.Referrals[i].CreatedAt.After(summary.Referrals[j].CreatedAt)
+	})
+
+	return summary, nil
+}
+
+// SetUserRole changes role of the user.
+func (s *Store) SetUserRole(_ context.Context, login string, role models.Role) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Login == login {
+			u.Role = role
+			u.UpdatedAt = time.Now()
+			s.users[u.ID] = u
+			return u, nil
+		}
+	}
+
+	return models.User{}, sql.ErrNoRows
+}
+
+// CreateAccount creates new account.
+func (s *Store) CreateAccount(_ context.Context, account models.Account) (models.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[account.ID]; ok {
+		return models.Account{}, integrityViolation("account with ID %s already exists", account.ID)
+	}
+	if _, ok := s.users[account.UserID]; !ok {
+		return models.Account{}, integrityViolation("user with ID %s does not exist", account.UserID)
+	}
+	for _, a := range s.accounts {
+		if a.UserID == account.UserID {
+			return models.Account{}, integrityViolation("account for user %s already exists", account.UserID)
+		}
+	}
+
+	if account.Tier == "" {
+		account.Tier = models.TierBronze
+	}
+	s.accounts[account.ID] = account
+
+	return account, nil
+}
+
+// GetAccountByUserID fetches user account by user ID.
+func (s *Store) GetAccountByUserID(_ context.Context, userID string) (models.Account, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	acc, ok := s.accountByUserID(userID)
+	if !ok {
+		return models.Account{}, sql.ErrNoRows
+	}
+
+	return acc, nil
+}
+
+// SetAccountFrozen freezes or unfreezes the account.
+func (s *Store) SetAccountFrozen(_ context.Context, accountID string, frozen bool) (models.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return acc, sql.ErrNoRows
+	}
+
+	if !frozen {
+		acc.FrozenAt = time.Time{}
+	} else if !acc.Frozen() {
+		acc.FrozenAt = time.Now()
+	}
+	acc.UpdatedAt = time.Now()
+	s.accounts[acc.ID] = acc
+
+	return acc, nil
+}
+
+// CreatePromoCode saves new promo code.
+func (s *Store) CreatePromoCode(_ context.Context, promo models.PromoCode) (models.PromoCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := promo.Validate(); err != nil {
+		return promo, err
+	}
+	if _, ok := s.promoCodes[promo.Code]; ok {
+		return promo, integrityViolation("promo code %s already exists", promo.Code)
+	}
+
+	s.promoCodes[promo.Code] = promo
+
+	return promo, nil
+}
+
+// ListPromoCodes fetches all promo codes, the latest first.
+func (s *Store) ListPromoCodes(_ context.Context) ([]models.PromoCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.PromoCode, 0, len(s.promoCodes))
+	for _, p := range s.promoCodes {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// DisablePromoCode forbids redeeming the promo code. Disabling an already disabled code
+// keeps the time it was disabled at.
+func (s *Store) DisablePromoCode(_ context.Context, code string) (models.PromoCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	promo, ok := s.promoCodes[code]
+	if !ok {
+		return promo, sql.ErrNoRows
+	}
+
+	if promo.DisabledAt == nil {
+		now := time.Now()
+		promo.DisabledAt = &now
+	}
+	promo.UpdatedAt = time.Now()
+	s.promoCodes[code] = promo
+
+	return promo, nil
+}
+
+// CreateCampaign saves new accrual campaign.
+func (s *Store) CreateCampaign(_ context.Context, campaign models.Campaign) (models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := campaign.Validate(); err != nil {
+		return campaign, err
+	}
+	if _, ok := s.campaigns[campaign.ID]; ok {
+		return campaign, integrityViolation("campaign %s already exists", campaign.ID)
+	}
+
+	s.campaigns[campaign.ID] = campaign
+
+	return campaign, nil
+}
+
+// ListCampaigns fetches all campaigns with the number of accruals they have been applied to
+// and the points they have added, the latest first.
+func (s *Store) ListCampaigns(_ context.Context) ([]models.CampaignStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.CampaignStats, 0, len(s.campaigns))
+	for _, c := range s.campaigns {
+		stats := models.CampaignStats{Campaign: c}
+		for _, t := range s.transactions {
+			if t.CampaignID == c.ID {
+				stats.Orders++
+				stats.Credited += t.CampaignBonus
+			}
+		}
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return result, nil
+}
+
+// DisableCampaign stops applying the campaign to accruals. Disabling an already disabled campaign
+// keeps the time it was disabled at.
+func (s *Store) DisableCampaign(_ context.Context, id string) (models.Campaign, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	campaign, ok := s.campaigns[id]
+	if !ok {
+		return campaign, sql.ErrNoRows
+	}
+
+	if campaign.DisabledAt == nil {
+		now := time.Now()
+		campaign.DisabledAt = &now
+	}
+	campaign.UpdatedAt = time.Now()
+	s.campaigns[id] = campaign
+
+	return campaign, nil
+}
+
+// RedeemPromoCode credits points of the promo code to the user account.
+func (s *Store) RedeemPromoCode(_ context.Context, userID string, code string) (models.PromoRedemption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var redemption models.PromoRedemption
+
+	promo, ok := s.promoCodes[code]
+	if !ok {
+		return redemption, sql.ErrNoRows
+	}
+
+	now := time.Now()
+	if !promo.Active(now) {
+		return redemption, fmt.Errorf("%w: %s", store.ErrPromoCodeInactive, code)
+	}
+	if promo.Redemptions >= promo.MaxRedemptions {
+		return redemption, fmt.Errorf("%w: %s", store.ErrPromoCodeExhausted, code)
+	}
+
+	acc, ok := s.accountByUserID(userID)
+	if !ok {
+		return redemption, sql.ErrNoRows
+	}
+
+	for _, r := range s.promoRedemptions {
+		if r.PromoCodeID == promo.ID && r.AccountID == acc.ID {
+			return redemption, fmt.Errorf("%w: %s", store.ErrPromoCodeRedeemed, code)
+		}
+	}
+
+	transaction := newTransaction(acc.ID, "", promo.Amount, models.TxDirectionPromo)
+	transaction.Comment = promo.Code
+	if _, err := s.post(acc, transaction); err != nil {
+		return redemption, err
+	}
+
+	redemption = models.PromoRedemption{
+		ID:            uuid.NewString(),
+		PromoCodeID:   promo.ID,
+		AccountID:     acc.ID,
+		TransactionID: transaction.ID,
+		Code:          promo.Code,
+		Amount:        promo.Amount,
+		CreatedAt:     now,
+	}
+	s.promoRedemptions = append(s.promoRedemptions, redemption)
+
+	promo.Redemptions++
+	promo.UpdatedAt = now
+	s.promoCodes[code] = promo
+
+	return redemption, nil
+}
+
+// RefreshTier evaluates the tier of the account, so the tier drops once accruals leave
+// the qualifying window, and returns the progress to the next tier.
+func (s *Store) RefreshTier(_ context.Context, accountID string) (models.TierStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accounts[accountID]
+	if !ok {
+		return models.TierStatus{}, sql.ErrNoRows
+	}
+
+	_, status := s.updateTier(acc)
+
+	return status, nil
+}
+
+// ListTierChanges fetches tier changes of the account, the latest first.
+func (s *Store) ListTierChanges(_ context.Context, accountID string) ([]models.TierChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []models.TierChange
+	for i := len(s.tierChanges) - 1; i >= 0; i-- {
+		if s.tierChanges[i].AccountID == accountID {
+			result = append(result, s.tierChanges[i])
+		}
+	}
+
+	return result, nil
+}
+
+// AdjustBalance credits or debits the account on behalf of an operator. Debits never exceed available balance.
+func (s *Store) AdjustBalance(_ context.Context, adjustment models.Adjustment) (models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction := newTransaction(adjustment.AccountID, "", adjustment.Amount, models.TxDirectionAdjustment)
+	transaction.Reason = adjustment.Reason
+	transaction.Comment = adjustment.Comment
+	transaction.Operator = adjustment.Operator
+
+	if err := adjustment.Validate(); err != nil {
+		return transaction, err
+	}
+
+	acc, ok := s.accounts[adjustment.AccountID]
+	if !ok {
+		return transaction, sql.ErrNoRows
+	}
+
+	if adjustment.Amount < 0 && acc.Available() < -adjustment.Amount {
+		return transaction, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.Available(),
+			WithdrawRequested: -adjustment.Amount,
+		}
+	}
+
+	if _, err := s.post(acc, transaction); err != nil {
+		return transaction, err
+	}
+
+	return transaction, nil
+}
+
+// CreateOrder saves new order.
+func (s *Store) CreateOrder(_ context.Context, order *models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.accounts[order.AccountID]; !ok {
+		return integrityViolation("account with ID %s does not exist", order.AccountID)
+	}
+	for _, o := range s.orders {
+		if o.ID == order.ID || o.Number == order.Number {
+			return integrityViolation("order %s already exists", order.Number)
+		}
+	}
+
+	if order.Status == "" {
+		order.Status = models.OrderStatusNew
+	}
+	if order.NextCheckAt.IsZero() {
+		order.NextCheckAt = order.CreatedAt
+	}
+
+	o := *order
+	o.Account = models.Account{}
+	s.orders[o.Number] = o
+
+	return nil
+}
+
+// GetOrderByNumber fetches order by its number.
+func (s *Store) GetOrderByNumber(_ context.Context, orderNumber string) (models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	o, ok := s.orders[orderNumber]
+	if !ok {
+		return models.Order{}, sql.ErrNoRows
+	}
+	o.Account = s.accounts[o.AccountID]
+
+	return o, nil
+}
+
+// ListOrders fetches a page of orders linked to the account.
+func (s *Store) ListOrders(_ context.Context, accountID string, filter models.OrderFilter) ([]models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Order, 0)
+	for _, o := range s.orders {
+		if o.AccountID != accountID || !inTimeRange(o.CreatedAt, filter.From, filter.To) || !afterCursor(filter.Page, o.CreatedAt, o.ID) {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !contains(filter.Statuses, o.Status) {
+			continue
+		}
+		result = append(result, o)
+	}
+
+	return paginate(result, filter.Page, func(o models.Order) (time.Time, string) { return o.CreatedAt, o.ID }), nil
+}
+
+// ListOrdersByStatus fetches orders in specified statuses.
+func (s *Store) ListOrdersByStatus(_ context.Context, statuses []models.OrderStatus, limit int) ([]models.Order, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Order, 0)
+	for _, o := range s.orders {
+		for _, status := range statuses {
+			if o.Status == status {
+				result = append(result, o)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return applyLimit(result, limit), nil
+}
+
+// ClaimDueOrders takes a lease on orders in specified statuses which are due to be checked at the moment,
+// the longest waiting first. The lease moves the next check of the orders by leaseTTL.
+func (s *Store) ClaimDueOrders(_ context.Context, statuses []models.OrderStatus, now time.Time, leaseTTL time.Duration, limit int) ([]models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]models.Order, 0)
+	for _, o := range s.orders {
+		if o.NextCheckAt.After(now) {
+			continue
+		}
+		for _, status := range statuses {
+			if o.Status == status {
+				result = append(result, o)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].NextCheckAt.Equal(result[j].NextCheckAt) {
+			return result[i].NextCheckAt.Before(result[j].NextCheckAt)
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	result = applyLimit(result, limit)
+	for i := range result {
+		result[i].NextCheckAt = now.Add(leaseTTL)
+		s.orders[result[i].Number] = result[i]
+	}
+
+	return result, nil
+}
+
+// ScheduleOrderCheck saves the number of attempts and the time of the next check of the order
+// unless its status has changed meanwhile.
+func (s *Store) ScheduleOrderCheck(_ context.Context, order models.Order) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.orderByID(order.ID)
+	if !ok || o.Status != order.Status {
+		return nil
+	}
+
+	o.Attempts = order.Attempts
+	o.NextCheckAt = order.NextCheckAt
+	s.orders[o.Number] = o
+
+	return nil
+}
+
+// UpdateOrder updates order status and accrual.
+func (s *Store) UpdateOrder(_ context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if checkOrder, err := s.updateOrder(order, prevStatus); err != nil {
+		return checkOrder, err
+	}
+
+	return order, nil
+}
+
+// ProcessOrder updates order status and accrual. If the order reaches PROCESSED status,
+// its accrual is credited to the account balance atomically.
+func (s *Store) ProcessOrder(_ context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credit := order.Status == models.OrderStatusProcessed && order.Accrual > 0
+	if credit {
+		if err := s.checkCredit(order); err != nil {
+			return order, err
+		}
+	}
+
+	checkOrder, err := s.updateOrder(order, prevStatus)
+	if err != nil {
+		return checkOrder, err
+	}
+
+	if credit {
+		if _, err = s.addBalance(order); err != nil {
+			s.orders[checkOrder.Number] = checkOrder
+			return order, err
+		}
+	}
+
+	if order.Status == models.OrderStatusProcessed {
+		if err = s.rewardReferral(order); err != nil {
+			return order, err
+		}
+	}
+
+	return order, nil
+}
+
+// ReturnOrder takes back accrual of the processed order which goods have been returned to the shop
+// and moves the order to RETURNED status.
+func (s *Store) ReturnOrder(_ context.Context, orderNumber string, policy models.ClawbackPolicy) (models.Clawback, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clawback := models.Clawback{OrderNumber: orderNumber}
+
+	order, ok := s.orders[orderNumber]
+	if !ok {
+		return clawback, sql.ErrNoRows
+	}
+	if order.Status != models.OrderStatusProcessed {
+		return clawback, fmt.Errorf("%w: order %s is %s", store.ErrOrderNotProcessed, orderNumber, order.Status)
+	}
+
+	for _, tx := range s.transactions {
+		if tx.OrderNumber != orderNumber || tx.Direction != models.TxDirectionAccrual {
+			continue
+		}
+
+		acc := s.accounts[order.AccountID]
+		clawback = ledger.Clawback(acc, orderNumber, tx.Amount, policy)
+		acc.DebtTotal += clawback.Debt
+		acc.UpdatedAt = time.Now()
+		s.accounts[acc.ID] = acc
+
+		if clawback.Clawed > 0 {
+			transaction := newTransaction(acc.ID, orderNumber, clawback.Clawed, models.TxDirectionClawback)
+			transaction.ParentID = tx.ID
+			if _, err := s.post(acc, transaction); err != nil {
+				return clawback, err
+			}
+		}
+		break
+	}
+
+	order.Status = models.OrderStatusReturned
+	order.UpdatedAt = time.Now()
+	s.orders[orderNumber] = order
+
+	// accrual of the returned order no longer qualifies for the tier
+	s.updateTier(s.accounts[order.AccountID])
+
+	return clawback, nil
+}
+
+// WithdrawBalance withdraws points from balance if there are enough points and daily and monthly caps
+// of rules are not exceeded. Repeated withdrawal for the same order and sum returns the account unchanged.
+func (s *Store) WithdrawBalance(_ context.Context, userID string, orderNumber string, sum points.Points, rules models.WithdrawalRules) (models.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accountByUserID(userID)
+	if !ok {
+		return acc, sql.ErrNoRows
+	}
+
+	for _, tx := range s.transactions {
+		if tx.OrderNumber != orderNumber || tx.Direction != models.TxDirectionWithdrawal {
+			continue
+		}
+		if tx.AccountID == acc.ID && tx.Amount == sum {
+			return acc, nil
+		}
+		return acc, integrityViolation("order %s has already been paid with points", orderNumber)
+	}
+
+	if hold, ok := s.orderHold(orderNumber); ok && hold.Status == models.HoldStatusActive {
+		return acc, integrityViolation("order %s is reserved by a hold", orderNumber)
+	}
+
+	if acc.Frozen() {
+		return acc, fmt.Errorf("%w: account %s", store.ErrAccountFrozen, acc.ID)
+	}
+
+	if err := s.checkWithdrawalCaps(acc.ID, sum, rules); err != nil {
+		return acc, err
+	}
+
+	if acc.Available() < sum {
+		return acc, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.Available(),
+			WithdrawRequested: sum,
+		}
+	}
+
+	return s.post(acc, newTransaction(acc.ID, orderNumber, sum, models.TxDirectionWithdrawal))
+}
+
+// checkWithdrawalCaps ensures that the withdrawal does not exceed daily and monthly caps of rules.
+func (s *Store) checkWithdrawalCaps(accountID string, sum points.Points, rules models.WithdrawalRules) error {
+	now := time.Now()
+	if err := s.checkWithdrawalLimit(accountID, sum, models.WithdrawalViolationDailyLimit, rules.DailyLimit, now, now.Add(-24*time.Hour)); err != nil {
+		return err
+	}
+
+	return s.checkWithdrawalLimit(accountID, sum, models.WithdrawalViolationMonthlyLimit, rules.MonthlyLimit, now, now.AddDate(0, -1, 0))
+}
+
+// checkWithdrawalLimit ensures that the withdrawal together with withdrawals and active holds made
+// since the time does not exceed the limit unless it is zero. Captured holds are counted as withdrawals.
+func (s *Store) checkWithdrawalLimit(accountID string, sum points.Points, violation models.WithdrawalViolation, limit points.Points, now, since time.Time) error {
+	if limit == 0 {
+		return nil
+	}
+
+	var withdrawn points.Points
+	for _, tx := range s.transactions {
+		if tx.AccountID == accountID && tx.Direction == models.TxDirectionWithdrawal && tx.CreatedAt.After(since) {
+			withdrawn += tx.Amount
+		}
+	}
+	for _, hold := range s.holds {
+		if hold.AccountID == accountID && hold.Active(now) && hold.CreatedAt.After(since) {
+			withdrawn += hold.Amount
+		}
+	}
+	if withdrawn+sum <= limit {
+		return nil
+	}
+
+	return &store.WithdrawalLimitError{
+		Err:       fmt.Errorf("withdrawal of %s exceeds %s of %s", sum, violation, limit),
+		Violation: violation,
+		Limit:     limit,
+		Withdrawn: withdrawn,
+		Requested: sum,
+	}
+}
+
+// RefundWithdrawal returns points withdrawn for the order back to the account. Refunds never exceed
+// the withdrawn amount in total, zero sum refunds the whole part which has not been refunded yet.
+func (s *Store) RefundWithdrawal(_ context.Context, orderNumber string, sum points.Points) (models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var (
+		withdrawal models.Transaction
+		found      bool
+		refunded   points.Points
+	)
+	for _, tx := range s.transactions {
+		if tx.OrderNumber == orderNumber && tx.Direction == models.TxDirectionWithdrawal {
+			withdrawal, found = tx, true
+		}
+	}
+	if !found {
+		return models.Transaction{}, sql.ErrNoRows
+	}
+	for _, tx := range s.transactions {
+		if tx.ParentID == withdrawal.ID && tx.Direction == models.TxDirectionRefund {
+			refunded += tx.Amount
+		}
+	}
+
+	sum, err := ledger.RefundAmount(withdrawal, refunded, sum)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	refund := newTransaction(withdrawal.AccountID, orderNumber, sum, models.TxDirectionRefund)
+	refund.ParentID = withdrawal.ID
+	if _, err = s.post(s.accounts[withdrawal.AccountID], refund); err != nil {
+		return refund, err
+	}
+
+	return refund, nil
+}
+
+// TransferPoints moves points from the user account to the account of the recipient. Transfers of the user
+// within the last 24 hours never exceed dailyLimit unless it is zero.
+func (s *Store) TransferPoints(_ context.Context, userID string, recipientLogin string, sum points.Points, dailyLimit points.Points) (models.Transfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer := models.Transfer{Recipient: recipientLogin, Amount: sum}
+
+	var recipient models.Account
+	for _, u := range s.users {
+		if u.Login == recipientLogin {
+			recipient, _ = s.accountByUserID(u.ID)
+		}
+	}
+	if recipient.ID == "" {
+		return transfer, fmt.Errorf("%w: %s", store.ErrRecipientNotFound, recipientLogin)
+	}
+	if recipient.UserID == userID {
+		return transfer, store.ErrSelfTransfer
+	}
+
+	sender, ok := s.accountByUserID(userID)
+	if !ok {
+		return transfer, sql.ErrNoRows
+	}
+	if sender.Frozen() {
+		return transfer, fmt.Errorf("%w: account %s", store.ErrAccountFrozen, sender.ID)
+	}
+
+	if dailyLimit > 0 {
+		var transferred points.Points
+		since := time.Now().Add(-24 * time.Hour)
+		for _, tx := range s.transactions {
+			if tx.AccountID == sender.ID && tx.Direction == models.TxDirectionTransferOut && tx.CreatedAt.After(since) {
+				transferred += tx.Amount
+			}
+		}
+
+		if err := ledger.CheckTransferLimit(transferred, sum, dailyLimit); err != nil {
+			return transfer, err
+		}
+	}
+
+	if sender.Available() < sum {
+		return transfer, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           sender.Available(),
+			WithdrawRequested: sum,
+		}
+	}
+
+	out := newTransaction(sender.ID, "", sum, models.TxDirectionTransferOut)
+	in := newTransaction(recipient.ID, "", sum, models.TxDirectionTransferIn)
+	in.ParentID = out.ID
+
+	if _, err := s.post(sender, out); err != nil {
+		return transfer, err
+	}
+	if _, err := s.post(recipient, in); err != nil {
+		return transfer, err
+	}
+
+	transfer.ID = out.ID
+	transfer.CreatedAt = out.CreatedAt
+
+	return transfer, nil
+}
+
+// CreateHold reserves points of the user account for the order until expiresAt if daily and monthly caps
+// of rules are not exceeded. Repeated request for the same order and sum returns the existing hold.
+func (s *Store) CreateHold(_ context.Context, userID string, orderNumber string, sum points.Points, expiresAt time.Time, rules models.WithdrawalRules) (models.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, ok := s.accountByUserID(userID)
+	if !ok {
+		return models.Hold{}, sql.ErrNoRows
+	}
+
+	if hold, ok := s.orderHold(orderNumber); ok {
+		if hold.AccountID == acc.ID && hold.Amount == sum {
+			return hold, nil
+		}
+		return hold, integrityViolation("order %s is already reserved", orderNumber)
+	}
+
+	for _, tx := range s.transactions {
+		if tx.OrderNumber == orderNumber && tx.Direction == models.TxDirectionWithdrawal {
+			return models.Hold{}, integrityViolation("order %s has already been paid with points", orderNumber)
+		}
+	}
+
+	if acc.Frozen() {
+		return models.Hold{}, fmt.Errorf("%w: account %s", store.ErrAccountFrozen, acc.ID)
+	}
+
+	if err := s.checkWithdrawalCaps(acc.ID, sum, rules); err != nil {
+		return models.Hold{}, err
+	}
+
+	if acc.Available() < sum {
+		return models.Hold{}, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.Available(),
+			WithdrawRequested: sum,
+		}
+	}
+
+	hold := models.Hold{
+		ID:          uuid.NewString(),
+		AccountID:   acc.ID,
+		OrderNumber: orderNumber,
+		Amount:      sum,
+		Status:      models.HoldStatusActive,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	s.holds[hold.ID] = hold
+
+	acc.HeldTotal += sum
+	acc.UpdatedAt = time.Now()
+	s.accounts[acc.ID] = acc
+
+	return hold, nil
+}
+
+// CaptureHold withdraws points reserved by the active hold. Capturing a captured hold is a no-op.
+func (s *Store) CaptureHold(_ context.Context, userID string, holdID string) (models.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, hold, err := s.userHold(userID, holdID)
+	if err != nil {
+		return hold, err
+	}
+	if hold.Status == models.HoldStatusCaptured {
+		return hold, nil
+	}
+	if !hold.Active(time.Now()) {
+		return hold, store.ErrHoldNotActive
+	}
+
+	if acc.CurrentPointsTotal < hold.Amount {
+		return hold, &store.NotEnoughBalanceError{
+			Err:               errors.New("not enough balance"),
+			Balance:           acc.CurrentPointsTotal,
+			WithdrawRequested: hold.Amount,
+		}
+	}
+
+	transaction := newTransaction(acc.ID, hold.OrderNumber, hold.Amount, models.TxDirectionWithdrawal)
+	acc.HeldTotal -= hold.Amount
+	if _, err = s.post(acc, transaction); err != nil {
+		return hold, err
+	}
+
+	hold.Status = models.HoldStatusCaptured
+	hold.TransactionID = transaction.ID
+	hold.UpdatedAt = time.Now()
+	s.holds[hold.ID] = hold
+
+	return hold, nil
+}
+
+// VoidHold releases points reserved by the active hold. Voiding a voided hold is a no-op.
+func (s *Store) VoidHold(_ context.Context, userID string, holdID string) (models.Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	acc, hold, err := s.userHold(userID, holdID)
+	if err != nil {
+		return hold, err
+	}
+	if hold.Status == models.HoldStatusVoided {
+		return hold, nil
+	}
+	if !hold.Active(time.Now()) {
+		return hold, store.ErrHoldNotActive
+	}
+
+	return s.releaseHold(acc, hold, models.HoldStatusVoided), nil
+}
+
+// ExpireHolds releases points reserved by active holds which have expired.
+func (s *Store) ExpireHolds(_ context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired int64
+	for _, hold := range s.holds {
+		if hold.Status != models.HoldStatusActive || hold.Active(now) {
+			continue
+		}
+		s.releaseHold(s.accounts[hold.AccountID], hold, models.HoldStatusExpired)
+		expired++
+	}
+
+	return expired, nil
+}
+
+// ListTransactions fetches a page of account transactions.
+func (s *Store) ListTransactions(_ context.Context, accountID string, filter models.TransactionFilter) ([]models.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.Transaction, 0)
+	for _, tx := range s.transactions {
+		if tx.AccountID != accountID || !inTimeRange(tx.CreatedAt, filter.From, filter.To) || !afterCursor(filter.Page, tx.CreatedAt, tx.ID) {
+			continue
+		}
+		if len(filter.Directions) > 0 && !contains(filter.Directions, tx.Direction) {
+			continue
+		}
+		result = append(result, tx)
+	}
+
+	return paginate(result, filter.Page, func(tx models.Transaction) (time.Time, string) { return tx.CreatedAt, tx.ID }), nil
+}
+
+// ListStatement fetches transactions of the account with wallet balance after each of them.
+func (s *Store) ListStatement(_ context.Context, accountID string, filter models.TransactionFilter) ([]models.StatementEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := make([]models.Transaction, 0)
+	for _, tx := range s.transactions {
+		if tx.AccountID == accountID {
+			history = append(history, tx)
+		}
+	}
+	history = paginate(history, models.Page{Order: models.SortOrderAsc}, func(tx models.Transaction) (time.Time, string) { return tx.CreatedAt, tx.ID })
+
+	var balance points.Points
+	result := make([]models.StatementEntry, 0)
+	for _, tx := range history {
+		balance += ledger.Delta(tx)
+
+		if !inTimeRange(tx.CreatedAt, filter.From, filter.To) || !afterCursor(filter.Page, tx.CreatedAt, tx.ID) {
+			continue
+		}
+		if len(filter.Directions) > 0 && !contains(filter.Directions, tx.Direction) {
+			continue
+		}
+		result = append(result, models.StatementEntry{
+			ID:           tx.ID,
+			Direction:    tx.Direction,
+			OrderNumber:  tx.OrderNumber,
+			Amount:       tx.Amount,
+			BalanceAfter: balance,
+			Reason:       tx.Reason,
+			Comment:      tx.Comment,
+			CreatedAt:    tx.CreatedAt,
+		})
+	}
+
+	return paginate(result, filter.Page, func(e models.StatementEntry) (time.Time, string) { return e.CreatedAt, e.ID }), nil
+}
+
+// ListExpiringLots fetches lots of the account with remaining points which expire before the specified time.
+func (s *Store) ListExpiringLots(_ context.Context, accountID string, before time.Time) ([]models.PointsLot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.PointsLot, 0)
+	for _, lot := range s.accountLots(accountID) {
+		if lot.Expires() && lot.ExpiresAt.Before(before) {
+			result = append(result, lot)
+		}
+	}
+
+	return result, nil
+}
+
+// ExpirePoints posts expiry transactions for remaining points of due lots.
+func (s *Store) ExpirePoints(_ context.Context, now time.Time) ([]models.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]models.PointsLot, 0)
+	for _, lot := range s.lots {
+		if lot.Due(now) {
+			due = append(due, lot)
+		}
+	}
+	ledger.SortLots(due)
+
+	result := make([]models.Transaction, 0, len(due))
+	for _, lot := range due {
+		if lot = s.lots[lot.ID]; !lot.Due(now) {
+			continue
+		}
+
+		var orderNumber string
+		if tx, ok := s.transactionByID(lot.TransactionID); ok {
+			orderNumber = tx.OrderNumber
+		}
+
+		transaction := newTransaction(lot.AccountID, orderNumber, lot.Remaining, models.TxDirectionExpiry)
+		if _, err := s.post(s.accounts[lot.AccountID], transaction); err != nil {
+			return result, err
+		}
+		result = append(result, transaction)
+	}
+
+	return result, nil
+}
+
+// CreateIdempotencyKey reserves idempotency key for the user.
+func (s *Store) CreateIdempotencyKey(_ context.Context, key models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := idempotencyKeyID(key.UserID, key.Key)
+	if _, ok := s.idempotencyKeys[id]; ok {
+		return integrityViolation("idempotency key %s already exists", key.Key)
+	}
+	s.idempotencyKeys[id] = key
+
+	return nil
+}
+
+// GetIdempotencyKey fetches idempotency key of the user.
+func (s *Store) GetIdempotencyKey(_ context.Context, userID string, key string) (models.IdempotencyKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.idempotencyKeys[idempotencyKeyID(userID, key)]
+	if !ok {
+		return k, sql.ErrNoRows
+	}
+
+	return k, nil
+}
+
+// CompleteIdempotencyKey saves response for the reserved idempotency key.
+func (s *Store) CompleteIdempotencyKey(_ context.Context, key models.IdempotencyKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := idempotencyKeyID(key.UserID, key.Key)
+	k, ok := s.idempotencyKeys[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	k.StatusCode = key.StatusCode
+	k.ContentType = key.ContentType
+	k.ResponseBody = key.ResponseBody
+	s.idempotencyKeys[id] = k
+
+	return nil
+}
+
+// DeleteIdempotencyKey releases idempotency key so that the request can be retried.
+func (s *Store) DeleteIdempotencyKey(_ context.Context, userID string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.idempotencyKeys, idempotencyKeyID(userID, key))
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys deletes idempotency keys expired by now.
+func (s *Store) DeleteExpiredIdempotencyKeys(_ context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, k := range s.idempotencyKeys {
+		if !k.ExpiresAt.After(now) {
+			delete(s.idempotencyKeys, id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// ListLedgerEntries fetches wallet ledger entries of the account, newest first.
+func (s *Store) ListLedgerEntries(_ context.Context, accountID string, limit int) ([]models.LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]models.LedgerEntry, 0)
+	for _, e := range s.entries {
+		if e.LedgerAccount == models.LedgerAccountWallet && e.AccountID == accountID {
+			result = append(result, e)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+
+	return applyLimit(result, limit), nil
+}
+
+// GetLedgerBalances derives balances of all ledger accounts from their entries.
+func (s *Store) GetLedgerBalances(_ context.Context) ([]models.LedgerBalance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	type key struct {
+		account   models.LedgerAccount
+		accountID string
+	}
+
+	balances := make(map[key]points.Points)
+	for _, e := range s.entries {
+		balances[key{e.LedgerAccount, e.AccountID}] += e.Amount
+	}
+
+	result := make([]models.LedgerBalance, 0, len(balances))
+	for k, b := range balances {
+		result = append(result, models.LedgerBalance{LedgerAccount: k.account, AccountID: k.accountID, Balance: b})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].LedgerAccount != result[j].LedgerAccount {
+			return result[i].LedgerAccount < result[j].LedgerAccount
+		}
+		return result[i].AccountID < result[j].AccountID
+	})
+
+	return result, nil
+}
+
+// ReconcileAccounts compares cached totals of every account with balances derived from its ledger entries,
+// active holds and processed orders. If repair is set, missing accruals are credited like processed orders
+// and cached totals are reset to derived values.
+func (s *Store) ReconcileAccounts(_ context.Context, repair bool) ([]models.AccountDrift, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balances, held := s.accountBalances()
+
+	missing := make(map[string][]models.Order)
+	for _, o := range s.orders {
+		if o.Status != models.OrderStatusProcessed || o.Accrual <= 0 {
+			continue
+		}
+		if _, ok := s.credited[o.Number]; !ok {
+			missing[o.AccountID] = append(missing[o.AccountID], o)
+		}
+	}
+
+	accounts := make([]models.Account, 0, len(s.accounts))
+	for _, acc := range s.accounts {
+		accounts = append(accounts, acc)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].CreatedAt.Before(accounts[j].CreatedAt)
+	})
+
+	drifts := make([]models.AccountDrift, 0)
+	for _, acc := range accounts {
+		sort.Slice(missing[acc.ID], func(i, j int) bool {
+			return missing[acc.ID][i].CreatedAt.Before(missing[acc.ID][j].CreatedAt)
+		})
+
+		d := ledger.Reconcile(acc, balances[acc.ID], held[acc.ID], missing[acc.ID])
+		if !d.HasDrift() {
+			continue
+		}
+
+		if repair {
+			var err error
+			if d, err = s.repairAccount(acc, d); err != nil {
+				return nil, err
+			}
+			d.Repaired = true
+		}
+
+		drifts = append(drifts, d)
+	}
+
+	return drifts, nil
+}
+
+// repairAccount credits missing accruals of the account, resets cached totals to the values derived
+// from the ledger and posts the difference of the balance as a correction adjustment.
+func (s *Store) repairAccount(acc models.Account, d models.AccountDrift) (models.AccountDrift, error) {
+	if len(d.MissingAccruals) > 0 {
+		for _, o := range d.MissingAccruals {
+			if _, err := s.creditMissingAccrual(o); err != nil {
+				return d, err
+			}
+		}
+
+		balances, held := s.accountBalances()
+		repaired := ledger.Reconcile(s.accounts[acc.ID], balances[acc.ID], held[acc.ID], nil)
+		d.ExpectedPointsTotal = repaired.ExpectedPointsTotal
+		d.ExpectedWithdrawnTotal = repaired.ExpectedWithdrawnTotal
+		d.DebtTotal = repaired.DebtTotal
+	}
+
+	acc, adjustment := ledger.Correction(s.accounts[acc.ID], d)
+	d.Adjustment = adjustment.Amount
+
+	if adjustment.Amount != 0 {
+		transaction := newTransaction(acc.ID, "", adjustment.Amount, models.TxDirectionAdjustment)
+		transaction.Reason = adjustment.Reason
+		transaction.Comment = adjustment.Comment
+		transaction.Operator = adjustment.Operator
+
+		_, err := s.post(acc, transaction)
+		return d, err
+	}
+
+	acc.UpdatedAt = time.Now()
+	s.accounts[acc.ID] = acc
+
+	return d, nil
+}
+
+// creditMissingAccrual credits the accrual of the processed order as is, because the tier and campaigns
+// in effect when the order was processed are not known anymore. The accrual settles the debt of the account
+// like accruals of processed orders do.
+func (s *Store) creditMissingAccrual(order models.Order) (models.Account, error) {
+	transaction := newTransaction(order.AccountID, order.Number, order.Accrual, models.TxDirectionAccrual)
+
+	acc, err := s.post(s.accounts[order.AccountID], transaction)
+	if err != nil {
+		return acc, err
+	}
+
+	s.credited[order.Number] = struct{}{}
+
+	if acc.DebtTotal == 0 {
+		return acc, nil
+	}
+
+	settled := min(acc.DebtTotal, transaction.Amount)
+	acc.DebtTotal -= settled
+
+	return s.post(acc, newTransaction(acc.ID, order.Number, settled, models.TxDirectionClawback))
+}
+
+// accountBalances sums ledger entries of wallet and redemption ledger accounts and active holds by user account.
+func (s *Store) accountBalances() (map[string]map[models.LedgerAccount]points.Points, map[string]points.Points) {
+	owners := make(map[string]string, len(s.transactions))
+	for _, tx := range s.transactions {
+		owners[tx.ID] = tx.AccountID
+	}
+
+	balances := make(map[string]map[models.LedgerAccount]points.Points)
+	for _, e := range s.entries {
+		if e.LedgerAccount != models.LedgerAccountWallet && e.LedgerAccount != models.LedgerAccountRedemption {
+			continue
+		}
+
+		accountID := owners[e.TransactionID]
+		if balances[accountID] == nil {
+			balances[accountID] = make(map[models.LedgerAccount]points.Points)
+		}
+		balances[accountID][e.LedgerAccount] += e.Amount
+	}
+
+	held := make(map[string]points.Points)
+	for _, hold := range s.holds {
+		if hold.Status == models.HoldStatusActive {
+			held[hold.AccountID] += hold.Amount
+		}
+	}
+
+	return balances, held
+}
+
+// AddBalance adds accrued points for order to account balance.
+func (s *Store) AddBalance(_ context.Context, order models.Order) (models.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkCredit(order); err != nil {
+		return models.Account{}, err
+	}
+
+	return s.addBalance(order)
+}
+
+func (s *Store) updateOrder(order models.Order, prevStatus models.OrderStatus) (models.Order, error) {
+	checkOrder, ok := s.orderByID(order.ID)
+	if !ok {
+		return checkOrder, sql.ErrNoRows
+	}
+	if checkOrder.Status != prevStatus {
+		return checkOrder, errors.New("sql: update conflict")
+	}
+
+	updated := checkOrder
+	updated.Status = order.Status
+	updated.Accrual = order.Accrual
+	updated.UpdatedAt = order.UpdatedAt
+	if !order.NextCheckAt.IsZero() {
+		updated.Attempts = order.Attempts
+		updated.NextCheckAt = order.NextCheckAt
+	}
+	s.orders[updated.Number] = updated
+
+	return checkOrder, nil
+}
+
+func (s *Store) checkCredit(order models.Order) error {
+	if _, ok := s.accounts[order.AccountID]; !ok {
+		return sql.ErrNoRows
+	}
+	if _, ok := s.credited[order.Number]; ok {
+		return integrityViolation("accrual for order %s has already been credited", order.Number)
+	}
+
+	return nil
+}
+
+func (s *Store) addBalance(order models.Order) (models.Account, error) {
+	acc, status := s.updateTier(s.accounts[order.AccountID])
+
+	campaigns := make([]models.Campaign, 0, len(s.campaigns))
+	for _, c := range s.campaigns {
+		campaigns = append(campaigns, c)
+	}
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedAt.Before(campaigns[j].CreatedAt)
+	})
+
+	amount := ledger.Multiply(order.Accrual, status.Multiplier)
+	campaign, bonus := ledger.ApplyCampaign(campaigns, acc, amount, time.Now())
+
+	transaction := newTransaction(order.AccountID, order.Number, amount+bonus, models.TxDirectionAccrual)
+	transaction.Tier = status.Tier
+	transaction.CampaignID = campaign.ID
+	transaction.CampaignBonus = bonus
+
+	acc, err := s.post(acc, transaction)
+	if err != nil {
+		return acc, err
+	}
+
+	s.credited[order.Number] = struct{}{}
+
+	if acc.DebtTotal == 0 {
+		return acc, nil
+	}
+
+	settled := min(acc.DebtTotal, transaction.Amount)
+	acc.DebtTotal -= settled
+
+	return s.post(acc, newTransaction(acc.ID, order.Number, settled, models.TxDirectionClawback))
+}
+
+// rewardReferral credits referral bonuses if the processed order is the first processed order of the referee.
+func (s *Store) rewardReferral(order models.Order) error {
+	refereeID := s.accounts[order.AccountID].UserID
+
+	for _, referral := range s.referrals {
+		if referral.RefereeID != refereeID || referral.Status != models.ReferralStatusPending {
+			continue
+		}
+
+		if err := s.creditReferralBonus(referral.RefereeID, order.Number, s.referralBonuses.Referee); err != nil {
+			return err
+		}
+		// the order of the referee is not shown to the referrer
+		if err := s.creditReferralBonus(referral.ReferrerID, "", s.referralBonuses.Referrer); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		referral.Status = models.ReferralStatusRewarded
+		referral.OrderNumber = order.Number
+		referral.ReferrerBonus = s.referralBonuses.Referrer
+		referral.RefereeBonus = s.referralBonuses.Referee
+		referral.RewardedAt = &now
+		s.referrals[referral.ID] = referral
+	}
+
+	return nil
+}
+
+// creditReferralBonus posts referral transaction to the account of the user.
+func (s *Store) creditReferralBonus(userID string, orderNumber string, amount points.Points) error {
+	if amount == 0 {
+		return nil
+	}
+
+	acc, ok := s.accountByUserID(userID)
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	_, err := s.post(acc, newTransaction(acc.ID, orderNumber, amount, models.TxDirectionReferral))
+
+	return err
+}
+
+// updateTier evaluates the tier of the account from accruals of its processed orders
+// and records the change if the account has moved to another tier.
+func (s *Store) updateTier(acc models.Account) (models.Account, models.TierStatus) {
+	now := time.Now()
+	since := ledger.QualifyingSince(s.tierPolicy, now)
+
+	var qualifying points.Points
+	for _, o := range s.orders {
+		if o.AccountID == acc.ID && o.Status == models.OrderStatusProcessed && !o.UpdatedAt.Before(since) {
+			qualifying += o.Accrual
+		}
+	}
+
+	status := ledger.EvaluateTier(s.tierPolicy, qualifying)
+	if status.Tier == acc.Tier {
+		return acc, status
+	}
+
+	s.tierChanges = append(s.tierChanges, models.TierChange{
+		ID:         uuid.NewString(),
+		AccountID:  acc.ID,
+		FromTier:   acc.Tier,
+		ToTier:     status.Tier,
+		Qualifying: qualifying,
+		CreatedAt:  now,
+	})
+
+	acc.Tier = status.Tier
+	acc.UpdatedAt = now
+	s.accounts[acc.ID] = acc
+
+	return acc, status
+}
+
+// post saves the transaction with its balanced ledger entries and updates cached totals of the account.
+func (s *Store) post(acc models.Account, transaction models.Transaction) (models.Account, error) {
+	entries, err := ledger.Entries(transaction)
+	if err != nil {
+		return acc, err
+	}
+
+	s.transactions = append(s.transactions, transaction)
+	s.entries = append(s.entries, entries...)
+
+	acc = ledger.Apply(acc, transaction, entries)
+	s.updateLots(transaction, acc.CurrentPointsTotal)
+	acc.UpdatedAt = time.Now()
+	s.accounts[acc.ID] = acc
+
+	return acc, nil
+}
+
+// updateLots creates a lot for points credited by the transaction or consumes lots
+// of the account in FIFO order for debited points.
+func (s *Store) updateLots(transaction models.Transaction, balance points.Points) {
+	delta := ledger.Delta(transaction)
+	if delta > 0 && ledger.CarriesLots(transaction) {
+		lots, changed := ledger.CarryLots(transaction, balance, s.pointsLifetime, s.carriableUsages(transaction.ParentID))
+		for _, lot := range lots {
+			s.lots[lot.ID] = lot
+		}
+		for _, usage := range changed {
+			s.usages[usage.ID] = usage
+		}
+		return
+	}
+	if delta > 0 {
+		lot := ledger.NewLot(transaction, balance, s.pointsLifetime)
+		s.lots[lot.ID] = lot
+		return
+	}
+
+	lots := s.accountLots(transaction.AccountID)
+	changed, _ := ledger.Consume(lots, -delta)
+	for _, lot := range changed {
+		lot.UpdatedAt = time.Now()
+		s.lots[lot.ID] = lot
+	}
+	for _, usage := range ledger.NewUsages(transaction, lots, changed) {
+		s.usages[usage.ID] = usage
+	}
+}
+
+// carriableUsages returns usages of lots by the transaction which points have not been carried yet,
+// the earliest expiring first.
+func (s *Store) carriableUsages(transactionID string) []models.PointsLotUsage {
+	usages := make([]models.PointsLotUsage, 0)
+	for _, usage := range s.usages {
+		if usage.TransactionID == transactionID && usage.Carried < usage.Amount {
+			usages = append(usages, usage)
+		}
+	}
+	ledger.SortUsages(usages)
+
+	return usages
+}
+
+// accountLots returns lots of the account with remaining points in the order of consumption.
+func (s *Store) accountLots(accountID string) []models.PointsLot {
+	lots := make([]models.PointsLot, 0)
+	for _, lot := range s.lots {
+		if lot.AccountID == accountID && lot.Remaining > 0 {
+			lots = append(lots, lot)
+		}
+	}
+	ledger.SortLots(lots)
+
+	return lots
+}
+
+func (s *Store) transactionByID(id string) (models.Transaction, bool) {
+	for _, tx := range s.transactions {
+		if tx.ID == id {
+			return tx, true
+		}
+	}
+
+	return models.Transaction{}, false
+}
+
+// orderHold returns the hold which reserves or has paid the order.
+func (s *Store) orderHold(orderNumber string) (models.Hold, bool) {
+	for _, hold := range s.holds {
+		if hold.OrderNumber != orderNumber {
+			continue
+		}
+		if hold.Status == models.HoldStatusActive || hold.Status == models.HoldStatusCaptured {
+			return hold, true
+		}
+	}
+
+	return models.Hold{}, false
+}
+
+func (s *Store) userHold(userID, holdID string) (models.Account, models.Hold, error) {
+	acc, ok := s.accountByUserID(userID)
+	if !ok {
+		return acc, models.Hold{}, sql.ErrNoRows
+	}
+
+	hold, ok := s.holds[holdID]
+	if !ok || hold.AccountID != acc.ID {
+		return acc, models.Hold{}, sql.ErrNoRows
+	}
+
+	return acc, hold, nil
+}
+
+func (s *Store) releaseHold(acc models.Account, hold models.Hold, status models.HoldStatus) models.Hold {
+	acc.HeldTotal -= hold.Amount
+	acc.UpdatedAt = time.Now()
+	s.accounts[acc.ID] = acc
+
+	hold.Status = status
+	hold.UpdatedAt = time.Now()
+	s.holds[hold.ID] = hold
+
+	return hold
+}
+
+func (s *Store) accountByUserID(userID string) (models.Account, bool) {
+	for _, a := range s.accounts {
+		if a.UserID == userID {
+			return a, true
+		}
+	}
+
+	return models.Account{}, false
+}
+
+func (s *Store) orderByID(id string) (models.Order, bool) {
+	for _, o := range s.orders {
+		if o.ID == id {
+			return o, true
+		}
+	}
+
+	return models.Order{}, false
+}
+
+func newTransaction(accountID, orderNumber string, amount points.Points, direction models.TxDirection) models.Transaction {
+	return models.Transaction{
+		ID:          uuid.NewString(),
+		AccountID:   accountID,
+		Amount:      amount,
+		OrderNumber: orderNumber,
+		Direction:   direction,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+}
+
+func idempotencyKeyID(userID, key string) string {
+	return userID + ":" + key
+}
+
+func integrityViolation(format string, args ...interface{}) error {
+	return &store.InsertError{
+		Err: fmt.Errorf("%w: %s", store.ErrIntegrityViolation, fmt.Sprintf(format, args...)),
+	}
+}
+
+// afterCursor returns true if an item created at t with the id follows the page cursor.
+func afterCursor(page models.Page, t time.Time, id string) bool {
+	if page.After == nil {
+		return true
+	}
+	if page.Desc() {
+		return t.Before(page.After.CreatedAt) || (t.Equal(page.After.CreatedAt) && id < page.After.ID)
+	}
+
+	return t.After(page.After.CreatedAt) || (t.Equal(page.After.CreatedAt) && id > page.After.ID)
+}
+
+func inTimeRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || t.Before(to))
+}
+
+// paginate sorts items by creation time and ID in the page order and applies the page limit.
+func paginate[T any](items []T, page models.Page, key func(T) (time.Time, string)) []T {
+	sort.Slice(items, func(i, j int) bool {
+		ti, idi := key(items[i])
+		tj, idj := key(items[j])
+		if page.Desc() {
+			ti, idi, tj, idj = tj, idj, ti, idi
+		}
+		return ti.Before(tj) || (ti.Equal(tj) && idi < idj)
+	})
+
+	return applyLimit(items, page.Limit)
+}
+
+func contains[T comparable](items []T, item T) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
+
+func applyLimit[T any](items []T, limit int) []T {
+	if limit > 0 && len(items) > limit {
+		return items[:limit]
+	}
+
+	return items
+}
@@ -0,0 +1,1468 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/internal/app/store"
+	"github.com/madatsci/gophermart/pkg/points"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsersAndAccounts(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+
+	t.Run("get user by login", func(t *testing.T) {
+		u, err := s.GetUserByLogin(ctx, "john_doe")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, u.ID)
+
+		_, err = s.GetUserByLogin(ctx, "unknown")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("duplicate login", func(t *testing.T) {
+		_, err := s.CreateUser(ctx, models.User{ID: uuid.NewString(), Login: "john_doe"})
+		require.Error(t, err)
+
+		var sErr store.StoreError
+		require.True(t, errors.As(err, &sErr))
+		assert.True(t, sErr.IntegrityViolation())
+	})
+
+	t.Run("get account by user ID", func(t *testing.T) {
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, acc.ID, a.ID)
+
+		_, err = s.GetAccountByUserID(ctx, uuid.NewString())
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestOrders(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+
+	first := createOrder(t, s, acc.ID, "1111", time.Now().Add(-time.Minute))
+	second := createOrder(t, s, acc.ID, "2222", time.Now())
+
+	t.Run("duplicate number", func(t *testing.T) {
+		err := s.CreateOrder(ctx, &models.Order{ID: uuid.NewString(), AccountID: acc.ID, Number: "1111"})
+		require.Error(t, err)
+
+		var sErr store.StoreError
+		require.True(t, errors.As(err, &sErr))
+		assert.True(t, sErr.IntegrityViolation())
+	})
+
+	t.Run("get order by number", func(t *testing.T) {
+		o, err := s.GetOrderByNumber(ctx, "1111")
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, o.ID)
+		assert.Equal(t, user.ID, o.Account.UserID)
+	})
+
+	t.Run("list by account", func(t *testing.T) {
+		orders, err := s.ListOrders(ctx, acc.ID, models.OrderFilter{Page: models.Page{Limit: 10}})
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		assert.Equal(t, second.ID, orders[0].ID)
+		assert.Equal(t, first.ID, orders[1].ID)
+	})
+
+	t.Run("list with cursor", func(t *testing.T) {
+		page := models.Page{Limit: 1, Order: models.SortOrderDesc}
+
+		orders, err := s.ListOrders(ctx, acc.ID, models.OrderFilter{Page: page})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, second.ID, orders[0].ID)
+
+		page.After = &models.Cursor{CreatedAt: orders[0].CreatedAt, ID: orders[0].ID}
+		orders, err = s.ListOrders(ctx, acc.ID, models.OrderFilter{Page: page})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, first.ID, orders[0].ID)
+
+		page.After = &models.Cursor{CreatedAt: orders[0].CreatedAt, ID: orders[0].ID}
+		orders, err = s.ListOrders(ctx, acc.ID, models.OrderFilter{Page: page})
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+	})
+
+	t.Run("list ascending within time range", func(t *testing.T) {
+		orders, err := s.ListOrders(ctx, acc.ID, models.OrderFilter{
+			Page: models.Page{Limit: 10, Order: models.SortOrderAsc},
+			From: first.CreatedAt,
+			To:   second.CreatedAt.Add(time.Second),
+		})
+		require.NoError(t, err)
+		require.Len(t, orders, 2)
+		assert.Equal(t, first.ID, orders[0].ID)
+
+		orders, err = s.ListOrders(ctx, acc.ID, models.OrderFilter{
+			Page: models.Page{Limit: 10},
+			From: first.CreatedAt.Add(time.Second),
+		})
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, second.ID, orders[0].ID)
+	})
+
+	t.Run("list by status", func(t *testing.T) {
+		orders, err := s.ListOrders(ctx, acc.ID, models.OrderFilter{
+			Page:     models.Page{Limit: 10},
+			Statuses: []models.OrderStatus{models.OrderStatusProcessed},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+	})
+
+	t.Run("update order", func(t *testing.T) {
+		o := first
+		o.Status = models.OrderStatusProcessing
+
+		_, err := s.UpdateOrder(ctx, o, models.OrderStatusProcessed)
+		assert.Error(t, err)
+
+		_, err = s.UpdateOrder(ctx, o, models.OrderStatusNew)
+		require.NoError(t, err)
+
+		orders, err := s.ListOrdersByStatus(ctx, []models.OrderStatus{models.OrderStatusNew}, 10)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, second.ID, orders[0].ID)
+	})
+
+	t.Run("claim due orders", func(t *testing.T) {
+		statuses := []models.OrderStatus{models.OrderStatusNew, models.OrderStatusProcessing}
+		now := time.Now()
+
+		orders, err := s.ClaimDueOrders(ctx, statuses, now, time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, first.ID, orders[0].ID)
+		assert.Equal(t, now.Add(time.Minute), orders[0].NextCheckAt)
+		claimed := orders[0]
+
+		orders, err = s.ClaimDueOrders(ctx, statuses, now, time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, orders, 1, "claimed order must be skipped")
+		assert.Equal(t, second.ID, orders[0].ID)
+
+		orders, err = s.ClaimDueOrders(ctx, statuses, now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Empty(t, orders)
+
+		claimed.Attempts = 1
+		claimed.NextCheckAt = now.Add(2 * time.Minute)
+		require.NoError(t, s.ScheduleOrderCheck(ctx, claimed))
+
+		orders, err = s.ClaimDueOrders(ctx, statuses, now.Add(time.Minute), 5*time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, orders, 1, "order with expired lease must be claimed again")
+		assert.Equal(t, second.ID, orders[0].ID)
+
+		orders, err = s.ClaimDueOrders(ctx, statuses, now.Add(2*time.Minute), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, orders, 1)
+		assert.Equal(t, first.ID, orders[0].ID)
+		assert.Equal(t, 1, orders[0].Attempts)
+
+		claimed.Status = models.OrderStatusNew
+		claimed.Attempts = 2
+		require.NoError(t, s.ScheduleOrderCheck(ctx, claimed))
+		got, err := s.GetOrderByNumber(ctx, claimed.Number)
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Attempts, "schedule of the order with changed status must be kept")
+	})
+}
+
+func TestBalance(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(500)
+
+	a, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(500), a.CurrentPointsTotal)
+
+	a, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(200), models.WithdrawalRules{})
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(300), a.CurrentPointsTotal)
+	assert.Equal(t, points.FromInt(200), a.WithdrawnTotal)
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(301), models.WithdrawalRules{})
+	var balanceErr *store.NotEnoughBalanceError
+	require.True(t, errors.As(err, &balanceErr))
+	assert.Equal(t, points.FromInt(300), balanceErr.Balance)
+
+	txs, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionWithdrawal))
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, "2222", txs[0].OrderNumber)
+}
+
+func TestStatement(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(500)
+
+	_, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+	_, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(200), models.WithdrawalRules{})
+	require.NoError(t, err)
+	_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(50), models.WithdrawalRules{})
+	require.NoError(t, err)
+
+	t.Run("running balance", func(t *testing.T) {
+		entries, err := s.ListStatement(ctx, acc.ID, models.TransactionFilter{Page: models.Page{Limit: 10, Order: models.SortOrderAsc}})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, points.FromInt(500), entries[0].BalanceAfter)
+		assert.Equal(t, points.FromInt(300), entries[1].BalanceAfter)
+		assert.Equal(t, points.FromInt(250), entries[2].BalanceAfter)
+	})
+
+	t.Run("filtered entries keep running balance", func(t *testing.T) {
+		entries, err := s.ListStatement(ctx, acc.ID, transactionFilter(models.TxDirectionWithdrawal))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "3333", entries[0].OrderNumber)
+		assert.Equal(t, points.FromInt(250), entries[0].BalanceAfter)
+		assert.Equal(t, "2222", entries[1].OrderNumber)
+		assert.Equal(t, points.FromInt(300), entries[1].BalanceAfter)
+	})
+}
+
+func TestPointsExpiration(t *testing.T) {
+	ctx := context.Background()
+	s := New(WithPointsLifetime(12))
+
+	user, acc := createUser(t, s, "john_doe")
+
+	first := createOrder(t, s, acc.ID, "1111", time.Now())
+	first.Accrual = points.FromInt(100)
+	_, err := s.AddBalance(ctx, first)
+	require.NoError(t, err)
+
+	second := createOrder(t, s, acc.ID, "2222", time.Now())
+	second.Accrual = points.FromInt(200)
+	_, err = s.AddBalance(ctx, second)
+	require.NoError(t, err)
+
+	// withdrawal consumes the oldest lot first
+	_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(150), models.WithdrawalRules{})
+	require.NoError(t, err)
+
+	lots, err := s.ListExpiringLots(ctx, acc.ID, time.Now().AddDate(1, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, lots, 1)
+	assert.Equal(t, points.FromInt(150), lots[0].Remaining)
+
+	t.Run("nothing is due yet", func(t *testing.T) {
+		expired, err := s.ExpirePoints(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, expired)
+	})
+
+	t.Run("due lots expire", func(t *testing.T) {
+		expired, err := s.ExpirePoints(ctx, time.Now().AddDate(1, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, expired, 1)
+		assert.Equal(t, models.TxDirectionExpiry, expired[0].Direction)
+		assert.Equal(t, "2222", expired[0].OrderNumber)
+		assert.Equal(t, points.FromInt(150), expired[0].Amount)
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.Points(0), a.CurrentPointsTotal)
+		assert.Equal(t, points.FromInt(150), a.WithdrawnTotal)
+
+		drifts, err := s.ReconcileAccounts(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("expired lots are not expired again", func(t *testing.T) {
+		expired, err := s.ExpirePoints(ctx, time.Now().AddDate(1, 0, 1))
+		require.NoError(t, err)
+		assert.Empty(t, expired)
+	})
+}
+
+func TestCarriedPointsExpiration(t *testing.T) {
+	ctx := context.Background()
+	s := New(WithPointsLifetime(12))
+
+	john, johnAcc := createUser(t, s, "john_doe")
+	jane, janeAcc := createUser(t, s, "jane_doe")
+	processOrder(t, s, johnAcc.ID, "1111", points.FromInt(100))
+
+	lots, err := s.ListExpiringLots(ctx, johnAcc.ID, time.Now().AddDate(1, 0, 1))
+	require.NoError(t, err)
+	require.Len(t, lots, 1)
+	expiresAt := lots[0].ExpiresAt
+
+	t.Run("transferred points keep expiry date", func(t *testing.T) {
+		_, err := s.TransferPoints(ctx, john.ID, "jane_doe", points.FromInt(30), 0)
+		require.NoError(t, err)
+
+		lots, err := s.ListExpiringLots(ctx, janeAcc.ID, time.Now().AddDate(1, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		assert.Equal(t, points.FromInt(30), lots[0].Remaining)
+		assert.Equal(t, expiresAt, lots[0].ExpiresAt)
+	})
+
+	t.Run("refunded points keep expiry date", func(t *testing.T) {
+		_, err := s.WithdrawBalance(ctx, john.ID, "2222", points.FromInt(70), models.WithdrawalRules{})
+		require.NoError(t, err)
+
+		_, err = s.RefundWithdrawal(ctx, "2222", points.FromInt(20))
+		require.NoError(t, err)
+		_, err = s.RefundWithdrawal(ctx, "2222", 0)
+		require.NoError(t, err)
+
+		lots, err := s.ListExpiringLots(ctx, johnAcc.ID, time.Now().AddDate(1, 0, 1))
+		require.NoError(t, err)
+		require.Len(t, lots, 2)
+		for _, lot := range lots {
+			assert.Equal(t, expiresAt, lot.ExpiresAt)
+		}
+	})
+
+	t.Run("carried points expire with the original lot", func(t *testing.T) {
+		expired, err := s.ExpirePoints(ctx, expiresAt)
+		require.NoError(t, err)
+		assert.Len(t, expired, 3)
+
+		for _, id := range []string{john.ID, jane.ID} {
+			a, err := s.GetAccountByUserID(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, points.Points(0), a.CurrentPointsTotal)
+		}
+
+		drifts, err := s.ReconcileAccounts(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+}
+
+func TestHolds(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(500)
+	_, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour)
+
+	hold, err := s.CreateHold(ctx, user.ID, "2222", points.FromInt(300), expiresAt, models.WithdrawalRules{})
+	require.NoError(t, err)
+
+	t.Run("held points are not available", func(t *testing.T) {
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(500), a.CurrentPointsTotal)
+		assert.Equal(t, points.FromInt(200), a.Available())
+
+		_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(250), models.WithdrawalRules{})
+		var balanceErr *store.NotEnoughBalanceError
+		require.True(t, errors.As(err, &balanceErr))
+		assert.Equal(t, points.FromInt(200), balanceErr.Balance)
+	})
+
+	t.Run("order is reserved once", func(t *testing.T) {
+		h, err := s.CreateHold(ctx, user.ID, "2222", points.FromInt(300), expiresAt, models.WithdrawalRules{})
+		require.NoError(t, err)
+		assert.Equal(t, hold.ID, h.ID)
+
+		_, err = s.CreateHold(ctx, user.ID, "2222", points.FromInt(100), expiresAt, models.WithdrawalRules{})
+		var sErr store.StoreError
+		require.True(t, errors.As(err, &sErr))
+		assert.True(t, sErr.IntegrityViolation())
+
+		_, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(100), models.WithdrawalRules{})
+		require.True(t, errors.As(err, &sErr))
+	})
+
+	t.Run("capture withdraws held points", func(t *testing.T) {
+		h, err := s.CaptureHold(ctx, user.ID, hold.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.HoldStatusCaptured, h.Status)
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(200), a.CurrentPointsTotal)
+		assert.Equal(t, points.FromInt(300), a.WithdrawnTotal)
+		assert.Equal(t, points.Points(0), a.HeldTotal)
+
+		_, err = s.CaptureHold(ctx, user.ID, hold.ID)
+		require.NoError(t, err)
+
+		_, err = s.VoidHold(ctx, user.ID, hold.ID)
+		assert.ErrorIs(t, err, store.ErrHoldNotActive)
+	})
+
+	t.Run("void releases held points", func(t *testing.T) {
+		h, err := s.CreateHold(ctx, user.ID, "3333", points.FromInt(100), expiresAt, models.WithdrawalRules{})
+		require.NoError(t, err)
+
+		h, err = s.VoidHold(ctx, user.ID, h.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.HoldStatusVoided, h.Status)
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(200), a.Available())
+
+		_, err = s.CaptureHold(ctx, user.ID, h.ID)
+		assert.ErrorIs(t, err, store.ErrHoldNotActive)
+	})
+
+	t.Run("stale holds expire", func(t *testing.T) {
+		h, err := s.CreateHold(ctx, user.ID, "4444", points.FromInt(100), time.Now().Add(time.Minute), models.WithdrawalRules{})
+		require.NoError(t, err)
+
+		expired, err := s.ExpireHolds(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), expired)
+
+		expired, err = s.ExpireHolds(ctx, time.Now().Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), expired)
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.Points(0), a.HeldTotal)
+
+		_, err = s.VoidHold(ctx, user.ID, h.ID)
+		assert.ErrorIs(t, err, store.ErrHoldNotActive)
+	})
+
+	t.Run("hold of another user", func(t *testing.T) {
+		other, _ := createUser(t, s, "jane_doe")
+
+		_, err := s.VoidHold(ctx, other.ID, hold.ID)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestRefundWithdrawal(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(500)
+	_, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(200), models.WithdrawalRules{})
+	require.NoError(t, err)
+
+	t.Run("partial refund", func(t *testing.T) {
+		refund, err := s.RefundWithdrawal(ctx, "2222", points.FromInt(50))
+		require.NoError(t, err)
+		assert.Equal(t, models.TxDirectionRefund, refund.Direction)
+		assert.NotEmpty(t, refund.ParentID)
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(350), a.CurrentPointsTotal)
+		assert.Equal(t, points.FromInt(150), a.WithdrawnTotal)
+	})
+
+	t.Run("refund exceeds withdrawal", func(t *testing.T) {
+		_, err := s.RefundWithdrawal(ctx, "2222", points.FromInt(151))
+		var refundErr *store.RefundExceededError
+		require.True(t, errors.As(err, &refundErr))
+		assert.Equal(t, points.FromInt(150), refundErr.Refundable)
+	})
+
+	t.Run("full refund of the rest", func(t *testing.T) {
+		refund, err := s.RefundWithdrawal(ctx, "2222", 0)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(150), refund.Amount)
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(500), a.CurrentPointsTotal)
+		assert.Equal(t, points.Points(0), a.WithdrawnTotal)
+
+		_, err = s.RefundWithdrawal(ctx, "2222", 0)
+		var refundErr *store.RefundExceededError
+		require.True(t, errors.As(err, &refundErr))
+
+		drifts, err := s.ReconcileAccounts(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("unknown order", func(t *testing.T) {
+		_, err := s.RefundWithdrawal(ctx, "3333", 0)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestReturnOrder(t *testing.T) {
+	ctx := context.Background()
+
+	// setup credits 100 points for order 1111 and spends 70 of them
+	setup := func(t *testing.T, s *Store) (models.User, models.Account) {
+		user, acc := createUser(t, s, "john_doe")
+		processOrder(t, s, acc.ID, "1111", points.FromInt(100))
+
+		_, err := s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(70), models.WithdrawalRules{})
+		require.NoError(t, err)
+
+		return user, acc
+	}
+
+	tests := []struct {
+		policy    models.ClawbackPolicy
+		clawed    points.Points
+		debt      points.Points
+		forgiven  points.Points
+		current   points.Points
+		debtTotal points.Points
+	}{
+		{models.ClawbackPolicyNegative, points.FromInt(100), 0, 0, points.FromInt(-70), 0},
+		{models.ClawbackPolicyDebt, points.FromInt(30), points.FromInt(70), 0, 0, points.FromInt(70)},
+		{models.ClawbackPolicyPartial, points.FromInt(30), 0, points.FromInt(70), 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			s := New()
+			user, _ := setup(t, s)
+
+			c, err := s.ReturnOrder(ctx, "1111", tt.policy)
+			require.NoError(t, err)
+			assert.Equal(t, points.FromInt(100), c.Accrual)
+			assert.Equal(t, tt.clawed, c.Clawed)
+			assert.Equal(t, tt.debt, c.Debt)
+			assert.Equal(t, tt.forgiven, c.Forgiven)
+
+			a, err := s.GetAccountByUserID(ctx, user.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.current, a.CurrentPointsTotal)
+			assert.Equal(t, tt.debtTotal, a.DebtTotal)
+
+			o, err := s.GetOrderByNumber(ctx, "1111")
+			require.NoError(t, err)
+			assert.Equal(t, models.OrderStatusReturned, o.Status)
+
+			_, err = s.ReturnOrder(ctx, "1111", tt.policy)
+			assert.ErrorIs(t, err, store.ErrOrderNotProcessed)
+
+			drifts, err := s.ReconcileAccounts(ctx, false)
+			require.NoError(t, err)
+			assert.Empty(t, drifts)
+		})
+	}
+
+	t.Run("debt is settled by next accruals", func(t *testing.T) {
+		s := New()
+		user, acc := setup(t, s)
+
+		_, err := s.ReturnOrder(ctx, "1111", models.ClawbackPolicyDebt)
+		require.NoError(t, err)
+
+		processOrder(t, s, acc.ID, "3333", points.FromInt(50))
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.Points(0), a.CurrentPointsTotal)
+		assert.Equal(t, points.FromInt(20), a.DebtTotal)
+
+		processOrder(t, s, acc.ID, "4444", points.FromInt(50))
+		a, err = s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(30), a.CurrentPointsTotal)
+		assert.Equal(t, points.Points(0), a.DebtTotal)
+
+		clawbacks, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionClawback))
+		require.NoError(t, err)
+		assert.Len(t, clawbacks, 3)
+
+		drifts, err := s.ReconcileAccounts(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("order is not processed", func(t *testing.T) {
+		s := New()
+		_, acc := createUser(t, s, "john_doe")
+		createOrder(t, s, acc.ID, "1111", time.Now())
+
+		_, err := s.ReturnOrder(ctx, "1111", models.ClawbackPolicyDebt)
+		assert.ErrorIs(t, err, store.ErrOrderNotProcessed)
+
+		_, err = s.ReturnOrder(ctx, "9999", models.ClawbackPolicyDebt)
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestFrozenAccount(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	processOrder(t, s, acc.ID, "1111", points.FromInt(100))
+
+	a, err := s.SetAccountFrozen(ctx, acc.ID, true)
+	require.NoError(t, err)
+	require.True(t, a.Frozen())
+	frozenAt := a.FrozenAt
+
+	a, err = s.SetAccountFrozen(ctx, acc.ID, true)
+	require.NoError(t, err)
+	assert.Equal(t, frozenAt, a.FrozenAt, "repeated freezing must keep the time")
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(10), models.WithdrawalRules{})
+	assert.ErrorIs(t, err, store.ErrAccountFrozen)
+
+	_, err = s.CreateHold(ctx, user.ID, "2222", points.FromInt(10), time.Now().Add(time.Hour), models.WithdrawalRules{})
+	assert.ErrorIs(t, err, store.ErrAccountFrozen)
+
+	processOrder(t, s, acc.ID, "3333", points.FromInt(50))
+
+	a, err = s.SetAccountFrozen(ctx, acc.ID, false)
+	require.NoError(t, err)
+	assert.False(t, a.Frozen())
+	assert.Equal(t, points.FromInt(150), a.CurrentPointsTotal, "frozen account must still earn points")
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(10), models.WithdrawalRules{})
+	assert.NoError(t, err)
+
+	_, err = s.SetAccountFrozen(ctx, uuid.NewString(), true)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestAdjustBalance(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	adjustment := func(amount points.Points, reason models.AdjustmentReason, comment string) models.Adjustment {
+		return models.Adjustment{AccountID: acc.ID, Amount: amount, Reason: reason, Comment: comment, Operator: "jane_support"}
+	}
+
+	credit, err := s.AdjustBalance(ctx, adjustment(points.FromInt(30), models.AdjustmentReasonGoodwill, "late delivery"))
+	require.NoError(t, err)
+	assert.Equal(t, models.TxDirectionAdjustment, credit.Direction)
+	assert.Equal(t, models.AdjustmentReasonGoodwill, credit.Reason)
+	assert.Equal(t, "late delivery", credit.Comment)
+	assert.Equal(t, "jane_support", credit.Operator)
+
+	_, err = s.AdjustBalance(ctx, adjustment(points.FromInt(-40), models.AdjustmentReasonCorrection, ""))
+	var balanceErr *store.NotEnoughBalanceError
+	require.True(t, errors.As(err, &balanceErr))
+	assert.Equal(t, points.FromInt(30), balanceErr.Balance)
+
+	_, err = s.AdjustBalance(ctx, adjustment(points.FromInt(-10), models.AdjustmentReasonOther, ""))
+	assert.Error(t, err, "other reason requires a comment")
+
+	_, err = s.AdjustBalance(ctx, adjustment(points.FromInt(-10), models.AdjustmentReasonCorrection, ""))
+	require.NoError(t, err)
+
+	a, err := s.GetAccountByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(20), a.CurrentPointsTotal)
+	assert.Equal(t, points.Points(0), a.WithdrawnTotal)
+
+	statement, err := s.ListStatement(ctx, acc.ID, transactionFilter(models.TxDirectionAdjustment))
+	require.NoError(t, err)
+	require.Len(t, statement, 2)
+	assert.Equal(t, models.AdjustmentReasonCorrection, statement[0].Reason)
+	assert.Equal(t, points.FromInt(20), statement[0].BalanceAfter)
+	assert.Equal(t, "late delivery", statement[1].Comment)
+
+	drifts, err := s.ReconcileAccounts(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestSetUserRole(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	createUser(t, s, "john_doe")
+
+	user, err := s.SetUserRole(ctx, "john_doe", models.RoleSupport)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleSupport, user.Role)
+
+	user, err = s.GetUserByLogin(ctx, "john_doe")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleSupport, user.Role)
+
+	_, err = s.SetUserRole(ctx, "jane_doe", models.RoleAdmin)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestTransferPoints(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	john, johnAcc := createUser(t, s, "john_doe")
+	jane, janeAcc := createUser(t, s, "jane_doe")
+	processOrder(t, s, johnAcc.ID, "1111", points.FromInt(100))
+
+	transfer, err := s.TransferPoints(ctx, john.ID, "jane_doe", points.FromInt(30), points.FromInt(50))
+	require.NoError(t, err)
+	assert.NotEmpty(t, transfer.ID)
+	assert.Equal(t, "jane_doe", transfer.Recipient)
+	assert.Equal(t, points.FromInt(30), transfer.Amount)
+
+	a, err := s.GetAccountByUserID(ctx, john.ID)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(70), a.CurrentPointsTotal)
+	assert.Equal(t, points.Points(0), a.WithdrawnTotal)
+
+	a, err = s.GetAccountByUserID(ctx, jane.ID)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(30), a.CurrentPointsTotal)
+
+	received, err := s.ListTransactions(ctx, janeAcc.ID, transactionFilter(models.TxDirectionTransferIn))
+	require.NoError(t, err)
+	require.Len(t, received, 1)
+	assert.Equal(t, transfer.ID, received[0].ParentID)
+
+	t.Run("daily limit", func(t *testing.T) {
+		_, err := s.TransferPoints(ctx, john.ID, "jane_doe", points.FromInt(21), points.FromInt(50))
+		var limitErr *store.TransferLimitError
+		require.True(t, errors.As(err, &limitErr))
+		assert.Equal(t, points.FromInt(30), limitErr.Transferred)
+
+		_, err = s.TransferPoints(ctx, john.ID, "jane_doe", points.FromInt(20), points.FromInt(50))
+		assert.NoError(t, err)
+	})
+
+	t.Run("not enough balance", func(t *testing.T) {
+		_, err := s.TransferPoints(ctx, jane.ID, "john_doe", points.FromInt(51), 0)
+		var balanceErr *store.NotEnoughBalanceError
+		require.True(t, errors.As(err, &balanceErr))
+		assert.Equal(t, points.FromInt(50), balanceErr.Balance)
+	})
+
+	t.Run("recipient not found", func(t *testing.T) {
+		_, err := s.TransferPoints(ctx, john.ID, "nobody", points.FromInt(1), 0)
+		assert.ErrorIs(t, err, store.ErrRecipientNotFound)
+	})
+
+	t.Run("self transfer", func(t *testing.T) {
+		_, err := s.TransferPoints(ctx, john.ID, "john_doe", points.FromInt(1), 0)
+		assert.ErrorIs(t, err, store.ErrSelfTransfer)
+	})
+
+	t.Run("frozen sender", func(t *testing.T) {
+		_, err := s.SetAccountFrozen(ctx, janeAcc.ID, true)
+		require.NoError(t, err)
+
+		_, err = s.TransferPoints(ctx, jane.ID, "john_doe", points.FromInt(1), 0)
+		assert.ErrorIs(t, err, store.ErrAccountFrozen)
+	})
+
+	balances, err := s.GetLedgerBalances(ctx)
+	require.NoError(t, err)
+	for _, b := range balances {
+		if b.LedgerAccount == models.LedgerAccountTransfers {
+			assert.Equal(t, points.Points(0), b.Balance, "transfers clearing account must be zero")
+		}
+	}
+
+	drifts, err := s.ReconcileAccounts(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestReferrals(t *testing.T) {
+	ctx := context.Background()
+	s := New(WithReferralBonuses(models.ReferralBonuses{Referrer: points.FromInt(100), Referee: points.FromInt(50)}))
+
+	jane, janeAcc := createUser(t, s, "jane_doe")
+	john, johnAcc := createUser(t, s, "john_doe")
+	bob, _ := createUser(t, s, "bob")
+
+	referral := models.Referral{
+		ID:         uuid.NewString(),
+		ReferrerID: jane.ID,
+		RefereeID:  john.ID,
+		Status:     models.ReferralStatusPending,
+		CreatedAt:  time.Now(),
+	}
+	_, err := s.CreateReferral(ctx, referral, 1)
+	require.NoError(t, err)
+
+	t.Run("limit and constraints", func(t *testing.T) {
+		_, err := s.CreateReferral(ctx, models.Referral{ID: uuid.NewString(), ReferrerID: jane.ID, RefereeID: bob.ID}, 1)
+		assert.ErrorIs(t, err, store.ErrReferralLimitReached)
+
+		_, err = s.CreateReferral(ctx, models.Referral{ID: uuid.NewString(), ReferrerID: bob.ID, RefereeID: bob.ID}, 0)
+		assert.ErrorIs(t, err, store.ErrSelfReferral)
+
+		_, err = s.CreateReferral(ctx, models.Referral{ID: uuid.NewString(), ReferrerID: bob.ID, RefereeID: john.ID}, 0)
+		var sErr store.StoreError
+		require.True(t, errors.As(err, &sErr))
+		assert.True(t, sErr.IntegrityViolation(), "user can be referred only once")
+	})
+
+	processOrder(t, s, johnAcc.ID, "1111", points.FromInt(10))
+	processOrder(t, s, johnAcc.ID, "2222", points.FromInt(10))
+
+	a, err := s.GetAccountByUserID(ctx, john.ID)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(70), a.CurrentPointsTotal, "referee bonus is credited once")
+
+	bonuses, err := s.ListTransactions(ctx, janeAcc.ID, transactionFilter(models.TxDirectionReferral))
+	require.NoError(t, err)
+	require.Len(t, bonuses, 1)
+	assert.Equal(t, points.FromInt(100), bonuses[0].Amount)
+	assert.Empty(t, bonuses[0].OrderNumber)
+
+	summary, err := s.GetReferralSummary(ctx, jane.ID)
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(100), summary.Earned)
+	require.Len(t, summary.Referrals, 1)
+	assert.Equal(t, "john_doe", summary.Referrals[0].Login)
+	assert.Equal(t, models.ReferralStatusRewarded, summary.Referrals[0].Status)
+	assert.Equal(t, "1111", summary.Referrals[0].OrderNumber)
+
+	drifts, err := s.ReconcileAccounts(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestPromoCodes(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	newPromo := func(code string, maxRedemptions int) models.PromoCode {
+		promo, err := s.CreatePromoCode(ctx, models.PromoCode{
+			ID:             uuid.NewString(),
+			Code:           code,
+			Amount:         points.FromInt(10),
+			MaxRedemptions: maxRedemptions,
+			CreatedAt:      time.Now(),
+			UpdatedAt:      time.Now(),
+		})
+		require.NoError(t, err)
+
+		return promo
+	}
+
+	users := make([]models.User, 5)
+	for i := range users {
+		users[i], _ = createUser(t, s, fmt.Sprintf("user_%d", i))
+	}
+
+	t.Run("concurrent redemptions do not exceed the cap", func(t *testing.T) {
+		newPromo("SPRING", 3)
+
+		var (
+			wg       sync.WaitGroup
+			redeemed atomic.Int32
+		)
+		for _, u := range users {
+			wg.Add(1)
+			go func(userID string) {
+				defer wg.Done()
+				if _, err := s.RedeemPromoCode(ctx, userID, "SPRING"); err == nil {
+					redeemed.Add(1)
+				} else {
+					assert.ErrorIs(t, err, store.ErrPromoCodeExhausted)
+				}
+			}(u.ID)
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(3), redeemed.Load())
+	})
+
+	t.Run("user redeems the code once", func(t *testing.T) {
+		newPromo("WELCOME", 10)
+
+		redemption, err := s.RedeemPromoCode(ctx, users[0].ID, "WELCOME")
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(10), redemption.Amount)
+
+		_, err = s.RedeemPromoCode(ctx, users[0].ID, "WELCOME")
+		assert.ErrorIs(t, err, store.ErrPromoCodeRedeemed)
+	})
+
+	t.Run("inactive codes", func(t *testing.T) {
+		_, err := s.RedeemPromoCode(ctx, users[0].ID, "UNKNOWN")
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		newPromo("DISABLED", 10)
+		promo, err := s.DisablePromoCode(ctx, "DISABLED")
+		require.NoError(t, err)
+		assert.NotNil(t, promo.DisabledAt)
+
+		_, err = s.RedeemPromoCode(ctx, users[0].ID, "DISABLED")
+		assert.ErrorIs(t, err, store.ErrPromoCodeInactive)
+
+		future := time.Now().Add(time.Hour)
+		_, err = s.CreatePromoCode(ctx, models.PromoCode{ID: uuid.NewString(), Code: "FUTURE", Amount: points.FromInt(10), MaxRedemptions: 1, StartsAt: &future})
+		require.NoError(t, err)
+
+		_, err = s.RedeemPromoCode(ctx, users[0].ID, "FUTURE")
+		assert.ErrorIs(t, err, store.ErrPromoCodeInactive)
+	})
+
+	_, err := s.CreatePromoCode(ctx, models.PromoCode{ID: uuid.NewString(), Code: "WELCOME", Amount: points.FromInt(10), MaxRedemptions: 1})
+	var sErr store.StoreError
+	require.True(t, errors.As(err, &sErr))
+	assert.True(t, sErr.IntegrityViolation())
+
+	promos, err := s.ListPromoCodes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, promos, 4)
+
+	drifts, err := s.ReconcileAccounts(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+}
+
+func TestTiers(t *testing.T) {
+	ctx := context.Background()
+	s := New(WithTierPolicy(models.TierPolicy{Rules: testTierRules, WindowMonths: 12}))
+
+	_, acc := createUser(t, s, "john_doe")
+	assert.Equal(t, models.TierBronze, acc.Tier)
+
+	processOrder(t, s, acc.ID, "1111", points.FromInt(600))
+	// the order which lifts the account to silver is already credited with silver multiplier
+	processOrder(t, s, acc.ID, "2222", points.FromInt(600))
+
+	accruals, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionAccrual))
+	require.NoError(t, err)
+	require.Len(t, accruals, 2)
+	assert.Equal(t, points.FromInt(750), accruals[0].Amount)
+	assert.Equal(t, models.TierSilver, accruals[0].Tier)
+	assert.Equal(t, points.FromInt(600), accruals[1].Amount)
+
+	status, err := s.RefreshTier(ctx, acc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.TierSilver, status.Tier)
+	assert.Equal(t, points.FromInt(1200), status.Qualifying)
+	assert.Equal(t, points.FromInt(3800), status.Remaining)
+
+	t.Run("returned order", func(t *testing.T) {
+		clawback, err := s.ReturnOrder(ctx, "2222", models.ClawbackPolicyNegative)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(750), clawback.Clawed)
+
+		status, err := s.RefreshTier(ctx, acc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TierBronze, status.Tier)
+	})
+
+	t.Run("qualifying window", func(t *testing.T) {
+		order := createOrder(t, s, acc.ID, "3333", time.Now().AddDate(-1, -1, 0))
+		s.orders[order.Number] = models.Order{
+			ID:        order.ID,
+			AccountID: acc.ID,
+			Number:    order.Number,
+			Status:    models.OrderStatusProcessed,
+			Accrual:   points.FromInt(1000),
+			CreatedAt: order.CreatedAt,
+			UpdatedAt: order.CreatedAt,
+		}
+
+		status, err := s.RefreshTier(ctx, acc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.TierBronze, status.Tier)
+		assert.Equal(t, points.FromInt(600), status.Qualifying)
+	})
+
+	changes, err := s.ListTierChanges(ctx, acc.ID)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, models.TierSilver, changes[0].FromTier)
+	assert.Equal(t, models.TierBronze, changes[0].ToTier)
+	assert.Equal(t, models.TierBronze, changes[1].FromTier)
+	assert.Equal(t, models.TierSilver, changes[1].ToTier)
+	assert.Equal(t, points.FromInt(1200), changes[1].Qualifying)
+}
+
+func TestRepeatedWithdrawal(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	other, _ := createUser(t, s, "jane_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(100)
+
+	_, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+
+	a, err := s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(60), models.WithdrawalRules{})
+	require.NoError(t, err)
+	assert.Equal(t, points.FromInt(40), a.CurrentPointsTotal)
+
+	a, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(60), models.WithdrawalRules{})
+	require.NoError(t, err, "repeated withdrawal must not fail")
+	assert.Equal(t, points.FromInt(40), a.CurrentPointsTotal, "repeated withdrawal must not charge twice")
+	assert.Equal(t, points.FromInt(60), a.WithdrawnTotal)
+
+	var sErr store.StoreError
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(10), models.WithdrawalRules{})
+	require.True(t, errors.As(err, &sErr))
+	assert.True(t, sErr.IntegrityViolation())
+
+	_, err = s.WithdrawBalance(ctx, other.ID, "2222", points.FromInt(60), models.WithdrawalRules{})
+	require.True(t, errors.As(err, &sErr))
+	assert.True(t, sErr.IntegrityViolation())
+}
+
+func TestWithdrawalLimits(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	processOrder(t, s, acc.ID, "1111", points.FromInt(1000))
+
+	rules := models.WithdrawalRules{DailyLimit: points.FromInt(100), MonthlyLimit: points.FromInt(300)}
+
+	_, err := s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(80), rules)
+	require.NoError(t, err)
+
+	var limitErr *store.WithdrawalLimitError
+	_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(30), rules)
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, models.WithdrawalViolationDailyLimit, limitErr.Violation)
+	assert.Equal(t, points.FromInt(80), limitErr.Withdrawn)
+
+	// withdrawals of previous days count only towards the monthly limit
+	for i := range s.transactions {
+		s.transactions[i].CreatedAt = time.Now().AddDate(0, 0, -2)
+	}
+	_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(100), rules)
+	require.NoError(t, err)
+	for i := range s.transactions {
+		s.transactions[i].CreatedAt = time.Now().AddDate(0, 0, -2)
+	}
+	_, err = s.WithdrawBalance(ctx, user.ID, "4444", points.FromInt(100), rules)
+	require.NoError(t, err)
+	for i := range s.transactions {
+		s.transactions[i].CreatedAt = time.Now().AddDate(0, 0, -2)
+	}
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "5555", points.FromInt(30), rules)
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, models.WithdrawalViolationMonthlyLimit, limitErr.Violation)
+	assert.Equal(t, points.FromInt(280), limitErr.Withdrawn)
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "5555", points.FromInt(20), rules)
+	require.NoError(t, err)
+}
+
+func TestHoldWithdrawalLimits(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	processOrder(t, s, acc.ID, "1111", points.FromInt(1000))
+
+	rules := models.WithdrawalRules{DailyLimit: points.FromInt(100)}
+	expiresAt := time.Now().Add(time.Hour)
+
+	hold, err := s.CreateHold(ctx, user.ID, "2222", points.FromInt(60), expiresAt, rules)
+	require.NoError(t, err)
+
+	var limitErr *store.WithdrawalLimitError
+	_, err = s.WithdrawBalance(ctx, user.ID, "3333", points.FromInt(50), rules)
+	require.True(t, errors.As(err, &limitErr), "active hold must count towards the limit")
+	assert.Equal(t, models.WithdrawalViolationDailyLimit, limitErr.Violation)
+	assert.Equal(t, points.FromInt(60), limitErr.Withdrawn)
+
+	_, err = s.CreateHold(ctx, user.ID, "3333", points.FromInt(50), expiresAt, rules)
+	require.True(t, errors.As(err, &limitErr))
+
+	_, err = s.CaptureHold(ctx, user.ID, hold.ID)
+	require.NoError(t, err)
+
+	_, err = s.CreateHold(ctx, user.ID, "3333", points.FromInt(50), expiresAt, rules)
+	require.True(t, errors.As(err, &limitErr), "captured hold must count towards the limit")
+	assert.Equal(t, points.FromInt(60), limitErr.Withdrawn)
+
+	voided, err := s.CreateHold(ctx, user.ID, "3333", points.FromInt(40), expiresAt, rules)
+	require.NoError(t, err)
+	_, err = s.VoidHold(ctx, user.ID, voided.ID)
+	require.NoError(t, err)
+
+	_, err = s.WithdrawBalance(ctx, user.ID, "4444", points.FromInt(40), rules)
+	require.NoError(t, err, "voided hold must not count towards the limit")
+}
+
+func TestConcurrentWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(100)
+
+	_, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s.WithdrawBalance(ctx, user.ID, fmt.Sprintf("%d", 2000+i), points.FromInt(10), models.WithdrawalRules{}) //nolint:errcheck
+		}(i)
+	}
+	wg.Wait()
+
+	a, err := s.GetAccountByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, points.Points(0), a.CurrentPointsTotal)
+	assert.Equal(t, points.FromInt(100), a.WithdrawnTotal)
+}
+
+func TestProcessOrder(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+
+	t.Run("intermediate status does not credit", func(t *testing.T) {
+		o := order
+		o.Status = models.OrderStatusProcessing
+
+		_, err := s.ProcessOrder(ctx, o, models.OrderStatusNew)
+		require.NoError(t, err)
+
+		txs, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionAccrual))
+		require.NoError(t, err)
+		assert.Empty(t, txs)
+	})
+
+	t.Run("processed order is credited exactly once", func(t *testing.T) {
+		o := order
+		o.Status = models.OrderStatusProcessed
+		o.Accrual = points.FromInt(500)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.ProcessOrder(ctx, o, models.OrderStatusProcessing) //nolint:errcheck
+			}()
+		}
+		wg.Wait()
+
+		a, err := s.GetAccountByUserID(ctx, user.ID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(500), a.CurrentPointsTotal)
+
+		_, err = s.AddBalance(ctx, o)
+		var sErr store.StoreError
+		require.True(t, errors.As(err, &sErr))
+		assert.True(t, sErr.IntegrityViolation())
+
+		txs, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionAccrual))
+		require.NoError(t, err)
+		assert.Len(t, txs, 1)
+	})
+}
+
+func TestLedger(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	user, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+	order.Accrual = points.FromInt(500)
+
+	_, err := s.AddBalance(ctx, order)
+	require.NoError(t, err)
+	a, err := s.WithdrawBalance(ctx, user.ID, "2222", points.FromInt(120), models.WithdrawalRules{})
+	require.NoError(t, err)
+
+	entries, err := s.ListLedgerEntries(ctx, acc.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	balances, err := s.GetLedgerBalances(ctx)
+	require.NoError(t, err)
+
+	var total points.Points
+	for _, b := range balances {
+		total += b.Balance
+		switch b.LedgerAccount {
+		case models.LedgerAccountWallet:
+			assert.Equal(t, acc.ID, b.AccountID)
+			assert.Equal(t, a.CurrentPointsTotal, b.Balance)
+		case models.LedgerAccountProgram:
+			assert.Equal(t, points.FromInt(-500), b.Balance)
+		case models.LedgerAccountRedemption:
+			assert.Equal(t, points.FromInt(120), b.Balance)
+		}
+	}
+	assert.Equal(t, points.Points(0), total, "ledger must be balanced")
+}
+
+func TestReconcileAccounts(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+
+	_, acc := createUser(t, s, "john_doe")
+	order := createOrder(t, s, acc.ID, "1111", time.Now())
+
+	drifts, err := s.ReconcileAccounts(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, drifts)
+
+	// order reached PROCESSED without being credited
+	order.Status = models.OrderStatusProcessed
+	order.Accrual = points.FromInt(100)
+	_, err = s.UpdateOrder(ctx, order, models.OrderStatusNew)
+	require.NoError(t, err)
+
+	drifts, err = s.ReconcileAccounts(ctx, false)
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, points.Points(0), drifts[0].CurrentPointsTotal)
+	assert.Equal(t, points.Points(0), drifts[0].ExpectedPointsTotal)
+	require.Len(t, drifts[0].MissingAccruals, 1)
+	assert.False(t, drifts[0].Repaired)
+
+	t.Run("missing accruals are credited as is", func(t *testing.T) {
+		_, err := s.CreateCampaign(ctx, models.Campaign{
+			ID:          uuid.NewString(),
+			Name:        "Double points weekend",
+			StartsAt:    time.Now().Add(-time.Hour),
+			EndsAt:      time.Now().Add(time.Hour),
+			Eligibility: models.CampaignEligibilityAll,
+			Multiplier:  points.FromInt(2),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		})
+		require.NoError(t, err)
+
+		drifts, err := s.ReconcileAccounts(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		assert.True(t, drifts[0].Repaired)
+		assert.Equal(t, points.FromInt(100), drifts[0].ExpectedPointsTotal)
+		assert.Zero(t, drifts[0].Adjustment)
+
+		a, err := s.GetAccountByUserID(ctx, acc.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(100), a.CurrentPointsTotal)
+
+		drifts, err = s.ReconcileAccounts(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("balance drift is booked as adjustment", func(t *testing.T) {
+		a := s.accounts[acc.ID]
+		a.CurrentPointsTotal = points.FromInt(120)
+		s.accounts[acc.ID] = a
+
+		drifts, err := s.ReconcileAccounts(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		assert.Equal(t, points.FromInt(20), drifts[0].Adjustment)
+
+		adjustments, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionAdjustment))
+		require.NoError(t, err)
+		require.Len(t, adjustments, 1)
+		assert.Equal(t, points.FromInt(20), adjustments[0].Amount)
+		assert.Equal(t, models.AdjustmentReasonCorrection, adjustments[0].Reason)
+
+		a, err = s.GetAccountByUserID(ctx, acc.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(120), a.CurrentPointsTotal)
+
+		drifts, err = s.ReconcileAccounts(ctx, false)
+		require.NoError(t, err)
+		assert.Empty(t, drifts)
+	})
+
+	t.Run("held total is derived from active holds", func(t *testing.T) {
+		_, err := s.CreateHold(ctx, acc.UserID, "2222", points.FromInt(50), time.Now().Add(time.Hour), models.WithdrawalRules{})
+		require.NoError(t, err)
+
+		a := s.accounts[acc.ID]
+		a.HeldTotal = 0
+		s.accounts[acc.ID] = a
+
+		drifts, err := s.ReconcileAccounts(ctx, true)
+		require.NoError(t, err)
+		require.Len(t, drifts, 1)
+		assert.Equal(t, points.FromInt(50), drifts[0].ExpectedHeldTotal)
+		assert.Zero(t, drifts[0].Adjustment)
+
+		a, err = s.GetAccountByUserID(ctx, acc.UserID)
+		require.NoError(t, err)
+		assert.Equal(t, points.FromInt(50), a.HeldTotal)
+		assert.Equal(t, points.FromInt(120), a.CurrentPointsTotal)
+	})
+}
+
+var testTierRules = []models.TierRule{
+	{Tier: models.TierBronze, Threshold: 0, Multiplier: points.FromInt(1)},
+	{Tier: models.TierSilver, Threshold: points.FromInt(1000), Multiplier: points.FromMinor(125)},
+	{Tier: models.TierGold, Threshold: points.FromInt(5000), Multiplier: points.FromMinor(150)},
+}
+
+func createUser(t *testing.T, s *Store, login string) (models.User, models.Account) {
+	t.Helper()
+
+	user, err := s.CreateUser(context.Background(), models.User{
+		ID:        uuid.NewString(),
+		Login:     login,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	acc, err := s.CreateAccount(context.Background(), models.Account{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	return user, acc
+}
+
+func createOrder(t *testing.T, s *Store, accountID, number string, createdAt time.Time) models.Order {
+	t.Helper()
+
+	order := models.Order{
+		ID:        uuid.NewString(),
+		AccountID: accountID,
+		Number:    number,
+		Status:    models.OrderStatusNew,
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+	require.NoError(t, s.CreateOrder(context.Background(), &order))
+
+	return order
+}
+
+func processOrder(t *testing.T, s *Store, accountID, number string, accrual points.Points) models.Order {
+	t.Helper()
+
+	order := createOrder(t, s, accountID, number, time.Now())
+	order.Status = models.OrderStatusProcessed
+	order.Accrual = accrual
+	order, err := s.ProcessOrder(context.Background(), order, models.OrderStatusNew)
+	require.NoError(t, err)
+
+	return order
+}
+
+func transactionFilter(directions ...models.TxDirection) models.TransactionFilter {
+	return models.TransactionFilter{
+		Page:       models.Page{Limit: 10},
+		Directions: directions,
+	}
+}
+
+func TestCampaigns(t *testing.T) {
+	ctx := context.Background()
+	s := New(WithTierPolicy(models.TierPolicy{Rules: testTierRules}))
+
+	_, acc := createUser(t, s, "john_doe")
+
+	campaign, err := s.CreateCampaign(ctx, models.Campaign{
+		ID:          uuid.NewString(),
+		Name:        "Double points weekend",
+		StartsAt:    time.Now().Add(-time.Hour),
+		EndsAt:      time.Now().Add(time.Hour),
+		Eligibility: models.CampaignEligibilityAll,
+		Multiplier:  points.FromInt(2),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+	require.NoError(t, err)
+
+	_, err = s.CreateCampaign(ctx, models.Campaign{
+		ID:          uuid.NewString(),
+		Name:        "Gold members",
+		StartsAt:    time.Now().Add(-time.Hour),
+		EndsAt:      time.Now().Add(time.Hour),
+		Eligibility: models.CampaignEligibilityTier,
+		Tier:        models.TierGold,
+		FixedBonus:  points.FromInt(1000),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	})
+	require.NoError(t, err)
+
+	processOrder(t, s, acc.ID, "1111", points.FromInt(100))
+
+	accruals, err := s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionAccrual))
+	require.NoError(t, err)
+	require.Len(t, accruals, 1)
+	assert.Equal(t, points.FromInt(200), accruals[0].Amount)
+	assert.Equal(t, campaign.ID, accruals[0].CampaignID)
+	assert.Equal(t, points.FromInt(100), accruals[0].CampaignBonus)
+
+	_, err = s.DisableCampaign(ctx, campaign.ID)
+	require.NoError(t, err)
+
+	processOrder(t, s, acc.ID, "2222", points.FromInt(100))
+
+	accruals, err = s.ListTransactions(ctx, acc.ID, transactionFilter(models.TxDirectionAccrual))
+	require.NoError(t, err)
+	require.Len(t, accruals, 2)
+	assert.Equal(t, points.FromInt(100), accruals[0].Amount)
+	assert.Empty(t, accruals[0].CampaignID)
+
+	campaigns, err := s.ListCampaigns(ctx)
+	require.NoError(t, err)
+	require.Len(t, campaigns, 2)
+	for _, c := range campaigns {
+		if c.ID == campaign.ID {
+			assert.Equal(t, 1, c.Orders)
+			assert.Equal(t, points.FromInt(100), c.Credited)
+			assert.NotNil(t, c.DisabledAt)
+		} else {
+			assert.Zero(t, c.Orders)
+		}
+	}
+
+	_, err = s.DisableCampaign(ctx, uuid.NewString())
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -3,8 +3,10 @@ package store
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/madatsci/gophermart/internal/app/models"
+	"github.com/madatsci/gophermart/pkg/points"
 	"github.com/uptrace/bun/driver/pgdriver"
 )
 
@@ -12,34 +14,161 @@ type Store interface {
 	// Users
 	CreateUser(ctx context.Context, user models.User) (models.User, error)
 	GetUserByLogin(ctx context.Context, login string) (models.User, error)
+	GetUserByID(ctx context.Context, id string) (models.User, error)
+	SetUserRole(ctx context.Context, login string, role models.Role) (models.User, error)
 
 	// Accounts
 	CreateAccount(ctx context.Context, account models.Account) (models.Account, error)
 	GetAccountByUserID(ctx context.Context, userID string) (models.Account, error)
-	WithdrawBalance(ctx context.Context, userID string, orderNumber string, sum float32) (models.Account, error)
+	WithdrawBalance(ctx context.Context, userID string, orderNumber string, sum points.Points, rules models.WithdrawalRules) (models.Account, error)
 	AddBalance(ctx context.Context, order models.Order) (models.Account, error)
+	RefundWithdrawal(ctx context.Context, orderNumber string, sum points.Points) (models.Transaction, error)
+	AdjustBalance(ctx context.Context, adjustment models.Adjustment) (models.Transaction, error)
+	SetAccountFrozen(ctx context.Context, accountID string, frozen bool) (models.Account, error)
+	TransferPoints(ctx context.Context, userID string, recipientLogin string, sum points.Points, dailyLimit points.Points) (models.Transfer, error)
+
+	// Referrals
+	GetUserByReferralCode(ctx context.Context, code string) (models.User, error)
+	CreateReferral(ctx context.Context, referral models.Referral, limit int) (models.Referral, error)
+	GetReferralSummary(ctx context.Context, userID string) (models.ReferralSummary, error)
+
+	// Promo codes
+	CreatePromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error)
+	ListPromoCodes(ctx context.Context) ([]models.PromoCode, error)
+	DisablePromoCode(ctx context.Context, code string) (models.PromoCode, error)
+	RedeemPromoCode(ctx context.Context, userID string, code string) (models.PromoRedemption, error)
+
+	// Campaigns
+	CreateCampaign(ctx context.Context, campaign models.Campaign) (models.Campaign, error)
+	ListCampaigns(ctx context.Context) ([]models.CampaignStats, error)
+	DisableCampaign(ctx context.Context, id string) (models.Campaign, error)
+
+	// Tiers
+	RefreshTier(ctx context.Context, accountID string) (models.TierStatus, error)
+	ListTierChanges(ctx context.Context, accountID string) ([]models.TierChange, error)
+
+	// Holds
+	CreateHold(ctx context.Context, userID string, orderNumber string, sum points.Points, expiresAt time.Time, rules models.WithdrawalRules) (models.Hold, error)
+	CaptureHold(ctx context.Context, userID string, holdID string) (models.Hold, error)
+	VoidHold(ctx context.Context, userID string, holdID string) (models.Hold, error)
+	ExpireHolds(ctx context.Context, now time.Time) (int64, error)
 
 	// Orders
 	CreateOrder(ctx context.Context, order *models.Order) error
 	GetOrderByNumber(ctx context.Context, orderNumber string) (models.Order, error)
-	ListOrdersByAccountID(ctx context.Context, accountID string, limit int) ([]models.Order, error)
+	ListOrders(ctx context.Context, accountID string, filter models.OrderFilter) ([]models.Order, error)
 	ListOrdersByStatus(ctx context.Context, statuses []models.OrderStatus, limit int) ([]models.Order, error)
+	ClaimDueOrders(ctx context.Context, statuses []models.OrderStatus, now time.Time, leaseTTL time.Duration, limit int) ([]models.Order, error)
+	ScheduleOrderCheck(ctx context.Context, order models.Order) error
 	UpdateOrder(ctx context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error)
+	ProcessOrder(ctx context.Context, order models.Order, prevStatus models.OrderStatus) (models.Order, error)
+	ReturnOrder(ctx context.Context, orderNumber string, policy models.ClawbackPolicy) (models.Clawback, error)
 
 	// Transactions
-	GetWithdrawals(ctx context.Context, accountID string, direction models.TxDirection, limit int) ([]models.Transaction, error)
+	ListTransactions(ctx context.Context, accountID string, filter models.TransactionFilter) ([]models.Transaction, error)
+	ListStatement(ctx context.Context, accountID string, filter models.TransactionFilter) ([]models.StatementEntry, error)
+
+	// Points lots
+	ListExpiringLots(ctx context.Context, accountID string, before time.Time) ([]models.PointsLot, error)
+	ExpirePoints(ctx context.Context, now time.Time) ([]models.Transaction, error)
+
+	// Idempotency keys
+	CreateIdempotencyKey(ctx context.Context, key models.IdempotencyKey) error
+	GetIdempotencyKey(ctx context.Context, userID string, key string) (models.IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key models.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, userID string, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+
+	// Ledger
+	ListLedgerEntries(ctx context.Context, accountID string, limit int) ([]models.LedgerEntry, error)
+	GetLedgerBalances(ctx context.Context) ([]models.LedgerBalance, error)
+	ReconcileAccounts(ctx context.Context, repair bool) ([]models.AccountDrift, error)
 }
 
+// ErrIntegrityViolation is reported by storages which are not backed by PostgreSQL
+// when a unique or foreign key constraint would be violated.
+var ErrIntegrityViolation = errors.New("integrity violation")
+
+// ErrOrderNotProcessed is returned when an order which has not been processed is returned.
+var ErrOrderNotProcessed = errors.New("order is not processed")
+
+// ErrAccountFrozen is returned when points of a frozen account are spent.
+var ErrAccountFrozen = errors.New("account is frozen")
+
+// ErrRecipientNotFound is returned when points are transferred to an unknown user.
+var ErrRecipientNotFound = errors.New("recipient not found")
+
+// ErrSelfTransfer is returned when the user transfers points to themselves.
+var ErrSelfTransfer = errors.New("transfer to the same account")
+
+// ErrSelfReferral is returned when the user is referred by themselves.
+var ErrSelfReferral = errors.New("referral to the same user")
+
+// ErrReferralCodeTaken is returned when a user is created with a referral code which belongs to another user.
+var ErrReferralCodeTaken = errors.New("referral code is taken")
+
+// ErrReferralLimitReached is returned when the referrer has already invited the maximum number of users.
+var ErrReferralLimitReached = errors.New("referral limit reached")
+
+// ErrPromoCodeInactive is returned when a promo code which is disabled, has not started or has ended is redeemed.
+var ErrPromoCodeInactive = errors.New("promo code is not active")
+
+// ErrPromoCodeExhausted is returned when a promo code has already been redeemed the maximum number of times.
+var ErrPromoCodeExhausted = errors.New("promo code is exhausted")
+
+// ErrPromoCodeRedeemed is returned when the user redeems the same promo code twice.
+var ErrPromoCodeRedeemed = errors.New("promo code has already been redeemed")
+
+// ErrHoldNotActive is returned when a hold which has been released or has expired is captured or voided.
+var ErrHoldNotActive = errors.New("hold is not active")
+
 type NotEnoughBalanceError struct {
 	Err               error
-	Balance           float32
-	WithdrawRequested float32
+	Balance           points.Points
+	WithdrawRequested points.Points
 }
 
 func (e *NotEnoughBalanceError) Error() string {
 	return e.Err.Error()
 }
 
+// RefundExceededError is returned when a refund exceeds the part of the withdrawal which has not been refunded yet.
+type RefundExceededError struct {
+	Err             error
+	Refundable      points.Points
+	RefundRequested points.Points
+}
+
+func (e *RefundExceededError) Error() string {
+	return e.Err.Error()
+}
+
+// TransferLimitError is returned when transfers of the user within the last 24 hours would exceed the daily limit.
+type TransferLimitError struct {
+	Err         error
+	Limit       points.Points
+	Transferred points.Points
+	Requested   points.Points
+}
+
+func (e *TransferLimitError) Error() string {
+	return e.Err.Error()
+}
+
+// WithdrawalLimitError is returned when withdrawals of the user within the last 24 hours or the last month
+// would exceed the cap of withdrawal rules.
+type WithdrawalLimitError struct {
+	Err       error
+	Violation models.WithdrawalViolation
+	Limit     points.Points
+	Withdrawn points.Points
+	Requested points.Points
+}
+
+func (e *WithdrawalLimitError) Error() string {
+	return e.Err.Error()
+}
+
 type InsertError struct {
 	Err error
 }
@@ -49,6 +178,10 @@ func (e *InsertError) Error() string {
 }
 
 func (e *InsertError) IntegrityViolation() bool {
+	if errors.Is(e.Err, ErrIntegrityViolation) {
+		return true
+	}
+
 	var pgErr pgdriver.Error
 	return errors.As(e.Err, &pgErr) && pgErr.IntegrityViolation()
 }
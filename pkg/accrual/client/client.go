@@ -1,10 +1,17 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/madatsci/gophermart/internal/app/config"
@@ -14,11 +21,21 @@ import (
 
 type (
 	Client struct {
-		baseURL string
-		http    *http.Client
-		log     *zap.SugaredLogger
+		baseURL   string
+		http      *http.Client
+		limiter   *Limiter
+		userAgent string
+		log       *zap.SugaredLogger
+
+		// GET requests failed with server or network errors are retried up to retries times,
+		// the delay starts from retryBase and doubles with every retry.
+		retries   int
+		retryBase time.Duration
 	}
 
+	// Option configures the client.
+	Option func(*Client)
+
 	RequestOptions struct {
 		Name   string
 		Path   string
@@ -37,25 +54,171 @@ func (e *RequestError) Error() string {
 	return e.Err.Error()
 }
 
-func New(config *config.Config, logger *zap.SugaredLogger) *Client {
-	return &Client{
-		baseURL: config.AccrualSystemAddress,
-		http:    &http.Client{},
-		log:     logger,
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the error with sentinel errors by the response status code.
+func (e *RequestError) Is(target error) bool {
+	switch target {
+	case ErrNotRegistered:
+		return e.StatusCode == http.StatusNoContent
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+var (
+	// ErrNotRegistered is returned when the order is not registered in accrual system.
+	ErrNotRegistered = errors.New("order is not registered in accrual system")
+	// ErrRateLimited is returned when accrual system responds with too many requests.
+	ErrRateLimited = errors.New("too many requests to accrual system")
+	// ErrServer is returned when accrual system responds with 5xx status code.
+	ErrServer = errors.New("accrual system server error")
+)
+
+const (
+	// defaultRetryAfter is how long requests are held after too many requests response without Retry-After header.
+	defaultRetryAfter = time.Minute
+
+	defaultTimeout   = 10 * time.Second
+	defaultUserAgent = "gophermart"
+	defaultRetries   = 3
+	defaultRetryBase = 100 * time.Millisecond
+	maxRetryDelay    = 5 * time.Second
+)
+
+// rateLimitPattern matches the limit in too many requests response of accrual system.
+var rateLimitPattern = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// WithTimeout sets the time limit of a single request including reading the response body.
+// By default it is 10 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.http.Timeout = timeout
+	}
+}
+
+// WithTransport sets the transport requests are sent by. By default it is http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.http.Transport = transport
+	}
+}
+
+// WithUserAgent sets User-Agent header of requests. By default it is "gophermart".
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithRetries sets how many times GET requests failed with server or network errors are retried
+// and the delay before the first retry. By default requests are retried 3 times starting from 100ms,
+// zero retries disables them.
+func WithRetries(retries int, base time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.retryBase = base
+	}
+}
+
+// New creates accrual system client, the address of accrual system must be an absolute http(s) URL.
+func New(config *config.Config, logger *zap.SugaredLogger, opts ...Option) (*Client, error) {
+	u, err := url.Parse(config.AccrualSystemAddress)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid accrual system address: %s", config.AccrualSystemAddress)
+	}
+
+	c := &Client{
+		baseURL:   strings.TrimRight(config.AccrualSystemAddress, "/"),
+		http:      &http.Client{Timeout: defaultTimeout},
+		limiter:   NewLimiter(config.AccrualRateLimit),
+		userAgent: defaultUserAgent,
+		log:       logger,
+		retries:   defaultRetries,
+		retryBase: defaultRetryBase,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+func (c *Client) get(ctx context.Context, r RequestOptions) (string, error) {
+	return c.doRequest(ctx, http.MethodGet, r)
+}
+
+// doRequest sends the request and retries idempotent GET requests failed with server or network
+// errors with exponential backoff and jitter.
+func (c *Client) doRequest(ctx context.Context, method string, r RequestOptions) (string, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := c.send(ctx, method, r)
+		if err == nil || method != http.MethodGet || attempt > c.retries || !c.retryable(ctx, err) {
+			return body, err
+		}
+
+		delay := retryDelay(attempt, c.retryBase)
+		c.log.With(
+			"name", r.Name,
+			"attempt", attempt,
+			"delay", delay.Seconds(),
+			"err", err,
+		).Info("retrying accrual system request")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", err
+		case <-timer.C:
+		}
 	}
 }
 
-func (c *Client) get(r RequestOptions) (string, error) {
-	return c.doRequest(http.MethodGet, r)
+// retryable returns true if the request failed with server or network error.
+func (c *Client) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, ErrServer) || errors.As(err, &netErr)
 }
 
-func (c *Client) doRequest(method string, r RequestOptions) (string, error) {
-	req, err := http.NewRequest(method, c.baseURL+r.Path, nil)
+// retryDelay returns the delay before the retry: base doubled with every attempt up to maxRetryDelay,
+// with a random jitter of up to a half of it so that concurrent requests are not retried together.
+func retryDelay(attempt int, base time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// send sends the request when the limiter allows it. Too many requests response holds requests
+// of all callers until Retry-After elapses and lowers the limit to the one reported by accrual system.
+func (c *Client) send(ctx context.Context, method string, r RequestOptions) (string, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return "", &RequestError{
+			Err: errors.Wrap(err, "wait for rate limiter"),
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+r.Path, nil)
 	if err != nil {
 		return "", &RequestError{
 			Err: errors.Wrap(err, "build request"),
 		}
 	}
+	req.Header.Set("User-Agent", c.userAgent)
 
 	var res *http.Response
 	start := time.Now()
@@ -85,14 +248,7 @@ func (c *Client) doRequest(method string, r RequestOptions) (string, error) {
 			ResponseBody: resBody,
 		}
 		if res.StatusCode == http.StatusTooManyRequests {
-			if durationStr := res.Header.Get("Retry-After"); durationStr != "" {
-				duration, err := time.ParseDuration(durationStr + "s")
-				if err != nil {
-					c.log.Errorf("could not parse duration from response header: %s", durationStr)
-				} else {
-					reqErr.RetryAfter = duration
-				}
-			}
+			c.throttle(res, resBody, reqErr)
 		}
 
 		return "", reqErr
@@ -109,6 +265,49 @@ func (c *Client) doRequest(method string, r RequestOptions) (string, error) {
 	return string(resBody), nil
 }
 
+// throttle pauses the limiter after too many requests response and learns the allowed rate from its body.
+func (c *Client) throttle(res *http.Response, body []byte, reqErr *RequestError) {
+	now := time.Now()
+
+	reqErr.RetryAfter = defaultRetryAfter
+	if value := res.Header.Get("Retry-After"); value != "" {
+		if retryAfter, ok := parseRetryAfter(value, now); ok {
+			reqErr.RetryAfter = retryAfter
+		} else {
+			c.log.Errorf("could not parse Retry-After header: %s", value)
+		}
+	}
+	c.limiter.Pause(now.Add(reqErr.RetryAfter))
+
+	if m := rateLimitPattern.FindSubmatch(body); m != nil {
+		if perMinute, err := strconv.Atoi(string(m[1])); err == nil {
+			c.limiter.Learn(perMinute)
+		}
+	}
+
+	c.log.With(
+		"retry_after", reqErr.RetryAfter.Seconds(),
+		"rate_limit", c.limiter.Limit(),
+	).Info("accrual system requests paused")
+}
+
+// parseRetryAfter parses Retry-After header value which is either delay in seconds or HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(t.Sub(now), 0), true
+}
+
 func (c *Client) logRequest(name, method string, start time.Time, res *http.Response) {
 	var status int
 	if res != nil {
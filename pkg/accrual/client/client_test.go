@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/madatsci/gophermart/internal/app/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 11, 26, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{name: "delta seconds", value: "60", want: time.Minute, ok: true},
+		{name: "HTTP date", value: "Tue, 26 Nov 2024 10:00:30 GMT", want: 30 * time.Second, ok: true},
+		{name: "HTTP date in the past", value: "Tue, 26 Nov 2024 09:00:00 GMT", want: 0, ok: true},
+		{name: "negative seconds", value: "-1", ok: false},
+		{name: "garbage", value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte("No more than 120 requests per minute allowed")) //nolint:errcheck
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":"2377225624","status":"PROCESSED","accrual":500}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	c, err := New(&config.Config{AccrualSystemAddress: srv.URL}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+
+	_, err = c.GetOrder(context.Background(), "2377225624")
+	var requestErr *RequestError
+	require.ErrorAs(t, err, &requestErr)
+	assert.Equal(t, http.StatusTooManyRequests, requestErr.StatusCode)
+	assert.Equal(t, time.Second, requestErr.RetryAfter)
+	assert.Equal(t, 120, c.limiter.Limit())
+
+	t.Run("requests are held until Retry-After elapses", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := c.GetOrder(ctx, "2377225624")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), requests.Load())
+
+		start := time.Now()
+		order, err := c.GetOrder(context.Background(), "2377225624")
+		require.NoError(t, err)
+		assert.Equal(t, OrderStatusProcessed, order.Status)
+		assert.Greater(t, time.Since(start), 500*time.Millisecond)
+	})
+}
+
+func TestNew(t *testing.T) {
+	for _, address := range []string{"", "localhost:8081", "ftp://localhost:8081", "http://"} {
+		_, err := New(&config.Config{AccrualSystemAddress: address}, zap.NewNop().Sugar())
+		assert.Error(t, err, address)
+	}
+
+	c, err := New(&config.Config{AccrualSystemAddress: "http://localhost:8081/"}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:8081", c.baseURL)
+}
+
+func TestRetries(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-agent", r.UserAgent())
+
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":"2377225624","status":"PROCESSED","accrual":500}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	newClient := func(retries int) *Client {
+		c, err := New(&config.Config{AccrualSystemAddress: srv.URL}, zap.NewNop().Sugar(),
+			WithUserAgent("test-agent"), WithRetries(retries, time.Millisecond))
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("server errors are retried", func(t *testing.T) {
+		requests.Store(0)
+
+		order, err := newClient(2).GetOrder(context.Background(), "2377225624")
+		require.NoError(t, err)
+		assert.Equal(t, OrderStatusProcessed, order.Status)
+		assert.Equal(t, int32(3), requests.Load())
+	})
+
+	t.Run("retries are limited", func(t *testing.T) {
+		requests.Store(0)
+
+		_, err := newClient(1).GetOrder(context.Background(), "2377225624")
+		require.ErrorIs(t, err, ErrServer)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+}
+
+func TestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/orders/1":
+			w.WriteHeader(http.StatusNoContent)
+		case "/api/orders/2":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/api/orders/3":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/api/orders/4":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(&config.Config{AccrualSystemAddress: srv.URL}, zap.NewNop().Sugar(),
+		WithRetries(0, 0), WithTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = c.GetOrder(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrNotRegistered)
+	assert.NotErrorIs(t, err, ErrServer)
+
+	_, err = c.GetOrder(context.Background(), "3")
+	assert.ErrorIs(t, err, ErrServer)
+
+	_, err = c.GetOrder(context.Background(), "4")
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+
+	// too many requests response pauses the limiter, so it goes last
+	_, err = c.GetOrder(context.Background(), "2")
+	assert.ErrorIs(t, err, ErrRateLimited)
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: maxRetryDelay} {
+		d := retryDelay(attempt, 100*time.Millisecond)
+		assert.GreaterOrEqual(t, d, want/2)
+		assert.LessOrEqual(t, d, want)
+	}
+}
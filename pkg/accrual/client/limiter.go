@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is shared by all callers of the client. It spaces requests evenly so that no more than
+// the limit of requests is sent per minute and holds all requests while accrual system asks to retry later.
+// Zero limit means requests are not limited until the limit is learned from accrual system.
+type Limiter struct {
+	mu        sync.Mutex
+	perMinute int
+	interval  time.Duration
+	next      time.Time
+}
+
+// NewLimiter creates new Limiter allowing perMinute requests per minute.
+func NewLimiter(perMinute int) *Limiter {
+	l := &Limiter{}
+	l.setLimit(perMinute)
+
+	return l
+}
+
+// Wait blocks until the next request is allowed or the context is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	at := l.next
+	if now := time.Now(); at.Before(now) {
+		at = now
+	}
+	reserved := at
+	if l.interval > 0 {
+		reserved = at.Add(l.interval)
+		l.next = reserved
+	}
+	l.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.release(at, reserved)
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Pause holds all requests until the time.
+func (l *Limiter) Pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.next) {
+		l.next = until
+	}
+}
+
+// Learn lowers the limit to perMinute requests per minute unless it is already lower.
+func (l *Limiter) Learn(perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perMinute > 0 && (l.perMinute == 0 || perMinute < l.perMinute) {
+		l.setLimit(perMinute)
+	}
+}
+
+// Limit returns the number of requests allowed per minute, zero means no limit.
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.perMinute
+}
+
+// release gives the slot reserved by a cancelled Wait back unless it was already taken by another caller
+// or the limiter was paused in the meantime.
+func (l *Limiter) release(at, reserved time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.next.Equal(reserved) {
+		l.next = at
+	}
+}
+
+func (l *Limiter) setLimit(perMinute int) {
+	if perMinute <= 0 {
+		return
+	}
+
+	l.perMinute = perMinute
+	l.interval = time.Minute / time.Duration(perMinute)
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("requests are spaced evenly across goroutines", func(t *testing.T) {
+		// 1200 requests per minute is one request per 50ms
+		l := NewLimiter(1200)
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+			at []time.Time
+		)
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				require.NoError(t, l.Wait(context.Background()))
+
+				mu.Lock()
+				at = append(at, time.Now())
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		first, last := at[0], at[0]
+		for _, a := range at {
+			if a.Before(first) {
+				first = a
+			}
+			if a.After(last) {
+				last = a
+			}
+		}
+		assert.GreaterOrEqual(t, last.Sub(first), 190*time.Millisecond)
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		l := NewLimiter(0)
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, l.Wait(context.Background()))
+		}
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		// one request per minute
+		l := NewLimiter(1)
+		require.NoError(t, l.Wait(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("cancelled wait gives the slot back", func(t *testing.T) {
+		// 600 requests per minute is one request per 100ms
+		l := NewLimiter(600)
+		start := time.Now()
+		require.NoError(t, l.Wait(context.Background()))
+
+		for i := 0; i < 3; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+			cancel()
+		}
+
+		require.NoError(t, l.Wait(context.Background()))
+		assert.Less(t, time.Since(start), 200*time.Millisecond)
+	})
+}
+
+func TestLimiterPause(t *testing.T) {
+	l := NewLimiter(0)
+	l.Pause(time.Now().Add(50 * time.Millisecond))
+
+	start := time.Now()
+	require.NoError(t, l.Wait(context.Background()))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	// pause in the past does not hold requests
+	l.Pause(time.Now().Add(-time.Minute))
+	start = time.Now()
+	require.NoError(t, l.Wait(context.Background()))
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestLimiterLearn(t *testing.T) {
+	l := NewLimiter(0)
+	l.Learn(60)
+	assert.Equal(t, 60, l.Limit())
+
+	l.Learn(100)
+	assert.Equal(t, 60, l.Limit(), "learned limit must not raise the limit")
+
+	l.Learn(30)
+	assert.Equal(t, 30, l.Limit())
+}
@@ -1,10 +1,16 @@
 package client
 
+import (
+	"context"
+
+	"github.com/madatsci/gophermart/pkg/points"
+)
+
 // OrderResponse represents order object that is received from accrual service.
 type OrderResponse struct {
-	Order   string      `json:"order"`
-	Status  OrderStatus `json:"status"`
-	Accrual float32     `json:"accrual"`
+	Order   string        `json:"order"`
+	Status  OrderStatus   `json:"status"`
+	Accrual points.Points `json:"accrual"`
 }
 
 // IsFinalStatus returns true if the order is in its final status.
@@ -22,7 +28,7 @@ const (
 )
 
 // GetOrder returns order status from accrual system.
-func (c *Client) GetOrder(number string) (OrderResponse, error) {
+func (c *Client) GetOrder(ctx context.Context, number string) (OrderResponse, error) {
 	var res OrderResponse
 
 	req := RequestOptions{
@@ -31,7 +37,7 @@ func (c *Client) GetOrder(number string) (OrderResponse, error) {
 		Result: &res,
 	}
 
-	_, err := c.get(req)
+	_, err := c.get(ctx, req)
 
 	return res, err
 }
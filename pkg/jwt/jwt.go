@@ -44,8 +44,9 @@ func New(opts Options) *JWT {
 
 // GetString returns signed JWT token as string.
 func (j *JWT) GetString(userID string) (string, error) {
-	j.claims.UserID = userID
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, j.claims)
+	claims := j.claims
+	claims.UserID = userID
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := token.SignedString(j.Secret)
 	if err != nil {
@@ -57,19 +58,31 @@ func (j *JWT) GetString(userID string) (string, error) {
 
 // GetUserID parses user ID from token.
 func (j *JWT) GetUserID(tokenString string) (string, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &j.claims, func(t *jwt.Token) (interface{}, error) {
+	claims, err := j.GetClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.UserID, nil
+}
+
+// GetClaims parses and validates claims of the token.
+func (j *JWT) GetClaims(tokenString string) (Claims, error) {
+	var claims Claims
+
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
 		return j.Secret, nil
 	})
 	if err != nil {
-		return "", errors.Wrap(err, "token parsing error")
+		return Claims{}, errors.Wrap(err, "token parsing error")
 	}
 
 	if !token.Valid {
-		return "", errInvalidToken
+		return Claims{}, errInvalidToken
 	}
 
-	return j.claims.UserID, nil
+	return claims, nil
 }
@@ -61,3 +61,19 @@ func TestInvalidSignature(t *testing.T) {
 	assert.Empty(t, decodedUserID)
 	assert.ErrorIs(t, err, j.ErrTokenSignatureInvalid)
 }
+
+func TestClaims(t *testing.T) {
+	jwt := New(Options{
+		Secret:   []byte("secret_key"),
+		Duration: time.Hour,
+	})
+
+	userID := uuid.NewString()
+
+	tokenString, err := jwt.GetString(userID)
+	require.NoError(t, err)
+
+	claims, err := jwt.GetClaims(tokenString)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+}
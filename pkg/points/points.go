@@ -0,0 +1,181 @@
+package points
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Scale is the number of decimal places kept by Points.
+const Scale = 2
+
+// unit is the amount of minor units in one point.
+const unit = 100
+
+var (
+	errInvalidFormat = errors.New("invalid points format")
+	errPrecision     = errors.New("points precision exceeded")
+	errOverflow      = errors.New("points value overflow")
+)
+
+// Points is an exact amount of loyalty points stored in minor units (hundredths of a point).
+type Points int64
+
+// FromInt converts whole number of points to Points.
+func FromInt(n int64) Points {
+	return Points(n * unit)
+}
+
+// FromMinor creates Points from amount of minor units.
+func FromMinor(n int64) Points {
+	return Points(n)
+}
+
+// Parse parses decimal string such as "729.98". Digits beyond Scale are only allowed if they are zeros.
+func Parse(s string) (Points, error) {
+	if s == "" {
+		return 0, errInvalidFormat
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return 0, errInvalidFormat
+	}
+	if !isDigits(whole) || !isDigits(fraction) {
+		return 0, errInvalidFormat
+	}
+
+	if len(fraction) > Scale {
+		if strings.Trim(fraction[Scale:], "0") != "" {
+			return 0, errPrecision
+		}
+		fraction = fraction[:Scale]
+	}
+	fraction += strings.Repeat("0", Scale-len(fraction))
+
+	f, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, errInvalidFormat
+	}
+
+	var w int64
+	if whole != "" {
+		w, err = strconv.ParseInt(whole, 10, 64)
+		if err != nil || w > (math.MaxInt64-f)/unit {
+			return 0, errOverflow
+		}
+	}
+
+	p := Points(w*unit + f)
+	if negative {
+		p = -p
+	}
+
+	return p, nil
+}
+
+// Minor returns amount of minor units.
+func (p Points) Minor() int64 {
+	return int64(p)
+}
+
+// String returns decimal representation without trailing zeros, e.g. "500", "500.5", "729.98".
+func (p Points) String() string {
+	v := int64(p)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	whole := v / unit
+	fraction := v % unit
+	if fraction == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+
+	f := strings.TrimRight(fmt.Sprintf("%0*d", Scale, fraction), "0")
+
+	return fmt.Sprintf("%s%d.%s", sign, whole, f)
+}
+
+// MarshalJSON encodes points as JSON number.
+func (p Points) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalJSON decodes points from JSON number or numeric string.
+func (p *Points) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+
+	if strings.ContainsAny(s, "eE") {
+		return errors.Wrapf(errInvalidFormat, "exponent is not supported: %s", s)
+	}
+
+	v, err := Parse(s)
+	if err != nil {
+		return errors.Wrapf(err, "parse points %s", s)
+	}
+	*p = v
+
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (p Points) Value() (driver.Value, error) {
+	return p.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (p *Points) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*p = 0
+		return nil
+	case int64:
+		*p = FromInt(v)
+		return nil
+	case []byte:
+		return p.scanString(string(v))
+	case string:
+		return p.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into points", src)
+	}
+}
+
+func (p *Points) scanString(s string) error {
+	v, err := Parse(s)
+	if err != nil {
+		return errors.Wrapf(err, "scan points %s", s)
+	}
+	*p = v
+
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
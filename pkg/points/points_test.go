@@ -0,0 +1,105 @@
+package points
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Points
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "500", want: 50000},
+		{in: "500.5", want: 50050},
+		{in: "729.98", want: 72998},
+		{in: ".5", want: 50},
+		{in: "100.000000", want: 10000},
+		{in: "-1", want: -100},
+		{in: "0.001", wantErr: true},
+		{in: "1,5", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0", Points(0).String())
+	assert.Equal(t, "500", FromInt(500).String())
+	assert.Equal(t, "500.5", Points(50050).String())
+	assert.Equal(t, "729.98", Points(72998).String())
+	assert.Equal(t, "0.05", Points(5).String())
+	assert.Equal(t, "-0.5", Points(-50).String())
+}
+
+func TestNoDrift(t *testing.T) {
+	var total Points
+	accrual, err := Parse("729.98")
+	require.NoError(t, err)
+
+	for i := 0; i < 1000; i++ {
+		total += accrual
+	}
+	for i := 0; i < 999; i++ {
+		total -= accrual
+	}
+
+	assert.Equal(t, "729.98", total.String())
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		Sum Points `json:"sum"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(`{"sum":751.15}`), &v))
+	assert.Equal(t, Points(75115), v.Sum)
+
+	b, err := json.Marshal(v)
+	require.NoError(t, err)
+	assert.Equal(t, `{"sum":751.15}`, string(b))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"sum":1e3}`), &v))
+	assert.Error(t, json.Unmarshal([]byte(`{"sum":0.125}`), &v))
+}
+
+func TestScan(t *testing.T) {
+	var p Points
+
+	require.NoError(t, p.Scan([]byte("729.98")))
+	assert.Equal(t, Points(72998), p)
+
+	require.NoError(t, p.Scan("12"))
+	assert.Equal(t, Points(1200), p)
+
+	require.NoError(t, p.Scan(int64(3)))
+	assert.Equal(t, Points(300), p)
+
+	require.NoError(t, p.Scan(nil))
+	assert.Equal(t, Points(0), p)
+
+	assert.Error(t, p.Scan(1.5))
+
+	v, err := Points(72998).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "729.98", v)
+}